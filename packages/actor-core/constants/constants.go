@@ -15,6 +15,12 @@ const (
 	SystemIDStealth       = "stealth"
 	SystemIDPerception    = "perception"
 	SystemIDLuck          = "luck"
+
+	// SystemIDDerivedFormula marks base values produced by derived-stat formulas
+	SystemIDDerivedFormula = "derived_formula"
+
+	// SystemIDRealmCaps marks cap contributions produced by a realm cap table
+	SystemIDRealmCaps = "realm_caps"
 )
 
 // Dimension Names
@@ -80,6 +86,28 @@ const (
 	ContextCraftingQuality = "crafting_quality"
 )
 
+// Resolve Context Keys are the keys ResolveWithContext reads from its context map
+const (
+	// ResolveContextIncludeTags keeps only contributions carrying all of the given tags
+	ResolveContextIncludeTags = "include_tags"
+
+	// ResolveContextExcludeTags drops contributions carrying any of the given tags
+	ResolveContextExcludeTags = "exclude_tags"
+
+	// ResolveContextProvenance records the contributing systems per dimension in the snapshot
+	ResolveContextProvenance = "provenance"
+
+	// ResolveContextRealm overrides the actor's realm paths for realm-scoped caps
+	ResolveContextRealm = "realm"
+)
+
+// Contribution Tags with a meaning to the aggregator
+const (
+	// TagCondition holds the predicate a CONDITIONAL contribution applies under,
+	// such as `in_combat && has_buff("berserk")`
+	TagCondition = "condition"
+)
+
 // Error Codes
 const (
 	// Validation Errors
@@ -89,6 +117,8 @@ const (
 	ErrorCodeMissingRequiredField = "V004"
 	ErrorCodeValueOutOfRange      = "V005"
 	ErrorCodeSchemaValidationFailed = "V006"
+	ErrorCodeActorValidationFailed  = "V007"
+	ErrorCodeInvalidCondition       = "V008"
 
 	// System Errors
 	ErrorCodeSubsystemContributionFailed = "S001"
@@ -97,6 +127,8 @@ const (
 	ErrorCodeCapsConflictDetected        = "S004"
 	ErrorCodeLayerOrderInvalid           = "S005"
 	ErrorCodeAcrossLayerPolicyInvalid    = "S006"
+	ErrorCodeAPILevelUnsupported         = "S007"
+	ErrorCodeIncompatibleOutput          = "S008"
 
 	// Performance Errors
 	ErrorCodeOperationTimeout        = "P001"
@@ -124,6 +156,14 @@ const (
 	DefaultMaxMemory         = "2gb"
 	DefaultPoolSize          = 100
 	DefaultMinIdleConns      = 10
+
+	// DefaultCapStatisticsWindow is the number of recent resolves cap hit
+	// statistics are kept for
+	DefaultCapStatisticsWindow = 1000
+
+	// DefaultConditionCacheSize is the number of parsed contribution
+	// conditions an aggregator keeps
+	DefaultConditionCacheSize = 1024
 )
 
 // Clamp Ranges
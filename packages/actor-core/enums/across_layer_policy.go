@@ -0,0 +1,30 @@
+package enums
+
+// AcrossLayerPolicy represents how caps from different layers are combined
+type AcrossLayerPolicy string
+
+const (
+	// AcrossLayerIntersect keeps the range every layer allows
+	AcrossLayerIntersect AcrossLayerPolicy = "intersect"
+
+	// AcrossLayerUnion keeps the range any layer allows
+	AcrossLayerUnion AcrossLayerPolicy = "union"
+
+	// AcrossLayerPrioritizedOverride keeps the caps of the first layer in order that sets any
+	AcrossLayerPrioritizedOverride AcrossLayerPolicy = "prioritized_override"
+)
+
+// IsValid checks if the across-layer policy is valid
+func (p AcrossLayerPolicy) IsValid() bool {
+	switch p {
+	case AcrossLayerIntersect, AcrossLayerUnion, AcrossLayerPrioritizedOverride:
+		return true
+	default:
+		return false
+	}
+}
+
+// String returns the string representation of the across-layer policy
+func (p AcrossLayerPolicy) String() string {
+	return string(p)
+}
@@ -18,10 +18,11 @@ const (
 	// BucketOverride represents override contributions
 	BucketOverride Bucket = "OVERRIDE"
 	
-	// BucketExponential represents exponential contributions (value^exponent)
+	// BucketExponential raises the running value to each contribution's power
 	BucketExponential Bucket = "EXPONENTIAL"
 	
-	// BucketLogarithmic represents logarithmic contributions (log(value))
+	// BucketLogarithmic scales the running value by 1 + log_base(1 + Σ values),
+	// so stacked bonuses have diminishing returns
 	BucketLogarithmic Bucket = "LOGARITHMIC"
 	
 	// BucketConditional represents conditional contributions
@@ -95,3 +96,17 @@ func (b Bucket) IsOverride() bool {
 func (b Bucket) IsConditional() bool {
 	return b == BucketConditional
 }
+
+// DefaultBucketOrder returns the order the pipeline applies buckets in when a
+// merge rule does not set one. OVERRIDE comes last, so an override is final.
+func DefaultBucketOrder() []Bucket {
+	return []Bucket{
+		BucketFlat,
+		BucketMult,
+		BucketPostAdd,
+		BucketExponential,
+		BucketLogarithmic,
+		BucketConditional,
+		BucketOverride,
+	}
+}
@@ -0,0 +1,29 @@
+package enums
+
+// DimensionKind represents whether a dimension is resolved as a primary or a
+// derived stat
+type DimensionKind string
+
+const (
+	// DimensionKindPrimary represents a core attribute, resolved into Snapshot.Primary
+	DimensionKindPrimary DimensionKind = "primary"
+
+	// DimensionKindDerived represents a stat calculated from other dimensions,
+	// resolved into Snapshot.Derived
+	DimensionKindDerived DimensionKind = "derived"
+)
+
+// IsValid checks if the dimension kind is valid
+func (k DimensionKind) IsValid() bool {
+	switch k {
+	case DimensionKindPrimary, DimensionKindDerived:
+		return true
+	default:
+		return false
+	}
+}
+
+// String returns the string representation of the dimension kind
+func (k DimensionKind) String() string {
+	return string(k)
+}
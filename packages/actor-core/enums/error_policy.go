@@ -0,0 +1,45 @@
+package enums
+
+// ErrorPolicy represents how the aggregator handles a failing subsystem
+type ErrorPolicy string
+
+const (
+	// ErrorPolicyFailFast aborts the resolve when the subsystem fails
+	ErrorPolicyFailFast ErrorPolicy = "FAIL_FAST"
+
+	// ErrorPolicySkipAndRecord skips the subsystem and records the failure on the snapshot
+	ErrorPolicySkipAndRecord ErrorPolicy = "SKIP_AND_RECORD"
+
+	// ErrorPolicyUseLastGood reuses the subsystem's last successful output for the actor
+	ErrorPolicyUseLastGood ErrorPolicy = "USE_LAST_GOOD"
+)
+
+// IsValid checks if the error policy is valid
+func (ep ErrorPolicy) IsValid() bool {
+	switch ep {
+	case ErrorPolicyFailFast, ErrorPolicySkipAndRecord, ErrorPolicyUseLastGood:
+		return true
+	default:
+		return false
+	}
+}
+
+// String returns the string representation of the error policy
+func (ep ErrorPolicy) String() string {
+	return string(ep)
+}
+
+// IsFailFast checks if the error policy is fail-fast
+func (ep ErrorPolicy) IsFailFast() bool {
+	return ep == ErrorPolicyFailFast
+}
+
+// IsSkipAndRecord checks if the error policy is skip-and-record
+func (ep ErrorPolicy) IsSkipAndRecord() bool {
+	return ep == ErrorPolicySkipAndRecord
+}
+
+// IsUseLastGood checks if the error policy is use-last-good
+func (ep ErrorPolicy) IsUseLastGood() bool {
+	return ep == ErrorPolicyUseLastGood
+}
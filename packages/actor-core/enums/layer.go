@@ -1,6 +1,7 @@
 package enums
 
-// Layer represents the cap layer scope
+// Layer represents a built-in cap layer scope. Cap layer registries may
+// register other layers (see interfaces.CapLayerRegistry.RegisterLayer).
 type Layer string
 
 const (
@@ -0,0 +1,30 @@
+package enums
+
+// SoftCapCurve represents the curve a soft cap tapers a value with
+type SoftCapCurve string
+
+const (
+	// SoftCapHyperbolic maps x to scale * x / (x + k)
+	SoftCapHyperbolic SoftCapCurve = "HYPERBOLIC"
+
+	// SoftCapPiecewiseLinear reduces the slope above each knee
+	SoftCapPiecewiseLinear SoftCapCurve = "PIECEWISE_LINEAR"
+
+	// SoftCapExponentialDecay decays the excess above a threshold towards threshold + scale
+	SoftCapExponentialDecay SoftCapCurve = "EXPONENTIAL_DECAY"
+)
+
+// IsValid checks if the soft cap curve is valid
+func (c SoftCapCurve) IsValid() bool {
+	switch c {
+	case SoftCapHyperbolic, SoftCapPiecewiseLinear, SoftCapExponentialDecay:
+		return true
+	default:
+		return false
+	}
+}
+
+// String returns the string representation of the soft cap curve
+func (c SoftCapCurve) String() string {
+	return string(c)
+}
@@ -0,0 +1,415 @@
+package expression
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Expression represents a parsed arithmetic expression over named variables.
+// Comparisons and logical operators evaluate to 1 (true) or 0 (false).
+type Expression struct {
+	source    string
+	root      node
+	variables []string
+}
+
+// Environment supplies variables and functions that are not built in at
+// evaluation time
+type Environment interface {
+	// Lookup returns the value of a variable
+	Lookup(name string) (float64, bool)
+
+	// Call calls a function that is not built in. Arguments are float64 or
+	// string values. ok is false if the environment has no such function.
+	Call(name string, args []interface{}) (value float64, ok bool, err error)
+}
+
+// variableEnvironment is an Environment over a fixed set of variables
+type variableEnvironment map[string]float64
+
+func (v variableEnvironment) Lookup(name string) (float64, bool) {
+	value, exists := v[name]
+	return value, exists
+}
+
+func (v variableEnvironment) Call(name string, args []interface{}) (float64, bool, error) {
+	return 0.0, false, nil
+}
+
+// Parse parses an arithmetic expression such as "vitality * 10 + strength * 2"
+func Parse(source string) (*Expression, error) {
+	return parse(source, false)
+}
+
+// ParseCondition parses a condition such as `in_combat && has_buff("berserk")`.
+// Unlike Parse, it accepts calls to functions that are not built in; they are
+// resolved by the Environment passed to EvaluateCondition.
+func ParseCondition(source string) (*Expression, error) {
+	return parse(source, true)
+}
+
+// parse parses an expression, allowing external function calls if external is set
+func parse(source string, external bool) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, fmt.Errorf("failed to tokenize expression %q: %w", source, err)
+	}
+
+	p := &parser{tokens: tokens, external: external}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse expression %q: %w", source, err)
+	}
+
+	if tok := p.peek(); tok.kind != tokenEOF {
+		return nil, fmt.Errorf("failed to parse expression %q: unexpected token %q at position %d", source, tok.text, tok.pos)
+	}
+
+	seen := make(map[string]bool)
+	root.collectVariables(seen)
+
+	variables := make([]string, 0, len(seen))
+	for name := range seen {
+		variables = append(variables, name)
+	}
+	sort.Strings(variables)
+
+	return &Expression{
+		source:    source,
+		root:      root,
+		variables: variables,
+	}, nil
+}
+
+// Evaluate evaluates the expression with the given variable values
+func (e *Expression) Evaluate(variables map[string]float64) (float64, error) {
+	return e.EvaluateWith(variableEnvironment(variables))
+}
+
+// EvaluateWith evaluates the expression against an environment
+func (e *Expression) EvaluateWith(env Environment) (float64, error) {
+	value, err := e.root.eval(env)
+	if err != nil {
+		return 0.0, fmt.Errorf("failed to evaluate expression %q: %w", e.source, err)
+	}
+
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0.0, fmt.Errorf("failed to evaluate expression %q: result is not a finite number", e.source)
+	}
+
+	return value, nil
+}
+
+// EvaluateCondition evaluates the expression against an environment and
+// reports whether the result is true (non-zero)
+func (e *Expression) EvaluateCondition(env Environment) (bool, error) {
+	value, err := e.EvaluateWith(env)
+	if err != nil {
+		return false, err
+	}
+	return value != 0, nil
+}
+
+// Variables returns the sorted names of all variables referenced by the expression
+func (e *Expression) Variables() []string {
+	variables := make([]string, len(e.variables))
+	copy(variables, e.variables)
+	return variables
+}
+
+// String returns the source of the expression
+func (e *Expression) String() string {
+	return e.source
+}
+
+// node represents a node of the expression tree
+type node interface {
+	eval(env Environment) (float64, error)
+	collectVariables(seen map[string]bool)
+}
+
+// numberNode represents a numeric literal
+type numberNode struct {
+	value float64
+}
+
+func (n *numberNode) eval(env Environment) (float64, error) {
+	return n.value, nil
+}
+
+func (n *numberNode) collectVariables(seen map[string]bool) {}
+
+// variableNode represents a variable reference
+type variableNode struct {
+	name string
+}
+
+func (n *variableNode) eval(env Environment) (float64, error) {
+	value, exists := env.Lookup(n.name)
+	if !exists {
+		return 0.0, fmt.Errorf("undefined variable %s", n.name)
+	}
+	return value, nil
+}
+
+func (n *variableNode) collectVariables(seen map[string]bool) {
+	seen[n.name] = true
+}
+
+// negateNode represents a unary minus
+type negateNode struct {
+	operand node
+}
+
+func (n *negateNode) eval(env Environment) (float64, error) {
+	value, err := n.operand.eval(env)
+	if err != nil {
+		return 0.0, err
+	}
+	return -value, nil
+}
+
+func (n *negateNode) collectVariables(seen map[string]bool) {
+	n.operand.collectVariables(seen)
+}
+
+// notNode represents a logical not
+type notNode struct {
+	operand node
+}
+
+func (n *notNode) eval(env Environment) (float64, error) {
+	value, err := n.operand.eval(env)
+	if err != nil {
+		return 0.0, err
+	}
+	return boolValue(value == 0), nil
+}
+
+func (n *notNode) collectVariables(seen map[string]bool) {
+	n.operand.collectVariables(seen)
+}
+
+// logicalNode represents a short-circuiting logical and/or
+type logicalNode struct {
+	operator string
+	left     node
+	right    node
+}
+
+func (n *logicalNode) eval(env Environment) (float64, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return 0.0, err
+	}
+
+	// The right operand is only evaluated if it decides the result
+	if (n.operator == "&&" && left == 0) || (n.operator == "||" && left != 0) {
+		return boolValue(left != 0), nil
+	}
+
+	right, err := n.right.eval(env)
+	if err != nil {
+		return 0.0, err
+	}
+	return boolValue(right != 0), nil
+}
+
+func (n *logicalNode) collectVariables(seen map[string]bool) {
+	n.left.collectVariables(seen)
+	n.right.collectVariables(seen)
+}
+
+// stringNode represents a string literal function argument
+type stringNode struct {
+	value string
+}
+
+func (n *stringNode) eval(env Environment) (float64, error) {
+	return 0.0, fmt.Errorf("string %q used as a number", n.value)
+}
+
+func (n *stringNode) collectVariables(seen map[string]bool) {}
+
+// binaryNode represents a binary arithmetic or comparison operation
+type binaryNode struct {
+	operator string
+	left     node
+	right    node
+}
+
+func (n *binaryNode) eval(env Environment) (float64, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return 0.0, err
+	}
+
+	right, err := n.right.eval(env)
+	if err != nil {
+		return 0.0, err
+	}
+
+	switch n.operator {
+	case "+":
+		return left + right, nil
+	case "-":
+		return left - right, nil
+	case "*":
+		return left * right, nil
+	case "/":
+		if right == 0 {
+			return 0.0, fmt.Errorf("division by zero")
+		}
+		return left / right, nil
+	case "%":
+		if right == 0 {
+			return 0.0, fmt.Errorf("modulo by zero")
+		}
+		return math.Mod(left, right), nil
+	case "^":
+		return math.Pow(left, right), nil
+	case "==":
+		return boolValue(left == right), nil
+	case "!=":
+		return boolValue(left != right), nil
+	case "<":
+		return boolValue(left < right), nil
+	case "<=":
+		return boolValue(left <= right), nil
+	case ">":
+		return boolValue(left > right), nil
+	case ">=":
+		return boolValue(left >= right), nil
+	default:
+		return 0.0, fmt.Errorf("unknown operator %s", n.operator)
+	}
+}
+
+func (n *binaryNode) collectVariables(seen map[string]bool) {
+	n.left.collectVariables(seen)
+	n.right.collectVariables(seen)
+}
+
+// callNode represents a function call. fn is nil for functions resolved by
+// the Environment.
+type callNode struct {
+	name string
+	fn   *function
+	args []node
+}
+
+func (n *callNode) eval(env Environment) (float64, error) {
+	if n.fn == nil {
+		return n.evalExternal(env)
+	}
+
+	args := make([]float64, len(n.args))
+	for i, arg := range n.args {
+		value, err := arg.eval(env)
+		if err != nil {
+			return 0.0, err
+		}
+		args[i] = value
+	}
+
+	return n.fn.call(args)
+}
+
+// evalExternal calls a function resolved by the Environment
+func (n *callNode) evalExternal(env Environment) (float64, error) {
+	args := make([]interface{}, len(n.args))
+	for i, arg := range n.args {
+		if str, ok := arg.(*stringNode); ok {
+			args[i] = str.value
+			continue
+		}
+
+		value, err := arg.eval(env)
+		if err != nil {
+			return 0.0, err
+		}
+		args[i] = value
+	}
+
+	value, ok, err := env.Call(n.name, args)
+	if err != nil {
+		return 0.0, fmt.Errorf("function %s: %w", n.name, err)
+	}
+	if !ok {
+		return 0.0, fmt.Errorf("unknown function %s", n.name)
+	}
+	return value, nil
+}
+
+func (n *callNode) collectVariables(seen map[string]bool) {
+	for _, arg := range n.args {
+		arg.collectVariables(seen)
+	}
+}
+
+// boolValue converts a boolean to 1 or 0
+func boolValue(b bool) float64 {
+	if b {
+		return 1.0
+	}
+	return 0.0
+}
+
+// function represents a built-in function
+type function struct {
+	// arity is the number of arguments, or -1 for variadic functions
+	arity int
+	call  func(args []float64) (float64, error)
+}
+
+// functions contains the built-in functions available to expressions
+var functions = map[string]function{
+	"min": {arity: -1, call: func(args []float64) (float64, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Min(result, arg)
+		}
+		return result, nil
+	}},
+	"max": {arity: -1, call: func(args []float64) (float64, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = math.Max(result, arg)
+		}
+		return result, nil
+	}},
+	"clamp": {arity: 3, call: func(args []float64) (float64, error) {
+		return math.Max(args[1], math.Min(args[2], args[0])), nil
+	}},
+	"abs": {arity: 1, call: func(args []float64) (float64, error) {
+		return math.Abs(args[0]), nil
+	}},
+	"floor": {arity: 1, call: func(args []float64) (float64, error) {
+		return math.Floor(args[0]), nil
+	}},
+	"ceil": {arity: 1, call: func(args []float64) (float64, error) {
+		return math.Ceil(args[0]), nil
+	}},
+	"round": {arity: 1, call: func(args []float64) (float64, error) {
+		return math.Round(args[0]), nil
+	}},
+	"sqrt": {arity: 1, call: func(args []float64) (float64, error) {
+		if args[0] < 0 {
+			return 0.0, fmt.Errorf("sqrt of negative number")
+		}
+		return math.Sqrt(args[0]), nil
+	}},
+	"pow": {arity: 2, call: func(args []float64) (float64, error) {
+		return math.Pow(args[0], args[1]), nil
+	}},
+	"log": {arity: 1, call: func(args []float64) (float64, error) {
+		if args[0] <= 0 {
+			return 0.0, fmt.Errorf("log of non-positive number")
+		}
+		return math.Log(args[0]), nil
+	}},
+	"exp": {arity: 1, call: func(args []float64) (float64, error) {
+		return math.Exp(args[0]), nil
+	}},
+}
@@ -0,0 +1,124 @@
+package expression
+
+import (
+	"fmt"
+	"strconv"
+	"unicode"
+)
+
+// tokenKind represents the kind of a lexical token
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenIdent
+	tokenOperator
+	tokenLParen
+	tokenRParen
+	tokenComma
+	tokenString
+)
+
+// token represents a lexical token
+type token struct {
+	kind   tokenKind
+	text   string
+	number float64
+	pos    int
+}
+
+// tokenize splits the source into tokens
+func tokenize(source string) ([]token, error) {
+	runes := []rune(source)
+	tokens := make([]token, 0, len(runes)/2+1)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			// Optional exponent (1e3, 2.5E-2)
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				j := i + 1
+				if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+					j++
+				}
+				if j < len(runes) && unicode.IsDigit(runes[j]) {
+					i = j
+					for i < len(runes) && unicode.IsDigit(runes[i]) {
+						i++
+					}
+				}
+			}
+
+			text := string(runes[start:i])
+			value, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %q at position %d", text, start)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text, number: value, pos: start})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), pos: start})
+
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen, text: "(", pos: i})
+			i++
+
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen, text: ")", pos: i})
+			i++
+
+		case r == ',':
+			tokens = append(tokens, token{kind: tokenComma, text: ",", pos: i})
+			i++
+
+		case r == '"' || r == '\'':
+			start := i
+			i++
+			for i < len(runes) && runes[i] != r {
+				i++
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: string(runes[start+1 : i]), pos: start})
+			i++
+
+		case i+1 < len(runes) && isTwoCharOperator(string(runes[i:i+2])):
+			tokens = append(tokens, token{kind: tokenOperator, text: string(runes[i : i+2]), pos: i})
+			i += 2
+
+		case r == '+' || r == '-' || r == '*' || r == '/' || r == '%' || r == '^' || r == '<' || r == '>' || r == '!':
+			tokens = append(tokens, token{kind: tokenOperator, text: string(r), pos: i})
+			i++
+
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+		}
+	}
+
+	tokens = append(tokens, token{kind: tokenEOF, pos: len(runes)})
+	return tokens, nil
+}
+
+// isTwoCharOperator checks if text is a comparison or logical operator of two characters
+func isTwoCharOperator(text string) bool {
+	switch text {
+	case "&&", "||", "==", "!=", "<=", ">=":
+		return true
+	}
+	return false
+}
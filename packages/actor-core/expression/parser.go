@@ -0,0 +1,273 @@
+package expression
+
+import (
+	"fmt"
+)
+
+// parser is a recursive descent parser over a token stream
+//
+// Grammar:
+//
+//	expr       := and ("||" and)*
+//	and        := comparison ("&&" comparison)*
+//	comparison := sum (("==" | "!=" | "<" | "<=" | ">" | ">=") sum)?
+//	sum        := term (("+" | "-") term)*
+//	term       := unary (("*" | "/" | "%") unary)*
+//	unary      := ("-" | "!") unary | power
+//	power      := primary ("^" unary)?
+//	primary    := number | ident | ident "(" args ")" | "(" expr ")"
+//	args       := arg ("," arg)*
+//	arg        := string | expr
+type parser struct {
+	tokens []token
+	pos    int
+
+	// external allows calls to functions that are not built in; they are
+	// resolved by the Environment at evaluation time
+	external bool
+}
+
+// peek returns the current token
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+// next consumes and returns the current token
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// isOperator checks if the current token is one of the given operators
+func (p *parser) isOperator(operators ...string) bool {
+	tok := p.peek()
+	if tok.kind != tokenOperator {
+		return false
+	}
+	for _, operator := range operators {
+		if tok.text == operator {
+			return true
+		}
+	}
+	return false
+}
+
+// parseExpr parses a logical or
+func (p *parser) parseExpr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator("||") {
+		operator := p.next().text
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{operator: operator, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// parseAnd parses a logical and
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseComparison()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator("&&") {
+		operator := p.next().text
+		right, err := p.parseComparison()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalNode{operator: operator, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// parseComparison parses a non-associative comparison
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isOperator("==", "!=", "<", "<=", ">", ">=") {
+		operator := p.next().text
+		right, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		return &binaryNode{operator: operator, left: left, right: right}, nil
+	}
+
+	return left, nil
+}
+
+// parseSum parses an additive expression
+func (p *parser) parseSum() (node, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator("+", "-") {
+		operator := p.next().text
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{operator: operator, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// parseTerm parses a multiplicative expression
+func (p *parser) parseTerm() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator("*", "/", "%") {
+		operator := p.next().text
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{operator: operator, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+// parseUnary parses a unary minus or logical not
+func (p *parser) parseUnary() (node, error) {
+	if p.isOperator("-", "!") {
+		operator := p.next().text
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if operator == "!" {
+			return &notNode{operand: operand}, nil
+		}
+		return &negateNode{operand: operand}, nil
+	}
+
+	return p.parsePower()
+}
+
+// parsePower parses a right-associative power expression
+func (p *parser) parsePower() (node, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isOperator("^") {
+		p.next()
+		exponent, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &binaryNode{operator: "^", left: base, right: exponent}, nil
+	}
+
+	return base, nil
+}
+
+// parsePrimary parses numbers, variables, function calls and parentheses
+func (p *parser) parsePrimary() (node, error) {
+	tok := p.next()
+
+	switch tok.kind {
+	case tokenNumber:
+		return &numberNode{value: tok.number}, nil
+
+	case tokenIdent:
+		if p.peek().kind != tokenLParen {
+			return &variableNode{name: tok.text}, nil
+		}
+
+		p.next()
+		args := make([]node, 0, 2)
+		if p.peek().kind != tokenRParen {
+			for {
+				arg, err := p.parseArg()
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+
+				if p.peek().kind != tokenComma {
+					break
+				}
+				p.next()
+			}
+		}
+
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, fmt.Errorf("expected ')' at position %d", closing.pos)
+		}
+
+		fn, exists := functions[tok.text]
+		if !exists {
+			if p.external {
+				return &callNode{name: tok.text, args: args}, nil
+			}
+			return nil, fmt.Errorf("unknown function %s at position %d", tok.text, tok.pos)
+		}
+		if fn.arity >= 0 && len(args) != fn.arity {
+			return nil, fmt.Errorf("function %s expects %d arguments, got %d", tok.text, fn.arity, len(args))
+		}
+		if fn.arity < 0 && len(args) == 0 {
+			return nil, fmt.Errorf("function %s expects at least one argument", tok.text)
+		}
+		for _, arg := range args {
+			if _, isString := arg.(*stringNode); isString {
+				return nil, fmt.Errorf("function %s expects numeric arguments", tok.text)
+			}
+		}
+
+		return &callNode{name: tok.text, fn: &fn, args: args}, nil
+
+	case tokenLParen:
+		inner, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokenRParen {
+			return nil, fmt.Errorf("expected ')' at position %d", closing.pos)
+		}
+		return inner, nil
+
+	case tokenString:
+		return nil, fmt.Errorf("unexpected string %q at position %d", tok.text, tok.pos)
+
+	case tokenEOF:
+		return nil, fmt.Errorf("unexpected end of expression")
+
+	default:
+		return nil, fmt.Errorf("unexpected token %q at position %d", tok.text, tok.pos)
+	}
+}
+
+// parseArg parses a function argument, which may be a string literal
+func (p *parser) parseArg() (node, error) {
+	if tok := p.peek(); tok.kind == tokenString {
+		p.next()
+		return &stringNode{value: tok.text}, nil
+	}
+
+	return p.parseExpr()
+}
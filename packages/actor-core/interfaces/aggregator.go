@@ -13,20 +13,38 @@ type Aggregator interface {
 	// ResolveWithContext resolves with additional context information
 	ResolveWithContext(ctx context.Context, actor *Actor, context map[string]interface{}) (*Snapshot, error)
 
-	// ResolveBatch resolves multiple actors in batch
+	// ResolveBatch resolves multiple actors in batch. The returned snapshots are
+	// aligned with actors (nil where resolution failed); if any actor failed the
+	// error is a *BatchError whose Errors are aligned with actors as well.
 	ResolveBatch(ctx context.Context, actors []*Actor) ([]*Snapshot, error)
 
+	// Explain resolves the actor and returns a trace of how the dimension was computed
+	Explain(ctx context.Context, actor *Actor, dimension string) (*AggregationTrace, error)
+
+	// ExplainWithContext explains the dimension as resolved with the given context
+	ExplainWithContext(ctx context.Context, actor *Actor, dimension string, context map[string]interface{}) (*AggregationTrace, error)
+
 	// GetCachedSnapshot returns a cached snapshot if available
 	GetCachedSnapshot(actorID string) (*Snapshot, bool)
 
 	// InvalidateCache invalidates the cache for the given actor
 	InvalidateCache(actorID string)
 
+	// MarkDirty marks subsystems of an actor as changed, or all of them if no
+	// system IDs are given, so that the next resolve invokes them again. Only
+	// CachingSubsystems that opt in through ShouldCache have their outputs
+	// reused at all; the others are invoked on every resolve.
+	MarkDirty(actorID string, systemIDs ...string)
+
 	// ClearCache clears all cached snapshots
 	ClearCache()
 
 	// GetMetrics returns performance metrics
 	GetMetrics() *AggregatorMetrics
+
+	// GetSubsystemMetrics returns the metrics of a subsystem, including what it
+	// reports as a PerformanceSubsystem
+	GetSubsystemMetrics(systemID string) (*SubsystemMetrics, bool)
 }
 
 // AggregatorMetrics represents performance metrics for the aggregator
@@ -57,6 +75,18 @@ type AggregatorMetrics struct {
 
 	// MemoryUsage is the current memory usage in bytes
 	MemoryUsage int64
+
+	// BatchesProcessed is the total number of batch resolves
+	BatchesProcessed int64
+
+	// BatchActorsProcessed is the total number of unique actors resolved in batches
+	BatchActorsProcessed int64
+
+	// BatchThroughput is the number of batch actors resolved per second
+	BatchThroughput float64
+
+	// Subsystems are the per-subsystem metrics, keyed by system ID
+	Subsystems map[string]*SubsystemMetrics
 }
 
 // GetCacheHitRate returns the cache hit rate
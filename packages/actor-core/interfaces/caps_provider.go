@@ -1,6 +1,7 @@
 package interfaces
 
 import (
+	"chaos-actor-module/packages/actor-core/types"
 	"context"
 )
 
@@ -18,30 +19,63 @@ type CapsProvider interface {
 	// GetAcrossLayerPolicy returns the across-layer policy
 	GetAcrossLayerPolicy() string
 
+	// GetDimensionPolicy returns the across-layer policy for a dimension
+	GetDimensionPolicy(dimension string) string
+
 	// ValidateCaps validates the given caps
 	ValidateCaps(caps EffectiveCaps) error
 
+	// ValidateScopes checks that every cap contribution's scope is a known layer
+	ValidateScopes(caps []CapContribution) error
+
 	// GetCapsForDimension returns caps for a specific dimension
 	GetCapsForDimension(dimension string) (Caps, error)
 
 	// GetSupportedDimensions returns all supported dimensions
 	GetSupportedDimensions() []string
 
-	// GetCapStatistics returns statistics about caps
+	// GetCapStatistics returns statistics about caps: the caps the outputs set
+	// for the actor, and how often each dimension hit its caps in recent resolves
 	GetCapStatistics(ctx context.Context, actor *Actor, outputs []*SubsystemOutput) (map[string]interface{}, error)
 
+	// RecordResolve records which dimensions of a resolved snapshot were at
+	// their caps, for GetCapStatistics
+	RecordResolve(snapshot *Snapshot)
+
 	// Validate validates the caps provider
 	Validate() error
+
+	// GetGeneration returns the generation of the cap configuration
+	GetGeneration() int64
 }
 
 // Caps represents min/max caps for a dimension
-// This is a forward declaration - actual implementation is in types package
-type Caps struct {
-	Min float64 `json:"min"`
-	Max float64 `json:"max"`
-}
+type Caps = types.Caps
 
 // EffectiveCaps represents effective caps for all dimensions
 type EffectiveCaps map[string]Caps
 
-// Methods will be implemented in the actual types package
+// CapHitStatistics count how often a dimension was at its effective caps in
+// the recorded resolves
+type CapHitStatistics struct {
+	// Capped is the number of resolves in which the dimension had effective
+	// caps and a value
+	Capped int64 `json:"capped"`
+
+	// AtMin is the number of those resolves in which the value was at the min cap
+	AtMin int64 `json:"at_min"`
+
+	// AtMax is the number of those resolves in which the value was at the max cap
+	AtMax int64 `json:"at_max"`
+
+	// Hits is the number of those resolves in which the value was at either cap
+	Hits int64 `json:"hits"`
+}
+
+// HitRate returns the fraction of capped resolves in which the dimension was at a cap
+func (s CapHitStatistics) HitRate() float64 {
+	if s.Capped == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Capped)
+}
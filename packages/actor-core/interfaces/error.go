@@ -1,97 +1,46 @@
 package interfaces
 
 import (
+	"chaos-actor-module/packages/actor-core/types"
+	"fmt"
 	"time"
 )
 
 // ActorCoreError represents an error in the Actor Core system
-type ActorCoreError struct {
-	// Type is the error category
-	Type string `json:"type"`
+type ActorCoreError = types.ActorCoreError
 
-	// Code is the specific error code
-	Code string `json:"code"`
-
-	// Message is the human-readable message
-	Message string `json:"message"`
-
-	// System is the originating system
-	System string `json:"system"`
-
-	// Dimension is the affected dimension
-	Dimension string `json:"dimension"`
-
-	// Layer is the affected layer
-	Layer string `json:"layer"`
-
-	// Context contains additional context
-	Context map[string]interface{} `json:"context"`
-
-	// Timestamp is when the error occurred
-	Timestamp time.Time `json:"timestamp"`
-
-	// StackTrace is the stack trace (debug only)
-	StackTrace string `json:"stack_trace,omitempty"`
+// BatchError reports per-actor failures of a batch resolve. Errors is aligned
+// with the input actors; entries for actors that resolved successfully are nil.
+type BatchError struct {
+	// Errors holds the error for each input actor
+	Errors []error
 }
 
 // Error returns the error message
-func (ace *ActorCoreError) Error() string {
-	return ace.Message
-}
-
-// GetType returns the error type
-func (ace *ActorCoreError) GetType() string {
-	return ace.Type
-}
-
-// GetCode returns the error code
-func (ace *ActorCoreError) GetCode() string {
-	return ace.Code
-}
-
-// GetSystem returns the originating system
-func (ace *ActorCoreError) GetSystem() string {
-	return ace.System
-}
-
-// GetDimension returns the affected dimension
-func (ace *ActorCoreError) GetDimension() string {
-	return ace.Dimension
-}
-
-// GetLayer returns the affected layer
-func (ace *ActorCoreError) GetLayer() string {
-	return ace.Layer
-}
-
-// GetContext returns the additional context
-func (ace *ActorCoreError) GetContext() map[string]interface{} {
-	return ace.Context
-}
-
-// GetTimestamp returns the timestamp
-func (ace *ActorCoreError) GetTimestamp() time.Time {
-	return ace.Timestamp
-}
-
-// GetStackTrace returns the stack trace
-func (ace *ActorCoreError) GetStackTrace() string {
-	return ace.StackTrace
-}
-
-// IsValidationError checks if this is a validation error
-func (ace *ActorCoreError) IsValidationError() bool {
-	return ace.Type == "validation"
-}
-
-// IsSystemError checks if this is a system error
-func (ace *ActorCoreError) IsSystemError() bool {
-	return ace.Type == "system"
-}
-
-// IsPerformanceError checks if this is a performance error
-func (ace *ActorCoreError) IsPerformanceError() bool {
-	return ace.Type == "performance"
+func (be *BatchError) Error() string {
+	return fmt.Sprintf("%d of %d actors failed to resolve", be.Failed(), len(be.Errors))
+}
+
+// Failed returns the number of actors that failed to resolve
+func (be *BatchError) Failed() int {
+	failed := 0
+	for _, err := range be.Errors {
+		if err != nil {
+			failed++
+		}
+	}
+	return failed
+}
+
+// Unwrap returns the non-nil per-actor errors
+func (be *BatchError) Unwrap() []error {
+	errs := make([]error, 0, len(be.Errors))
+	for _, err := range be.Errors {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
 }
 
 // ErrorHandler represents an error handler
@@ -0,0 +1,123 @@
+package interfaces
+
+import (
+	"chaos-actor-module/packages/actor-core/enums"
+	"chaos-actor-module/packages/actor-core/types"
+	"time"
+)
+
+// The shapes below are the ones the interfaces package declared before it
+// adopted the types package model. They are kept so that code still producing
+// them can be converted at the boundary.
+
+// LegacyContribution is a contribution with an untyped bucket
+type LegacyContribution struct {
+	Dimension string
+	Bucket    string
+	Value     float64
+	System    string
+	Priority  int64
+}
+
+// ToContribution converts the legacy contribution
+func (lc LegacyContribution) ToContribution() Contribution {
+	return Contribution{
+		Dimension: lc.Dimension,
+		Bucket:    enums.Bucket(lc.Bucket),
+		Value:     lc.Value,
+		System:    lc.System,
+		Priority:  lc.Priority,
+	}
+}
+
+// LegacyCapContribution is a cap contribution with an untyped mode and list tags
+type LegacyCapContribution struct {
+	System    string
+	Dimension string
+	Mode      string
+	Kind      string
+	Value     float64
+	Priority  int64
+	Scope     string
+	Realm     string
+	Tags      []string
+}
+
+// ToCapContribution converts the legacy cap contribution. Tags are converted
+// with types.TagsFromList.
+func (lc LegacyCapContribution) ToCapContribution() CapContribution {
+	return CapContribution{
+		System:    lc.System,
+		Dimension: lc.Dimension,
+		Mode:      enums.CapMode(lc.Mode),
+		Kind:      lc.Kind,
+		Value:     lc.Value,
+		Priority:  lc.Priority,
+		Scope:     lc.Scope,
+		Realm:     lc.Realm,
+		Tags:      types.TagsFromList(lc.Tags),
+	}
+}
+
+// LegacySubsystemOutput is a subsystem output made of legacy contributions
+type LegacySubsystemOutput struct {
+	Primary []LegacyContribution
+	Derived []LegacyContribution
+	Caps    []LegacyCapContribution
+	Context map[string]ModifierPack
+	Meta    SubsystemMeta
+}
+
+// ToSubsystemOutput converts the legacy subsystem output
+func (lo *LegacySubsystemOutput) ToSubsystemOutput() *SubsystemOutput {
+	output := &SubsystemOutput{
+		Primary: make([]Contribution, 0, len(lo.Primary)),
+		Derived: make([]Contribution, 0, len(lo.Derived)),
+		Caps:    make([]CapContribution, 0, len(lo.Caps)),
+		Context: lo.Context,
+		Meta:    lo.Meta,
+	}
+
+	for _, contrib := range lo.Primary {
+		output.Primary = append(output.Primary, contrib.ToContribution())
+	}
+	for _, contrib := range lo.Derived {
+		output.Derived = append(output.Derived, contrib.ToContribution())
+	}
+	for _, capContrib := range lo.Caps {
+		output.Caps = append(output.Caps, capContrib.ToCapContribution())
+	}
+
+	return output
+}
+
+// LegacySnapshot is the snapshot shape without resolve metadata
+type LegacySnapshot struct {
+	ActorID   string
+	Primary   map[string]float64
+	Derived   map[string]float64
+	CapsUsed  map[string]Caps
+	Context   map[string]ModifierPack
+	Failures  []SubsystemFailure
+	Version   int64
+	CreatedAt time.Time
+}
+
+// ToLegacySnapshot converts a snapshot to the legacy shape. Timestamp becomes
+// CreatedAt; SubsystemsProcessed, ProcessingTime and Metadata are dropped.
+func ToLegacySnapshot(snapshot *Snapshot) *LegacySnapshot {
+	if snapshot == nil {
+		return nil
+	}
+
+	return &LegacySnapshot{
+		ActorID:   snapshot.ActorID,
+		Primary:   snapshot.Primary,
+		Derived:   snapshot.Derived,
+		CapsUsed:  snapshot.CapsUsed,
+		Context:   snapshot.Context,
+		Failures:  snapshot.Failures,
+		Version:   snapshot.Version,
+		CreatedAt: snapshot.Timestamp,
+	}
+}
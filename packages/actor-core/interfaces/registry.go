@@ -1,5 +1,10 @@
 package interfaces
 
+import (
+	"chaos-actor-module/packages/actor-core/enums"
+	"math"
+)
+
 // CombinerRegistry represents a registry for merge rules
 type CombinerRegistry interface {
 	// GetRule returns the merge rule for the given dimension
@@ -28,6 +33,9 @@ type CombinerRegistry interface {
 
 	// Count returns the number of rules
 	Count() int64
+
+	// GetGeneration returns the generation, bumped on every rule change
+	GetGeneration() int64
 }
 
 // CapLayerRegistry represents a registry for cap layer configuration
@@ -61,6 +69,184 @@ type CapLayerRegistry interface {
 
 	// Reset resets to default configuration
 	Reset()
+
+	// GetGeneration returns the generation, bumped on every configuration change
+	GetGeneration() int64
+
+	// GetDimensionPolicy returns the across-layer policy for a dimension: its
+	// override if it has one, else the across-layer policy
+	GetDimensionPolicy(dimension string) string
+
+	// SetDimensionPolicy overrides the across-layer policy for a dimension. An
+	// empty policy removes the override.
+	SetDimensionPolicy(dimension string, policy string) error
+
+	// GetDimensionPolicies returns the per-dimension policy overrides
+	GetDimensionPolicies() map[string]string
+
+	// RegisterPolicy registers an across-layer policy under its name
+	RegisterPolicy(policy AcrossLayerPolicy) error
+
+	// GetPolicy returns the across-layer policy registered under a name
+	GetPolicy(name string) (AcrossLayerPolicy, bool)
+
+	// RegisterLayer registers a layer, or replaces the definition of a
+	// registered layer, so that it can be ordered and referenced by cap scopes
+	RegisterLayer(layer LayerDefinition) error
+
+	// GetLayer returns the definition of a registered layer
+	GetLayer(name string) (LayerDefinition, bool)
+
+	// GetLayers returns the definitions of all registered layers, sorted by name
+	GetLayers() []LayerDefinition
+
+	// ValidateScopes checks that every cap contribution's scope is a registered layer
+	ValidateScopes(caps []CapContribution) error
+}
+
+// LayerDefinition describes a cap layer
+type LayerDefinition struct {
+	// Name is the layer name, referenced by CapContribution.Scope
+	Name string `json:"name"`
+
+	// Description describes what the layer caps
+	Description string `json:"description,omitempty"`
+
+	// DefaultPolicy, if set, is the across-layer policy the layer's caps are
+	// combined with the caps of the layers before it by
+	DefaultPolicy string `json:"default_policy,omitempty"`
+}
+
+// AcrossLayerPolicy combines the caps different layers set for a dimension
+type AcrossLayerPolicy interface {
+	// Name returns the name the policy is selected by
+	Name() string
+
+	// Combine combines the caps of the layers that cap the dimension, given in
+	// layer order. layers is never empty.
+	Combine(dimension string, layers []LayerCaps) (Caps, error)
+}
+
+// LayerCaps are the caps a layer sets for a dimension
+type LayerCaps struct {
+	// Layer is the layer name
+	Layer string
+
+	// Caps are the layer's effective caps
+	Caps Caps
+}
+
+// DimensionCatalog represents a catalog of the dimensions actors are resolved in
+type DimensionCatalog interface {
+	// RegisterDimension registers a dimension, or replaces the definition of a
+	// registered dimension
+	RegisterDimension(dimension DimensionDefinition) error
+
+	// RemoveDimension removes a dimension
+	RemoveDimension(name string)
+
+	// GetDimension returns the definition of a dimension
+	GetDimension(name string) (DimensionDefinition, bool)
+
+	// GetDimensions returns the definitions of all dimensions, sorted by name
+	GetDimensions() []DimensionDefinition
+
+	// GetDimensionNames returns the names of all dimensions, sorted
+	GetDimensionNames() []string
+
+	// GetDimensionsBySubsystem returns the definitions of the dimensions a
+	// subsystem owns, sorted by name
+	GetDimensionsBySubsystem(subsystem string) []DimensionDefinition
+
+	// LoadFromConfig replaces the catalog with the dimensions in configuration
+	LoadFromConfig(config map[string]interface{}) error
+
+	// Validate validates all dimensions
+	Validate() error
+
+	// Reset restores the built-in dimensions
+	Reset()
+
+	// Count returns the number of dimensions
+	Count() int64
+
+	// GetGeneration returns the generation, bumped on every catalog change
+	GetGeneration() int64
+}
+
+// DimensionDefinition describes a dimension
+type DimensionDefinition struct {
+	// Name is the dimension name contributions and caps refer to
+	Name string `json:"name"`
+
+	// DisplayName is the human-readable name
+	DisplayName string `json:"display_name,omitempty"`
+
+	// Unit is the unit values are measured in, e.g. "points" or "m/s"
+	Unit string `json:"unit,omitempty"`
+
+	// Kind is whether the dimension is a primary or a derived stat
+	Kind enums.DimensionKind `json:"kind"`
+
+	// DefaultCaps is the range the dimension is capped to by default
+	DefaultCaps Caps `json:"default_caps"`
+
+	// Subsystem is the ID of the subsystem that owns the dimension, if any
+	Subsystem string `json:"subsystem,omitempty"`
+
+	// Description describes what the dimension affects
+	Description string `json:"description,omitempty"`
+}
+
+// DerivedFormulaRegistry represents a registry for derived-stat formulas
+type DerivedFormulaRegistry interface {
+	// GetFormula returns the formula for the given derived dimension
+	GetFormula(dimension string) (*DerivedFormula, bool)
+
+	// SetFormula parses and sets the formula expression for the given derived dimension
+	SetFormula(dimension string, expression string) error
+
+	// RemoveFormula removes the formula for the given dimension
+	RemoveFormula(dimension string)
+
+	// GetDimensions returns all dimensions with formulas
+	GetDimensions() []string
+
+	// GetEvaluationOrder returns formula dimensions ordered so that dependencies come first
+	GetEvaluationOrder() ([]string, error)
+
+	// Evaluate evaluates the formula for the given dimension against the given values
+	Evaluate(dimension string, values map[string]float64) (float64, error)
+
+	// LoadFromConfig loads formulas from configuration
+	LoadFromConfig(config map[string]interface{}) error
+
+	// Validate validates all formulas
+	Validate() error
+
+	// Clear clears all formulas
+	Clear()
+
+	// HasFormula checks if a formula exists for the given dimension
+	HasFormula(dimension string) bool
+
+	// Count returns the number of formulas
+	Count() int64
+
+	// GetGeneration returns the generation, bumped on every formula change
+	GetGeneration() int64
+}
+
+// DerivedFormula represents a formula computing a derived dimension from other dimensions
+type DerivedFormula struct {
+	// Dimension is the derived dimension computed by the formula
+	Dimension string `json:"dimension"`
+
+	// Expression is the formula source, e.g. "vitality * 10 + strength * 2"
+	Expression string `json:"expression"`
+
+	// DependsOn are the dimensions referenced by the expression
+	DependsOn []string `json:"depends_on,omitempty"`
 }
 
 // MergeRule represents a rule for merging contributions
@@ -73,6 +259,16 @@ type MergeRule struct {
 
 	// ClampDefault is the default clamp range
 	ClampDefault Caps `json:"clamp_default"`
+
+	// BucketOrder is the order the pipeline applies buckets in. Buckets it
+	// leaves out are applied afterwards in enums.DefaultBucketOrder.
+	BucketOrder []enums.Bucket `json:"bucket_order,omitempty"`
+
+	// LogBase is the base of the LOGARITHMIC bucket, e if zero
+	LogBase float64 `json:"log_base,omitempty"`
+
+	// SoftCap tapers the merged value before hard caps apply, if set
+	SoftCap *SoftCap `json:"soft_cap,omitempty"`
 }
 
 // IsValid checks if the merge rule is valid
@@ -83,9 +279,51 @@ func (mr *MergeRule) IsValid() bool {
 		return false
 	}
 
+	seen := make(map[enums.Bucket]bool, len(mr.BucketOrder))
+	for _, bucket := range mr.BucketOrder {
+		if !bucket.IsValid() || seen[bucket] {
+			return false
+		}
+		seen[bucket] = true
+	}
+
+	if mr.LogBase < 0 || mr.LogBase == 1 {
+		return false
+	}
+
+	if mr.SoftCap != nil && mr.SoftCap.Validate() != nil {
+		return false
+	}
+
 	return true
 }
 
+// GetBucketOrder returns the complete order the pipeline applies buckets in
+func (mr *MergeRule) GetBucketOrder() []enums.Bucket {
+	order := make([]enums.Bucket, 0, len(enums.DefaultBucketOrder()))
+	listed := make(map[enums.Bucket]bool, len(mr.BucketOrder))
+	for _, bucket := range mr.BucketOrder {
+		order = append(order, bucket)
+		listed[bucket] = true
+	}
+
+	for _, bucket := range enums.DefaultBucketOrder() {
+		if !listed[bucket] {
+			order = append(order, bucket)
+		}
+	}
+
+	return order
+}
+
+// GetLogBase returns the base of the LOGARITHMIC bucket
+func (mr *MergeRule) GetLogBase() float64 {
+	if mr.LogBase == 0 {
+		return math.E
+	}
+	return mr.LogBase
+}
+
 // GetDefaultClampRange returns the default clamp range
 func (mr *MergeRule) GetDefaultClampRange() Caps {
 	return mr.ClampDefault
@@ -103,10 +341,12 @@ func (mr *MergeRule) GetOperator() string {
 
 // PluginRegistry represents a registry for subsystems
 type PluginRegistry interface {
-	// Register registers a subsystem
+	// Register registers a subsystem, initializing LifecycleSubsystems.
+	// Subsystems whose ordering constraints form a cycle are rejected, as are
+	// subsystems outside the supported API-level range without a shim.
 	Register(subsystem Subsystem) error
 
-	// Unregister unregisters a subsystem
+	// Unregister unregisters a subsystem, shutting down LifecycleSubsystems
 	Unregister(systemID string) error
 
 	// Get returns a subsystem by ID
@@ -115,7 +355,8 @@ type PluginRegistry interface {
 	// GetAll returns all registered subsystems
 	GetAll() []Subsystem
 
-	// GetByPriority returns subsystems ordered by priority
+	// GetByPriority returns subsystems in dependency order, higher priority
+	// first where unconstrained, ties by system ID
 	GetByPriority() []Subsystem
 
 	// Clear clears all registered subsystems
@@ -132,6 +373,42 @@ type PluginRegistry interface {
 
 	// IsEmpty checks if the registry is empty
 	IsEmpty() bool
+
+	// GetGeneration returns the generation, bumped on every register and unregister
+	GetGeneration() int64
+
+	// Close unregisters all subsystems, shutting down LifecycleSubsystems
+	Close() error
+
+	// GetConflicts returns the OVERRIDE conflicts between registered subsystems
+	GetConflicts() []PluginConflict
+
+	// SetAPILevelRange sets the API levels accepted by Register
+	SetAPILevelRange(minLevel, maxLevel int64) error
+
+	// GetAPILevelRange returns the API levels accepted by Register
+	GetAPILevelRange() (int64, int64)
+
+	// RegisterShim registers a shim for subsystems built against an API level
+	// outside the supported range
+	RegisterShim(apiLevel int64, shim SubsystemShim) error
+}
+
+// SubsystemShim adapts a subsystem built against an unsupported API level to
+// one the registry supports. The adapted subsystem must keep the system ID.
+type SubsystemShim func(subsystem Subsystem) (Subsystem, error)
+
+// PluginConflict reports subsystems that write OVERRIDE to the same dimension
+// at the same priority, so only the registry order decides which one wins
+type PluginConflict struct {
+	// Dimension is the contested dimension
+	Dimension string
+
+	// Priority is the priority the subsystems share
+	Priority int64
+
+	// Systems are the conflicting system IDs, sorted
+	Systems []string
 }
 
 // ConfigLoader represents a configuration loader
@@ -0,0 +1,114 @@
+package interfaces
+
+import (
+	"chaos-actor-module/packages/actor-core/enums"
+	"fmt"
+	"math"
+)
+
+// SoftCap tapers a dimension's merged value so that stacking has diminishing
+// returns. It is applied after bucket aggregation and before hard caps.
+type SoftCap struct {
+	// Curve is the soft cap curve
+	Curve enums.SoftCapCurve `json:"curve"`
+
+	// K is the HYPERBOLIC half point: the value that maps to Scale / 2
+	K float64 `json:"k,omitempty"`
+
+	// Scale is the HYPERBOLIC asymptote (1 if zero), or the most
+	// EXPONENTIAL_DECAY lets a value exceed Threshold by
+	Scale float64 `json:"scale,omitempty"`
+
+	// Threshold is the value above which EXPONENTIAL_DECAY applies
+	Threshold float64 `json:"threshold,omitempty"`
+
+	// Knees are the PIECEWISE_LINEAR knees in ascending order
+	Knees []SoftCapKnee `json:"knees,omitempty"`
+}
+
+// SoftCapKnee sets the slope of a piecewise-linear soft cap above a value
+type SoftCapKnee struct {
+	// At is the value the knee starts at
+	At float64 `json:"at"`
+
+	// Slope is the rate values above At increase at, up to the next knee
+	Slope float64 `json:"slope"`
+}
+
+// Validate checks that the soft cap's parameters suit its curve
+func (sc *SoftCap) Validate() error {
+	switch sc.Curve {
+	case enums.SoftCapHyperbolic:
+		if sc.K <= 0 {
+			return fmt.Errorf("%s soft cap: k must be positive", sc.Curve)
+		}
+		if sc.Scale < 0 {
+			return fmt.Errorf("%s soft cap: scale cannot be negative", sc.Curve)
+		}
+
+	case enums.SoftCapPiecewiseLinear:
+		if len(sc.Knees) == 0 {
+			return fmt.Errorf("%s soft cap: at least one knee is required", sc.Curve)
+		}
+		for i, knee := range sc.Knees {
+			if knee.Slope < 0 {
+				return fmt.Errorf("%s soft cap: knee at %v has a negative slope", sc.Curve, knee.At)
+			}
+			if i > 0 && knee.At <= sc.Knees[i-1].At {
+				return fmt.Errorf("%s soft cap: knees must be in ascending order", sc.Curve)
+			}
+		}
+
+	case enums.SoftCapExponentialDecay:
+		if sc.Scale <= 0 {
+			return fmt.Errorf("%s soft cap: scale must be positive", sc.Curve)
+		}
+
+	default:
+		return fmt.Errorf("invalid soft cap curve: %s", sc.Curve)
+	}
+
+	return nil
+}
+
+// Apply tapers a value along the soft cap curve. Values the curve does not
+// reach (non-positive values for HYPERBOLIC, values up to the first knee or
+// the threshold otherwise) are returned unchanged.
+func (sc *SoftCap) Apply(value float64) float64 {
+	switch sc.Curve {
+	case enums.SoftCapHyperbolic:
+		if value <= 0 {
+			return value
+		}
+		scale := sc.Scale
+		if scale == 0 {
+			scale = 1
+		}
+		return scale * value / (value + sc.K)
+
+	case enums.SoftCapPiecewiseLinear:
+		if len(sc.Knees) == 0 || value <= sc.Knees[0].At {
+			return value
+		}
+		result := sc.Knees[0].At
+		for i, knee := range sc.Knees {
+			upper := value
+			if i+1 < len(sc.Knees) && sc.Knees[i+1].At < value {
+				upper = sc.Knees[i+1].At
+			}
+			if upper <= knee.At {
+				break
+			}
+			result += (upper - knee.At) * knee.Slope
+		}
+		return result
+
+	case enums.SoftCapExponentialDecay:
+		if value <= sc.Threshold || sc.Scale <= 0 {
+			return value
+		}
+		return sc.Threshold + sc.Scale*(1-math.Exp(-(value-sc.Threshold)/sc.Scale))
+	}
+
+	return value
+}
@@ -1,6 +1,7 @@
 package interfaces
 
 import (
+	"chaos-actor-module/packages/actor-core/enums"
 	"context"
 	"time"
 )
@@ -29,7 +30,10 @@ type ValidatingSubsystem interface {
 	Validate(actor *Actor) error
 }
 
-// CachingSubsystem represents a subsystem that supports caching
+// CachingSubsystem represents a subsystem that supports caching. The aggregator
+// reuses its last output for an actor, until the output expires with the cache
+// TTL, while ShouldCache is true, GetCacheKey and the actor version are
+// unchanged and the subsystem has not been marked dirty.
 type CachingSubsystem interface {
 	// GetCacheKey returns the cache key for the given actor
 	GetCacheKey(actor *Actor) string
@@ -74,7 +78,62 @@ type ConditionalSubsystem interface {
 	IsActive(actor *Actor) bool
 }
 
-// PerformanceSubsystem represents a subsystem that provides performance metrics
+// TimeoutSubsystem represents a subsystem with its own contribution deadline
+type TimeoutSubsystem interface {
+	// Timeout returns the maximum duration of a Contribute call, or zero for no limit
+	Timeout() time.Duration
+}
+
+// BatchSubsystem represents a subsystem that can contribute for many actors in one call
+type BatchSubsystem interface {
+	// ContributeBatch returns the outputs for the given actors, aligned with actors
+	ContributeBatch(ctx context.Context, actors []*Actor) ([]*SubsystemOutput, error)
+}
+
+// VersionedSubsystem represents a subsystem that declares the API level it
+// was built against. Subsystems that do not implement it are assumed to be
+// built against constants.APICurrent.
+type VersionedSubsystem interface {
+	// APILevel returns the API level the subsystem implements
+	APILevel() int64
+}
+
+// DependentSubsystem represents a subsystem that must run in a given order
+// relative to other subsystems. The constraints only order subsystems: those
+// naming subsystems that are not registered are ignored when ordering, and a
+// subsystem may be registered before the subsystems it depends on.
+type DependentSubsystem interface {
+	// DependsOn returns the system IDs this subsystem requires, which run
+	// before it. Missing ones are reported by the registry's Validate and
+	// rejected when a registry is built from a plugin manifest.
+	DependsOn() []string
+
+	// Before returns the system IDs this subsystem runs before
+	Before() []string
+
+	// After returns the system IDs this subsystem runs after
+	After() []string
+}
+
+// DimensionWriter represents a subsystem that declares the dimensions it writes
+type DimensionWriter interface {
+	// WritesDimensions returns the dimensions and buckets this subsystem contributes to
+	WritesDimensions() []DimensionWrite
+}
+
+// DimensionWrite is a dimension and the bucket a subsystem contributes to it with
+type DimensionWrite struct {
+	// Dimension is the dimension name
+	Dimension string
+
+	// Bucket is the contribution bucket
+	Bucket enums.Bucket
+}
+
+// PerformanceSubsystem represents a subsystem that provides performance metrics.
+// The aggregator records calls, latency and errors itself and merges in what
+// GetMetrics reports: its MemoryUsage, and its CacheHits and CacheMisses added
+// to the aggregator's reuse counts.
 type PerformanceSubsystem interface {
 	// GetMetrics returns performance metrics for this subsystem
 	GetMetrics() *SubsystemMetrics
@@ -85,6 +144,12 @@ type SubsystemMetrics struct {
 	// ProcessingTime is the total processing time
 	ProcessingTime time.Duration
 
+	// Calls is the number of Contribute calls
+	Calls int64
+
+	// Latency is the distribution of Contribute call durations
+	Latency *LatencyHistogram
+
 	// CacheHits is the number of cache hits
 	CacheHits int64
 
@@ -110,11 +175,143 @@ func (sm *SubsystemMetrics) GetCacheHitRate() float64 {
 	return float64(sm.CacheHits) / float64(total)
 }
 
-// GetErrorRate returns the error rate
+// GetErrorRate returns the share of Contribute calls that failed
 func (sm *SubsystemMetrics) GetErrorRate() float64 {
-	total := sm.CacheHits + sm.CacheMisses
-	if total == 0 {
+	if sm.Calls == 0 {
 		return 0.0
 	}
-	return float64(sm.Errors) / float64(total)
+	return float64(sm.Errors) / float64(sm.Calls)
+}
+
+// GetAverageLatency returns the average Contribute call duration
+func (sm *SubsystemMetrics) GetAverageLatency() time.Duration {
+	if sm.Calls == 0 {
+		return 0
+	}
+	return sm.ProcessingTime / time.Duration(sm.Calls)
+}
+
+// Clone returns a deep copy of the metrics
+func (sm *SubsystemMetrics) Clone() *SubsystemMetrics {
+	clone := *sm
+	if sm.Latency != nil {
+		clone.Latency = sm.Latency.Clone()
+	}
+	return &clone
+}
+
+// DefaultLatencyBounds are the default upper bounds of latency histogram buckets
+var DefaultLatencyBounds = []time.Duration{
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// LatencyHistogram is a bucketed histogram of durations
+type LatencyHistogram struct {
+	// Bounds are the inclusive upper bounds of the buckets, in ascending order
+	Bounds []time.Duration `json:"bounds"`
+
+	// Counts are the observations per bucket; the last entry counts
+	// observations above the highest bound
+	Counts []int64 `json:"counts"`
+
+	// Count is the total number of observations
+	Count int64 `json:"count"`
+
+	// Sum is the sum of all observations
+	Sum time.Duration `json:"sum"`
+
+	// Min is the smallest observation
+	Min time.Duration `json:"min"`
+
+	// Max is the largest observation
+	Max time.Duration `json:"max"`
+}
+
+// NewLatencyHistogram creates a histogram with the given bucket bounds, or
+// DefaultLatencyBounds if none are given
+func NewLatencyHistogram(bounds ...time.Duration) *LatencyHistogram {
+	if len(bounds) == 0 {
+		bounds = DefaultLatencyBounds
+	}
+
+	copied := make([]time.Duration, len(bounds))
+	copy(copied, bounds)
+
+	return &LatencyHistogram{
+		Bounds: copied,
+		Counts: make([]int64, len(bounds)+1),
+	}
+}
+
+// Observe records a duration
+func (lh *LatencyHistogram) Observe(duration time.Duration) {
+	bucket := len(lh.Bounds)
+	for i, bound := range lh.Bounds {
+		if duration <= bound {
+			bucket = i
+			break
+		}
+	}
+	lh.Counts[bucket]++
+
+	if lh.Count == 0 || duration < lh.Min {
+		lh.Min = duration
+	}
+	if duration > lh.Max {
+		lh.Max = duration
+	}
+	lh.Count++
+	lh.Sum += duration
+}
+
+// Mean returns the mean observation
+func (lh *LatencyHistogram) Mean() time.Duration {
+	if lh.Count == 0 {
+		return 0
+	}
+	return lh.Sum / time.Duration(lh.Count)
+}
+
+// Percentile returns the upper bound of the bucket containing the given
+// percentile (0-100), or Max if it falls above the highest bound
+func (lh *LatencyHistogram) Percentile(percentile float64) time.Duration {
+	if lh.Count == 0 {
+		return 0
+	}
+
+	rank := int64(float64(lh.Count) * percentile / 100.0)
+	if rank < 1 {
+		rank = 1
+	}
+
+	var seen int64
+	for i, count := range lh.Counts {
+		seen += count
+		if seen >= rank {
+			if i < len(lh.Bounds) && lh.Bounds[i] < lh.Max {
+				return lh.Bounds[i]
+			}
+			return lh.Max
+		}
+	}
+
+	return lh.Max
+}
+
+// Clone returns a deep copy of the histogram
+func (lh *LatencyHistogram) Clone() *LatencyHistogram {
+	clone := *lh
+	clone.Bounds = make([]time.Duration, len(lh.Bounds))
+	copy(clone.Bounds, lh.Bounds)
+	clone.Counts = make([]int64, len(lh.Counts))
+	copy(clone.Counts, lh.Counts)
+	return &clone
 }
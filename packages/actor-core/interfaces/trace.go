@@ -0,0 +1,113 @@
+package interfaces
+
+import "time"
+
+// AggregationTrace explains how a single dimension was resolved for an actor
+type AggregationTrace struct {
+	// ActorID is the actor the trace was produced for
+	ActorID string `json:"actor_id"`
+
+	// Dimension is the explained dimension
+	Dimension string `json:"dimension"`
+
+	// Kind is "primary" or "derived"
+	Kind string `json:"kind"`
+
+	// Rule is the merge rule used for the dimension
+	Rule *MergeRule `json:"rule,omitempty"`
+
+	// Contributions are the contributions in processing order
+	Contributions []ContributionTrace `json:"contributions"`
+
+	// Conditions are the outcomes of the CONDITIONAL contribution predicates.
+	// Contributions whose condition did not hold are not in Contributions.
+	Conditions []ConditionTrace `json:"conditions,omitempty"`
+
+	// Steps are the intermediate values after each bucket (or the operator fold)
+	Steps []AggregationStep `json:"steps"`
+
+	// Candidate is the merged value after any soft cap, before any clamp
+	Candidate float64 `json:"candidate"`
+
+	// LayerCaps are the caps produced within each layer, in layer order
+	LayerCaps []LayerCapsTrace `json:"layer_caps,omitempty"`
+
+	// AcrossLayerPolicy is the policy used to combine the dimension's layer caps
+	AcrossLayerPolicy string `json:"across_layer_policy,omitempty"`
+
+	// EffectiveCaps is the across-layer result, if any layer capped the dimension
+	EffectiveCaps *Caps `json:"effective_caps,omitempty"`
+
+	// Clamp is the range the candidate was clamped to
+	Clamp *Caps `json:"clamp,omitempty"`
+
+	// ClampSource is "effective_caps", "clamp_default" or "none"
+	ClampSource string `json:"clamp_source"`
+
+	// Final is the value written to the snapshot
+	Final float64 `json:"final"`
+
+	// CreatedAt is when the trace was produced
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ContributionTrace describes one contribution to the explained dimension
+type ContributionTrace struct {
+	// System is the contributing system ID
+	System string `json:"system"`
+
+	// Bucket is the contribution bucket
+	Bucket string `json:"bucket"`
+
+	// Priority is the contribution priority
+	Priority int64 `json:"priority"`
+
+	// Value is the contribution value
+	Value float64 `json:"value"`
+}
+
+// ConditionTrace records the evaluation of a CONDITIONAL contribution's predicate
+type ConditionTrace struct {
+	// System is the contributing system ID
+	System string `json:"system"`
+
+	// Condition is the predicate source
+	Condition string `json:"condition"`
+
+	// Value is the contribution value
+	Value float64 `json:"value"`
+
+	// Met is true if the condition held and the contribution was applied
+	Met bool `json:"met"`
+}
+
+// AggregationStep records the running value after a bucket or operator step
+type AggregationStep struct {
+	// Stage is the bucket name, or the operator name in operator mode
+	Stage string `json:"stage"`
+
+	// Value is the running value after the stage
+	Value float64 `json:"value"`
+}
+
+// LayerCapsTrace records the caps produced within a single layer
+type LayerCapsTrace struct {
+	// Layer is the layer name
+	Layer string `json:"layer"`
+
+	// Caps are the within-layer caps for the dimension
+	Caps Caps `json:"caps"`
+}
+
+// IsClamped checks if the clamp changed the candidate value
+func (at *AggregationTrace) IsClamped() bool {
+	return at.Candidate != at.Final
+}
+
+// AddStep records an intermediate value; it is a no-op on a nil trace
+func (at *AggregationTrace) AddStep(stage string, value float64) {
+	if at == nil {
+		return
+	}
+	at.Steps = append(at.Steps, AggregationStep{Stage: stage, Value: value})
+}
@@ -1,64 +1,33 @@
 package interfaces
 
-import "time"
+import (
+	"chaos-actor-module/packages/actor-core/types"
+)
 
-// Forward declarations to avoid circular imports
-// These will be implemented in the types package
+// The data model is defined once in the types package. The aliases below let
+// the Aggregator, CapsProvider and Subsystem interfaces use it directly, so
+// values flow between the packages without conversion.
 
-type Actor struct {
-	ID      string
-	Version int64
-}
+// Actor represents an actor in the system
+type Actor = types.Actor
 
-type SubsystemOutput struct {
-	Primary []Contribution
-	Derived []Contribution
-	Caps    []CapContribution
-	Context map[string]ModifierPack
-	Meta    SubsystemMeta
-}
+// SubsystemOutput represents the output from a subsystem
+type SubsystemOutput = types.SubsystemOutput
 
-type Snapshot struct {
-	ActorID   string
-	Primary   map[string]float64
-	Derived   map[string]float64
-	CapsUsed  map[string]Caps
-	Version   int64
-	CreatedAt time.Time
-}
+// Snapshot represents the final aggregated snapshot of an actor's stats
+type Snapshot = types.Snapshot
 
-// Caps is defined in caps_provider.go
-type Contribution struct {
-	Dimension string
-	Bucket    string
-	Value     float64
-	System    string
-	Priority  int64
-}
+// SubsystemFailure records a subsystem that failed while resolving a snapshot
+type SubsystemFailure = types.SubsystemFailure
 
-type CapContribution struct {
-	System    string
-	Dimension string
-	Mode      string
-	Kind      string
-	Value     float64
-	Priority  int64
-	Scope     string
-	Realm     string
-	Tags      []string
-}
+// Contribution represents a contribution to a dimension
+type Contribution = types.Contribution
 
-type SubsystemMeta struct {
-	System     string
-	Version    int64
-	APILevel   int64
-	Compatible bool
-}
+// CapContribution represents a cap contribution
+type CapContribution = types.CapContribution
 
-type ModifierPack struct {
-	AdditivePercent float64
-	Multipliers     []float64
-	PostAdd         float64
-}
+// SubsystemMeta represents subsystem metadata
+type SubsystemMeta = types.SubsystemMeta
 
-// EffectiveCaps will be defined in caps_provider.go
+// ModifierPack represents a context modifier pack
+type ModifierPack = types.ModifierPack
@@ -0,0 +1,71 @@
+package registry
+
+import (
+	"chaos-actor-module/packages/actor-core/enums"
+	"chaos-actor-module/packages/actor-core/interfaces"
+)
+
+// builtinPolicies returns the across-layer policies every layer registry starts with
+func builtinPolicies() map[string]interfaces.AcrossLayerPolicy {
+	return map[string]interfaces.AcrossLayerPolicy{
+		enums.AcrossLayerIntersect.String():           intersectPolicy{},
+		enums.AcrossLayerUnion.String():               unionPolicy{},
+		enums.AcrossLayerPrioritizedOverride.String(): prioritizedOverridePolicy{},
+	}
+}
+
+// intersectPolicy keeps the range every layer allows. If the layers do not
+// overlap, the result collapses to the lowest maximum.
+type intersectPolicy struct{}
+
+// Name returns the policy name
+func (intersectPolicy) Name() string {
+	return enums.AcrossLayerIntersect.String()
+}
+
+// Combine intersects the layer caps
+func (intersectPolicy) Combine(dimension string, layers []interfaces.LayerCaps) (interfaces.Caps, error) {
+	result := layers[0].Caps
+	for _, layer := range layers[1:] {
+		result = result.Intersect(layer.Caps)
+	}
+
+	if result.Min > result.Max {
+		result.Min = result.Max
+	}
+
+	return result, nil
+}
+
+// unionPolicy keeps the range any layer allows, so a layer can raise the caps
+// of another
+type unionPolicy struct{}
+
+// Name returns the policy name
+func (unionPolicy) Name() string {
+	return enums.AcrossLayerUnion.String()
+}
+
+// Combine unions the layer caps
+func (unionPolicy) Combine(dimension string, layers []interfaces.LayerCaps) (interfaces.Caps, error) {
+	result := layers[0].Caps
+	for _, layer := range layers[1:] {
+		result = result.Union(layer.Caps)
+	}
+
+	return result, nil
+}
+
+// prioritizedOverridePolicy keeps the caps of the first layer in order that
+// caps the dimension, ignoring later layers
+type prioritizedOverridePolicy struct{}
+
+// Name returns the policy name
+func (prioritizedOverridePolicy) Name() string {
+	return enums.AcrossLayerPrioritizedOverride.String()
+}
+
+// Combine returns the first layer's caps
+func (prioritizedOverridePolicy) Combine(dimension string, layers []interfaces.LayerCaps) (interfaces.Caps, error) {
+	return layers[0].Caps, nil
+}
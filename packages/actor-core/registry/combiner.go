@@ -2,6 +2,7 @@ package registry
 
 import (
 	"chaos-actor-module/packages/actor-core/constants"
+	"chaos-actor-module/packages/actor-core/enums"
 	"chaos-actor-module/packages/actor-core/interfaces"
 	"encoding/json"
 	"fmt"
@@ -12,23 +13,26 @@ import (
 
 // CombinerRegistryImpl implements the CombinerRegistry interface
 type CombinerRegistryImpl struct {
-	rules    map[string]*interfaces.MergeRule
-	mu       sync.RWMutex
-	filePath string
+	rules      map[string]*interfaces.MergeRule
+	mu         sync.RWMutex
+	filePath   string
+	generation int64
 }
 
 // NewCombinerRegistry creates a new combiner registry
 func NewCombinerRegistry() interfaces.CombinerRegistry {
 	return &CombinerRegistryImpl{
-		rules: make(map[string]*interfaces.MergeRule),
+		rules:      make(map[string]*interfaces.MergeRule),
+		generation: NextGeneration(),
 	}
 }
 
 // NewCombinerRegistryFromFile creates a new combiner registry from a file
 func NewCombinerRegistryFromFile(filePath string) (interfaces.CombinerRegistry, error) {
 	registry := &CombinerRegistryImpl{
-		rules:    make(map[string]*interfaces.MergeRule),
-		filePath: filePath,
+		rules:      make(map[string]*interfaces.MergeRule),
+		filePath:   filePath,
+		generation: NextGeneration(),
 	}
 	
 	if err := registry.LoadFromFile(filePath); err != nil {
@@ -66,6 +70,7 @@ func (cr *CombinerRegistryImpl) SetRule(dimension string, rule *interfaces.Merge
 	}
 	
 	cr.rules[dimension] = rule
+	cr.generation = NextGeneration()
 	return nil
 }
 
@@ -101,6 +106,7 @@ func (cr *CombinerRegistryImpl) LoadFromConfig(config map[string]interface{}) er
 		cr.rules[dimension] = rule
 	}
 	
+	cr.generation = NextGeneration()
 	return nil
 }
 
@@ -172,6 +178,7 @@ func (cr *CombinerRegistryImpl) Clear() {
 	defer cr.mu.Unlock()
 	
 	cr.rules = make(map[string]*interfaces.MergeRule)
+	cr.generation = NextGeneration()
 }
 
 // Count returns the number of rules
@@ -197,6 +204,15 @@ func (cr *CombinerRegistryImpl) RemoveRule(dimension string) {
 	defer cr.mu.Unlock()
 	
 	delete(cr.rules, dimension)
+	cr.generation = NextGeneration()
+}
+
+// GetGeneration returns the generation, bumped on every rule change
+func (cr *CombinerRegistryImpl) GetGeneration() int64 {
+	cr.mu.RLock()
+	defer cr.mu.RUnlock()
+	
+	return cr.generation
 }
 
 // GetDefaultRule returns the default rule for a dimension
@@ -306,6 +322,59 @@ func (cr *CombinerRegistryImpl) parseRule(data interface{}) (*interfaces.MergeRu
 		}
 	}
 	
+	// Parse BucketOrder
+	if bucketOrder, exists := ruleMap["bucket_order"]; exists {
+		buckets, ok := bucketOrder.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("bucket_order must be a list")
+		}
+		
+		for _, bucket := range buckets {
+			name, ok := bucket.(string)
+			if !ok {
+				return nil, fmt.Errorf("bucket_order entries must be strings")
+			}
+			if !enums.Bucket(name).IsValid() {
+				return nil, fmt.Errorf("bucket_order: invalid bucket %s", name)
+			}
+			for _, listed := range rule.BucketOrder {
+				if listed == enums.Bucket(name) {
+					return nil, fmt.Errorf("bucket_order: bucket %s listed twice", name)
+				}
+			}
+			rule.BucketOrder = append(rule.BucketOrder, enums.Bucket(name))
+		}
+	}
+	
+	// Parse LogBase
+	if logBase, exists := ruleMap["log_base"]; exists {
+		if lb, ok := logBase.(float64); ok {
+			rule.LogBase = lb
+		} else {
+			return nil, fmt.Errorf("log_base must be a number")
+		}
+		if rule.LogBase <= 0 || rule.LogBase == 1 {
+			return nil, fmt.Errorf("log_base must be positive and not 1")
+		}
+	}
+	
+	// Parse SoftCap
+	if softCap, exists := ruleMap["soft_cap"]; exists {
+		data, err := json.Marshal(softCap)
+		if err != nil {
+			return nil, fmt.Errorf("soft_cap: %w", err)
+		}
+		
+		rule.SoftCap = &interfaces.SoftCap{}
+		if err := json.Unmarshal(data, rule.SoftCap); err != nil {
+			return nil, fmt.Errorf("soft_cap must be a map: %w", err)
+		}
+		
+		if err := rule.SoftCap.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	
 	// Parse ClampDefault
 	if clampDefault, exists := ruleMap["clamp_default"]; exists {
 		clampMap, ok := clampDefault.(map[string]interface{})
@@ -7,6 +7,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"gopkg.in/yaml.v3"
 )
 
 // ConfigLoaderImpl implements the ConfigLoader interface
@@ -43,23 +45,22 @@ func (cl *ConfigLoaderImpl) Load(filename string) (map[string]interface{}, error
 	}
 
 	// Determine format from file extension
-	ext := strings.ToLower(filepath.Ext(filename))
-	if ext != "" {
-		ext = ext[1:] // Remove the dot
-	}
-
-	return cl.LoadFromBytes(data)
+	return cl.LoadFromBytesWithFormat(data, cl.GetFileFormat(filename))
 }
 
-// LoadFromBytes loads configuration from bytes
+// LoadFromBytes loads JSON configuration from bytes
 func (cl *ConfigLoaderImpl) LoadFromBytes(data []byte) (map[string]interface{}, error) {
+	return cl.LoadFromBytesWithFormat(data, "json")
+}
+
+// LoadFromBytesWithFormat loads configuration in the given format from bytes.
+// YAML values are normalized to the types JSON decoding produces, so callers
+// see float64 numbers and map[string]interface{} objects for both formats.
+func (cl *ConfigLoaderImpl) LoadFromBytesWithFormat(data []byte, format string) (map[string]interface{}, error) {
 	if len(data) == 0 {
 		return nil, fmt.Errorf("data cannot be empty")
 	}
 
-	// Default to JSON format
-	format := "json"
-
 	format = strings.ToLower(format)
 	if !cl.supportedFormats[format] {
 		return nil, fmt.Errorf("unsupported format: %s", format)
@@ -73,10 +74,18 @@ func (cl *ConfigLoaderImpl) LoadFromBytes(data []byte) (map[string]interface{},
 			return nil, fmt.Errorf("failed to parse JSON: %w", err)
 		}
 	case "yaml", "yml":
-		// For now, we'll treat YAML as JSON since we don't have YAML parser
-		// In a real implementation, you would use gopkg.in/yaml.v3
-		if err := json.Unmarshal(data, &config); err != nil {
-			return nil, fmt.Errorf("failed to parse YAML (using JSON parser): %w", err)
+		var raw interface{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
+		}
+
+		normalized, err := json.Marshal(raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to normalize YAML: %w", err)
+		}
+
+		if err := json.Unmarshal(normalized, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse YAML: %w", err)
 		}
 	default:
 		return nil, fmt.Errorf("unsupported format: %s", format)
@@ -120,11 +129,9 @@ func (cl *ConfigLoaderImpl) Save(filename string, config map[string]interface{})
 			return fmt.Errorf("failed to marshal JSON: %w", err)
 		}
 	case "yaml", "yml":
-		// For now, we'll save as JSON since we don't have YAML marshaler
-		// In a real implementation, you would use gopkg.in/yaml.v3
-		data, err = json.MarshalIndent(config, "", "  ")
+		data, err = yaml.Marshal(config)
 		if err != nil {
-			return fmt.Errorf("failed to marshal YAML (using JSON marshaler): %w", err)
+			return fmt.Errorf("failed to marshal YAML: %w", err)
 		}
 	default:
 		return fmt.Errorf("unsupported format: %s", format)
@@ -196,7 +203,7 @@ func (cl *ConfigLoaderImpl) LoadFromFileWithFormat(filename, format string) (map
 		return nil, fmt.Errorf("failed to read file %s: %w", filename, err)
 	}
 
-	return cl.LoadFromBytes(data)
+	return cl.LoadFromBytesWithFormat(data, format)
 }
 
 // SaveToFileWithFormat saves configuration to a file with a specific format
@@ -238,10 +245,9 @@ func (cl *ConfigLoaderImpl) SaveToFileWithFormat(filename string, config map[str
 			return fmt.Errorf("failed to marshal JSON: %w", err)
 		}
 	case "yaml", "yml":
-		// For now, we'll save as JSON since we don't have YAML marshaler
-		data, err = json.MarshalIndent(config, "", "  ")
+		data, err = yaml.Marshal(config)
 		if err != nil {
-			return fmt.Errorf("failed to marshal YAML (using JSON marshaler): %w", err)
+			return fmt.Errorf("failed to marshal YAML: %w", err)
 		}
 	default:
 		return fmt.Errorf("unsupported format: %s", format)
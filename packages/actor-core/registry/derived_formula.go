@@ -0,0 +1,350 @@
+package registry
+
+import (
+	"chaos-actor-module/packages/actor-core/expression"
+	"chaos-actor-module/packages/actor-core/interfaces"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+)
+
+// compiledFormula holds a formula together with its parsed expression
+type compiledFormula struct {
+	formula *interfaces.DerivedFormula
+	expr    *expression.Expression
+}
+
+// DerivedFormulaRegistryImpl implements the DerivedFormulaRegistry interface
+type DerivedFormulaRegistryImpl struct {
+	formulas   map[string]*compiledFormula
+	mu         sync.RWMutex
+	filePath   string
+	generation int64
+}
+
+// NewDerivedFormulaRegistry creates a new derived formula registry
+func NewDerivedFormulaRegistry() interfaces.DerivedFormulaRegistry {
+	return &DerivedFormulaRegistryImpl{
+		formulas:   make(map[string]*compiledFormula),
+		generation: NextGeneration(),
+	}
+}
+
+// NewDerivedFormulaRegistryFromFile creates a new derived formula registry from a file
+func NewDerivedFormulaRegistryFromFile(filePath string) (interfaces.DerivedFormulaRegistry, error) {
+	registry := &DerivedFormulaRegistryImpl{
+		formulas:   make(map[string]*compiledFormula),
+		filePath:   filePath,
+		generation: NextGeneration(),
+	}
+
+	if err := registry.LoadFromFile(filePath); err != nil {
+		return nil, fmt.Errorf("failed to load derived formula registry from file: %w", err)
+	}
+
+	return registry, nil
+}
+
+// GetFormula returns the formula for the given derived dimension
+func (dfr *DerivedFormulaRegistryImpl) GetFormula(dimension string) (*interfaces.DerivedFormula, bool) {
+	dfr.mu.RLock()
+	defer dfr.mu.RUnlock()
+
+	compiled, exists := dfr.formulas[dimension]
+	if !exists {
+		return nil, false
+	}
+
+	return compiled.formula, true
+}
+
+// SetFormula parses and sets the formula expression for the given derived dimension
+func (dfr *DerivedFormulaRegistryImpl) SetFormula(dimension string, source string) error {
+	dfr.mu.Lock()
+	defer dfr.mu.Unlock()
+
+	compiled, err := compileFormula(dimension, source)
+	if err != nil {
+		return err
+	}
+
+	formulas := make(map[string]*compiledFormula, len(dfr.formulas)+1)
+	for dim, existing := range dfr.formulas {
+		formulas[dim] = existing
+	}
+	formulas[dimension] = compiled
+
+	if _, err := evaluationOrder(formulas); err != nil {
+		return err
+	}
+
+	dfr.formulas = formulas
+	dfr.generation = NextGeneration()
+	return nil
+}
+
+// RemoveFormula removes the formula for the given dimension
+func (dfr *DerivedFormulaRegistryImpl) RemoveFormula(dimension string) {
+	dfr.mu.Lock()
+	defer dfr.mu.Unlock()
+
+	delete(dfr.formulas, dimension)
+	dfr.generation = NextGeneration()
+}
+
+// GetDimensions returns all dimensions with formulas
+func (dfr *DerivedFormulaRegistryImpl) GetDimensions() []string {
+	dfr.mu.RLock()
+	defer dfr.mu.RUnlock()
+
+	dimensions := make([]string, 0, len(dfr.formulas))
+	for dimension := range dfr.formulas {
+		dimensions = append(dimensions, dimension)
+	}
+
+	sort.Strings(dimensions)
+	return dimensions
+}
+
+// GetEvaluationOrder returns formula dimensions ordered so that dependencies come first
+func (dfr *DerivedFormulaRegistryImpl) GetEvaluationOrder() ([]string, error) {
+	dfr.mu.RLock()
+	defer dfr.mu.RUnlock()
+
+	return evaluationOrder(dfr.formulas)
+}
+
+// Evaluate evaluates the formula for the given dimension against the given values.
+// Dependencies missing from values are treated as zero.
+func (dfr *DerivedFormulaRegistryImpl) Evaluate(dimension string, values map[string]float64) (float64, error) {
+	dfr.mu.RLock()
+	compiled, exists := dfr.formulas[dimension]
+	dfr.mu.RUnlock()
+
+	if !exists {
+		return 0.0, fmt.Errorf("no formula for dimension %s", dimension)
+	}
+
+	variables := make(map[string]float64, len(compiled.formula.DependsOn))
+	for _, dependency := range compiled.formula.DependsOn {
+		variables[dependency] = values[dependency]
+	}
+
+	return compiled.expr.Evaluate(variables)
+}
+
+// LoadFromConfig loads formulas from configuration
+func (dfr *DerivedFormulaRegistryImpl) LoadFromConfig(config map[string]interface{}) error {
+	dfr.mu.Lock()
+	defer dfr.mu.Unlock()
+
+	formulasData, ok := config["formulas"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid config: formulas not found or not a map")
+	}
+
+	formulas := make(map[string]*compiledFormula, len(dfr.formulas)+len(formulasData))
+	for dim, existing := range dfr.formulas {
+		formulas[dim] = existing
+	}
+
+	for dimension, formulaData := range formulasData {
+		var source string
+
+		switch data := formulaData.(type) {
+		case string:
+			source = data
+		case map[string]interface{}:
+			expr, ok := data["expression"].(string)
+			if !ok {
+				return fmt.Errorf("formula for dimension %s must have a string expression", dimension)
+			}
+			source = expr
+		default:
+			return fmt.Errorf("formula for dimension %s must be a string or a map", dimension)
+		}
+
+		compiled, err := compileFormula(dimension, source)
+		if err != nil {
+			return err
+		}
+
+		formulas[dimension] = compiled
+	}
+
+	if _, err := evaluationOrder(formulas); err != nil {
+		return err
+	}
+
+	dfr.formulas = formulas
+	dfr.generation = NextGeneration()
+	return nil
+}
+
+// LoadFromFile loads formulas from a file
+func (dfr *DerivedFormulaRegistryImpl) LoadFromFile(filePath string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", filePath, err)
+	}
+
+	var config map[string]interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse JSON: %w", err)
+	}
+
+	dfr.filePath = filePath
+	return dfr.LoadFromConfig(config)
+}
+
+// Validate validates all formulas
+func (dfr *DerivedFormulaRegistryImpl) Validate() error {
+	dfr.mu.RLock()
+	defer dfr.mu.RUnlock()
+
+	for dimension, compiled := range dfr.formulas {
+		if compiled == nil || compiled.expr == nil {
+			return fmt.Errorf("formula for dimension %s is nil", dimension)
+		}
+	}
+
+	if _, err := evaluationOrder(dfr.formulas); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Clear clears all formulas
+func (dfr *DerivedFormulaRegistryImpl) Clear() {
+	dfr.mu.Lock()
+	defer dfr.mu.Unlock()
+
+	dfr.formulas = make(map[string]*compiledFormula)
+	dfr.generation = NextGeneration()
+}
+
+// HasFormula checks if a formula exists for the given dimension
+func (dfr *DerivedFormulaRegistryImpl) HasFormula(dimension string) bool {
+	dfr.mu.RLock()
+	defer dfr.mu.RUnlock()
+
+	_, exists := dfr.formulas[dimension]
+	return exists
+}
+
+// Count returns the number of formulas
+func (dfr *DerivedFormulaRegistryImpl) Count() int64 {
+	dfr.mu.RLock()
+	defer dfr.mu.RUnlock()
+
+	return int64(len(dfr.formulas))
+}
+
+// GetGeneration returns the generation, bumped on every formula change
+func (dfr *DerivedFormulaRegistryImpl) GetGeneration() int64 {
+	dfr.mu.RLock()
+	defer dfr.mu.RUnlock()
+
+	return dfr.generation
+}
+
+// GetFilepath returns the current file path
+func (dfr *DerivedFormulaRegistryImpl) GetFilepath() string {
+	dfr.mu.RLock()
+	defer dfr.mu.RUnlock()
+
+	return dfr.filePath
+}
+
+// compileFormula parses a formula source for the given dimension
+func compileFormula(dimension string, source string) (*compiledFormula, error) {
+	if dimension == "" {
+		return nil, fmt.Errorf("dimension cannot be empty")
+	}
+
+	expr, err := expression.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid formula for dimension %s: %w", dimension, err)
+	}
+
+	dependsOn := expr.Variables()
+	for _, dependency := range dependsOn {
+		if dependency == dimension {
+			return nil, fmt.Errorf("formula for dimension %s references itself", dimension)
+		}
+	}
+
+	return &compiledFormula{
+		formula: &interfaces.DerivedFormula{
+			Dimension:  dimension,
+			Expression: source,
+			DependsOn:  dependsOn,
+		},
+		expr: expr,
+	}, nil
+}
+
+// evaluationOrder topologically sorts formula dimensions so that formulas
+// referencing other formula dimensions are evaluated after them. Ties are
+// broken by dimension name so the order is stable.
+func evaluationOrder(formulas map[string]*compiledFormula) ([]string, error) {
+	inDegree := make(map[string]int, len(formulas))
+	dependents := make(map[string][]string, len(formulas))
+
+	for dimension := range formulas {
+		inDegree[dimension] = 0
+	}
+
+	for dimension, compiled := range formulas {
+		for _, dependency := range compiled.formula.DependsOn {
+			if _, isFormula := formulas[dependency]; !isFormula {
+				continue
+			}
+			inDegree[dimension]++
+			dependents[dependency] = append(dependents[dependency], dimension)
+		}
+	}
+
+	ready := make([]string, 0, len(formulas))
+	for dimension, degree := range inDegree {
+		if degree == 0 {
+			ready = append(ready, dimension)
+		}
+	}
+	sort.Strings(ready)
+
+	order := make([]string, 0, len(formulas))
+	for len(ready) > 0 {
+		dimension := ready[0]
+		ready = ready[1:]
+		order = append(order, dimension)
+
+		released := false
+		for _, dependent := range dependents[dimension] {
+			inDegree[dependent]--
+			if inDegree[dependent] == 0 {
+				ready = append(ready, dependent)
+				released = true
+			}
+		}
+		if released {
+			sort.Strings(ready)
+		}
+	}
+
+	if len(order) != len(formulas) {
+		cyclic := make([]string, 0)
+		for dimension, degree := range inDegree {
+			if degree > 0 {
+				cyclic = append(cyclic, dimension)
+			}
+		}
+		sort.Strings(cyclic)
+		return nil, fmt.Errorf("circular formula dependency between dimensions %v", cyclic)
+	}
+
+	return order, nil
+}
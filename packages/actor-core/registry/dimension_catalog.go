@@ -0,0 +1,363 @@
+package registry
+
+import (
+	"bytes"
+	"chaos-actor-module/packages/actor-core/enums"
+	"chaos-actor-module/packages/actor-core/interfaces"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// DimensionCatalogImpl implements the DimensionCatalog interface
+type DimensionCatalogImpl struct {
+	dimensions map[string]interfaces.DimensionDefinition
+	mu         sync.RWMutex
+	filePath   string
+	generation int64
+}
+
+// NewDimensionCatalog creates a new dimension catalog with the built-in dimensions
+func NewDimensionCatalog() interfaces.DimensionCatalog {
+	return &DimensionCatalogImpl{
+		dimensions: builtinDimensions(),
+		generation: NextGeneration(),
+	}
+}
+
+// NewDimensionCatalogFromFile creates a new dimension catalog from a file
+func NewDimensionCatalogFromFile(filePath string) (interfaces.DimensionCatalog, error) {
+	catalog := &DimensionCatalogImpl{
+		dimensions: builtinDimensions(),
+		filePath:   filePath,
+		generation: NextGeneration(),
+	}
+
+	if err := catalog.LoadFromFile(filePath); err != nil {
+		return nil, fmt.Errorf("failed to load dimension catalog from file: %w", err)
+	}
+
+	return catalog, nil
+}
+
+// RegisterDimension registers a dimension or replaces a registered dimension's definition
+func (dc *DimensionCatalogImpl) RegisterDimension(dimension interfaces.DimensionDefinition) error {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	if err := validateDimension(dimension); err != nil {
+		return err
+	}
+
+	dc.dimensions[dimension.Name] = dimension
+	dc.generation = NextGeneration()
+	return nil
+}
+
+// RemoveDimension removes a dimension
+func (dc *DimensionCatalogImpl) RemoveDimension(name string) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	if _, exists := dc.dimensions[name]; exists {
+		delete(dc.dimensions, name)
+		dc.generation = NextGeneration()
+	}
+}
+
+// GetDimension returns the definition of a dimension
+func (dc *DimensionCatalogImpl) GetDimension(name string) (interfaces.DimensionDefinition, bool) {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+
+	dimension, exists := dc.dimensions[name]
+	return dimension, exists
+}
+
+// GetDimensions returns the definitions of all dimensions, sorted by name
+func (dc *DimensionCatalogImpl) GetDimensions() []interfaces.DimensionDefinition {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+
+	return dc.sortedDimensionsUnsafe(func(interfaces.DimensionDefinition) bool { return true })
+}
+
+// GetDimensionNames returns the names of all dimensions, sorted
+func (dc *DimensionCatalogImpl) GetDimensionNames() []string {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+
+	names := make([]string, 0, len(dc.dimensions))
+	for name := range dc.dimensions {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// GetDimensionsBySubsystem returns the definitions of the dimensions a
+// subsystem owns, sorted by name
+func (dc *DimensionCatalogImpl) GetDimensionsBySubsystem(subsystem string) []interfaces.DimensionDefinition {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+
+	return dc.sortedDimensionsUnsafe(func(dimension interfaces.DimensionDefinition) bool {
+		return dimension.Subsystem == subsystem
+	})
+}
+
+// LoadFromConfig replaces the catalog with the dimensions in configuration:
+//
+//	dimensions:
+//	  strength: { display_name: Strength, unit: points, kind: primary, default_caps: { min: 0, max: 999999 } }
+//	  qi_max:   { kind: derived, default_caps: { min: 0, max: 50000 }, subsystem: jindan }
+//
+// The catalog is left unchanged if any dimension is invalid.
+func (dc *DimensionCatalogImpl) LoadFromConfig(config map[string]interface{}) error {
+	if config == nil {
+		return fmt.Errorf("config cannot be nil")
+	}
+
+	dimensionsMap, ok := config["dimensions"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("dimensions must be an object")
+	}
+
+	dimensions := make(map[string]interfaces.DimensionDefinition, len(dimensionsMap))
+	for name, data := range dimensionsMap {
+		dimension, err := parseDimensionDefinition(name, data)
+		if err != nil {
+			return err
+		}
+		dimensions[name] = dimension
+	}
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	dc.dimensions = dimensions
+	dc.generation = NextGeneration()
+	return nil
+}
+
+// LoadFromFile loads the catalog from a YAML or JSON file
+func (dc *DimensionCatalogImpl) LoadFromFile(filePath string) error {
+	config, err := NewConfigLoader().Load(filePath)
+	if err != nil {
+		return err
+	}
+
+	if err := dc.LoadFromConfig(config); err != nil {
+		return err
+	}
+
+	dc.mu.Lock()
+	dc.filePath = filePath
+	dc.mu.Unlock()
+	return nil
+}
+
+// SaveToFile saves the catalog to a JSON file
+func (dc *DimensionCatalogImpl) SaveToFile(filePath string) error {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	config := map[string]interface{}{
+		"dimensions": dc.dimensions,
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+
+	// Ensure directory exists
+	dir := filepath.Dir(filePath)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create directory %s: %w", dir, err)
+	}
+
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", filePath, err)
+	}
+
+	dc.filePath = filePath
+	return nil
+}
+
+// Validate validates all dimensions
+func (dc *DimensionCatalogImpl) Validate() error {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+
+	for name, dimension := range dc.dimensions {
+		if dimension.Name != name {
+			return fmt.Errorf("dimension %s is registered under %s", dimension.Name, name)
+		}
+		if err := validateDimension(dimension); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Reset restores the built-in dimensions
+func (dc *DimensionCatalogImpl) Reset() {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	dc.dimensions = builtinDimensions()
+	dc.generation = NextGeneration()
+}
+
+// Count returns the number of dimensions
+func (dc *DimensionCatalogImpl) Count() int64 {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+
+	return int64(len(dc.dimensions))
+}
+
+// GetGeneration returns the generation, bumped on every catalog change
+func (dc *DimensionCatalogImpl) GetGeneration() int64 {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+
+	return dc.generation
+}
+
+// GetFilepath returns the file path the catalog was loaded from or saved to
+func (dc *DimensionCatalogImpl) GetFilepath() string {
+	dc.mu.RLock()
+	defer dc.mu.RUnlock()
+
+	return dc.filePath
+}
+
+// sortedDimensionsUnsafe returns the dimensions matching keep, sorted by name,
+// without locking (internal use)
+func (dc *DimensionCatalogImpl) sortedDimensionsUnsafe(keep func(interfaces.DimensionDefinition) bool) []interfaces.DimensionDefinition {
+	dimensions := make([]interfaces.DimensionDefinition, 0, len(dc.dimensions))
+	for _, dimension := range dc.dimensions {
+		if keep(dimension) {
+			dimensions = append(dimensions, dimension)
+		}
+	}
+
+	sort.Slice(dimensions, func(i, j int) bool {
+		return dimensions[i].Name < dimensions[j].Name
+	})
+	return dimensions
+}
+
+// validateDimension validates a dimension definition
+func validateDimension(dimension interfaces.DimensionDefinition) error {
+	if dimension.Name == "" {
+		return fmt.Errorf("dimension name cannot be empty")
+	}
+
+	if !dimension.Kind.IsValid() {
+		return fmt.Errorf("invalid kind for dimension %s: %q", dimension.Name, dimension.Kind)
+	}
+
+	if dimension.DefaultCaps.Min > dimension.DefaultCaps.Max {
+		return fmt.Errorf("default caps min (%v) is greater than max (%v) for dimension %s", dimension.DefaultCaps.Min, dimension.DefaultCaps.Max, dimension.Name)
+	}
+
+	return nil
+}
+
+// parseDimensionDefinition parses a catalog entry. The entry's name defaults
+// to its key, and its default caps are required.
+func parseDimensionDefinition(name string, data interface{}) (interfaces.DimensionDefinition, error) {
+	var entry struct {
+		interfaces.DimensionDefinition
+		DefaultCaps *interfaces.Caps `json:"default_caps"`
+	}
+
+	if _, ok := data.(map[string]interface{}); !ok {
+		return interfaces.DimensionDefinition{}, fmt.Errorf("dimension %s must be an object", name)
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return interfaces.DimensionDefinition{}, fmt.Errorf("invalid dimension %s: %w", name, err)
+	}
+
+	// Reject unknown fields, so that misspelled ones are not silently dropped
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&entry); err != nil {
+		return interfaces.DimensionDefinition{}, fmt.Errorf("invalid dimension %s: %w", name, err)
+	}
+
+	dimension := entry.DimensionDefinition
+	if dimension.Name == "" {
+		dimension.Name = name
+	} else if dimension.Name != name {
+		return dimension, fmt.Errorf("dimension %s is named %s", name, dimension.Name)
+	}
+
+	if entry.DefaultCaps == nil {
+		return dimension, fmt.Errorf("dimension %s must have default_caps", name)
+	}
+	dimension.DefaultCaps = *entry.DefaultCaps
+
+	return dimension, validateDimension(dimension)
+}
+
+// builtinDimensions returns the dimensions every catalog starts with, as
+// listed in docs/21_Dimension_Catalog.md
+func builtinDimensions() map[string]interfaces.DimensionDefinition {
+	primary := func(name, displayName, unit string, max float64, description string) interfaces.DimensionDefinition {
+		return interfaces.DimensionDefinition{Name: name, DisplayName: displayName, Unit: unit, Kind: enums.DimensionKindPrimary, DefaultCaps: interfaces.Caps{Min: 0, Max: max}, Description: description}
+	}
+	derived := func(name, displayName, unit string, min, max float64, description string) interfaces.DimensionDefinition {
+		return interfaces.DimensionDefinition{Name: name, DisplayName: displayName, Unit: unit, Kind: enums.DimensionKindDerived, DefaultCaps: interfaces.Caps{Min: min, Max: max}, Description: description}
+	}
+
+	dimensions := []interfaces.DimensionDefinition{
+		primary("strength", "Strength", "points", 999999, "Physical power and damage capability"),
+		primary("vitality", "Vitality", "points", 999999, "Health and endurance"),
+		primary("dexterity", "Dexterity", "points", 999999, "Speed and agility"),
+		primary("intelligence", "Intelligence", "points", 999999, "Mental capacity and magical power"),
+		primary("spirit", "Spirit", "points", 999999, "Spiritual energy and cultivation"),
+		primary("charisma", "Charisma", "points", 999999, "Social influence"),
+		primary("luck", "Luck", "points", 999999, "Fortune and chance"),
+
+		derived("hp_max", "Max HP", "points", 1, 2000000, "Maximum health points"),
+		derived("mp_max", "Max MP", "points", 1, 1000000, "Maximum mana points"),
+		derived("stamina_max", "Max Stamina", "points", 1, 500000, "Maximum stamina points"),
+		derived("attack_power", "Attack Power", "points", 0, 999999, "Physical damage output"),
+		derived("defense", "Defense", "points", 0, 999999, "Physical damage reduction"),
+		derived("magic_power", "Magic Power", "points", 0, 999999, "Magical damage output"),
+		derived("magic_resistance", "Magic Resistance", "points", 0, 999999, "Magical damage reduction"),
+		derived("crit_rate", "Critical Rate", "ratio", 0, 1, "Critical hit chance"),
+		derived("crit_damage", "Critical Damage", "multiplier", 1, 10, "Critical hit damage multiplier"),
+		derived("accuracy", "Accuracy", "ratio", 0, 1, "Hit chance"),
+		derived("move_speed", "Move Speed", "m/s", 0, 12, "Movement speed"),
+		derived("attack_speed", "Attack Speed", "attacks/s", 0.1, 10, "Attack speed"),
+		derived("cast_speed", "Cast Speed", "spells/s", 0.1, 10, "Spell casting speed"),
+		derived("cooldown_reduction", "Cooldown Reduction", "ratio", 0, 0.5, "Ability cooldown reduction"),
+		derived("mana_efficiency", "Mana Efficiency", "ratio", 0, 0.8, "Mana cost reduction"),
+		derived("energy_efficiency", "Energy Efficiency", "ratio", 0, 0.8, "Energy cost reduction"),
+		derived("learning_rate", "Learning Rate", "multiplier", 0.1, 5, "Experience gain multiplier"),
+		derived("cultivation_speed", "Cultivation Speed", "multiplier", 0.1, 10, "Cultivation progress rate"),
+		derived("breakthrough_success", "Breakthrough Success", "ratio", 0, 1, "Breakthrough success chance"),
+		derived("lifespan_years", "Lifespan", "years", 1, 10000, "Character lifespan"),
+		derived("poise_rank", "Poise Rank", "rank", 0, 10, "Social standing rank"),
+		derived("stealth", "Stealth", "ratio", 0, 1, "Stealth capability"),
+		derived("perception", "Perception", "ratio", 0, 1, "Awareness and detection"),
+	}
+
+	result := make(map[string]interfaces.DimensionDefinition, len(dimensions))
+	for _, dimension := range dimensions {
+		result[dimension.Name] = dimension
+	}
+	return result
+}
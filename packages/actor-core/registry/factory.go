@@ -33,6 +33,26 @@ func (rf *RegistryFactory) CreateCapLayerRegistryFromFile(filePath string) (inte
 	return NewCapLayerRegistryFromFile(filePath)
 }
 
+// CreateDerivedFormulaRegistry creates a new derived formula registry
+func (rf *RegistryFactory) CreateDerivedFormulaRegistry() interfaces.DerivedFormulaRegistry {
+	return NewDerivedFormulaRegistry()
+}
+
+// CreateDerivedFormulaRegistryFromFile creates a new derived formula registry from a file
+func (rf *RegistryFactory) CreateDerivedFormulaRegistryFromFile(filePath string) (interfaces.DerivedFormulaRegistry, error) {
+	return NewDerivedFormulaRegistryFromFile(filePath)
+}
+
+// CreateDimensionCatalog creates a new dimension catalog with the built-in dimensions
+func (rf *RegistryFactory) CreateDimensionCatalog() interfaces.DimensionCatalog {
+	return NewDimensionCatalog()
+}
+
+// CreateDimensionCatalogFromFile creates a new dimension catalog from a file
+func (rf *RegistryFactory) CreateDimensionCatalogFromFile(filePath string) (interfaces.DimensionCatalog, error) {
+	return NewDimensionCatalogFromFile(filePath)
+}
+
 // CreatePluginRegistry creates a new plugin registry
 func (rf *RegistryFactory) CreatePluginRegistry() interfaces.PluginRegistry {
 	return NewPluginRegistry()
@@ -52,16 +72,18 @@ func (rf *RegistryFactory) CreateCache(maxSize int64, evictionPolicy string) int
 func (rf *RegistryFactory) CreateAllRegistries() (*RegistrySet, error) {
 	combinerRegistry := rf.CreateCombinerRegistry()
 	capLayerRegistry := rf.CreateCapLayerRegistry()
+	derivedFormulaRegistry := rf.CreateDerivedFormulaRegistry()
 	pluginRegistry := rf.CreatePluginRegistry()
 	configLoader := rf.CreateConfigLoader()
 	cache := rf.CreateCache(1000, "lru")
 	
 	return &RegistrySet{
-		CombinerRegistry: combinerRegistry,
-		CapLayerRegistry: capLayerRegistry,
-		PluginRegistry:   pluginRegistry,
-		ConfigLoader:     configLoader,
-		Cache:            cache,
+		CombinerRegistry:       combinerRegistry,
+		CapLayerRegistry:       capLayerRegistry,
+		DerivedFormulaRegistry: derivedFormulaRegistry,
+		PluginRegistry:         pluginRegistry,
+		ConfigLoader:           configLoader,
+		Cache:                  cache,
 	}, nil
 }
 
@@ -77,26 +99,29 @@ func (rf *RegistryFactory) CreateAllRegistriesFromFiles(combinerFile, layerFile
 		return nil, fmt.Errorf("failed to create cap layer registry: %w", err)
 	}
 	
+	derivedFormulaRegistry := rf.CreateDerivedFormulaRegistry()
 	pluginRegistry := rf.CreatePluginRegistry()
 	configLoader := rf.CreateConfigLoader()
 	cache := rf.CreateCache(1000, "lru")
 	
 	return &RegistrySet{
-		CombinerRegistry: combinerRegistry,
-		CapLayerRegistry: capLayerRegistry,
-		PluginRegistry:   pluginRegistry,
-		ConfigLoader:     configLoader,
-		Cache:            cache,
+		CombinerRegistry:       combinerRegistry,
+		CapLayerRegistry:       capLayerRegistry,
+		DerivedFormulaRegistry: derivedFormulaRegistry,
+		PluginRegistry:         pluginRegistry,
+		ConfigLoader:           configLoader,
+		Cache:                  cache,
 	}, nil
 }
 
 // RegistrySet contains all registry instances
 type RegistrySet struct {
-	CombinerRegistry interfaces.CombinerRegistry
-	CapLayerRegistry interfaces.CapLayerRegistry
-	PluginRegistry   interfaces.PluginRegistry
-	ConfigLoader     interfaces.ConfigLoader
-	Cache            interfaces.Cache
+	CombinerRegistry       interfaces.CombinerRegistry
+	CapLayerRegistry       interfaces.CapLayerRegistry
+	DerivedFormulaRegistry interfaces.DerivedFormulaRegistry
+	PluginRegistry         interfaces.PluginRegistry
+	ConfigLoader           interfaces.ConfigLoader
+	Cache                  interfaces.Cache
 }
 
 // Validate validates all registries
@@ -130,6 +155,13 @@ func (rs *RegistrySet) Validate() error {
 		return fmt.Errorf("cap layer registry validation failed: %w", err)
 	}
 	
+	// DerivedFormulaRegistry is optional
+	if rs.DerivedFormulaRegistry != nil {
+		if err := rs.DerivedFormulaRegistry.Validate(); err != nil {
+			return fmt.Errorf("derived formula registry validation failed: %w", err)
+		}
+	}
+	
 	// PluginRegistry doesn't have Validate method
 	// if err := rs.PluginRegistry.Validate(); err != nil {
 	// 	return fmt.Errorf("plugin registry validation failed: %w", err)
@@ -148,6 +180,11 @@ func (rs *RegistrySet) GetCapLayerRegistry() interfaces.CapLayerRegistry {
 	return rs.CapLayerRegistry
 }
 
+// GetDerivedFormulaRegistry returns the derived formula registry
+func (rs *RegistrySet) GetDerivedFormulaRegistry() interfaces.DerivedFormulaRegistry {
+	return rs.DerivedFormulaRegistry
+}
+
 // GetPluginRegistry returns the plugin registry
 func (rs *RegistrySet) GetPluginRegistry() interfaces.PluginRegistry {
 	return rs.PluginRegistry
@@ -173,6 +210,11 @@ func (rs *RegistrySet) SetCapLayerRegistry(registry interfaces.CapLayerRegistry)
 	rs.CapLayerRegistry = registry
 }
 
+// SetDerivedFormulaRegistry sets the derived formula registry
+func (rs *RegistrySet) SetDerivedFormulaRegistry(registry interfaces.DerivedFormulaRegistry) {
+	rs.DerivedFormulaRegistry = registry
+}
+
 // SetPluginRegistry sets the plugin registry
 func (rs *RegistrySet) SetPluginRegistry(registry interfaces.PluginRegistry) {
 	rs.PluginRegistry = registry
@@ -219,6 +261,10 @@ func (rs *RegistrySet) GetStats() map[string]interface{} {
 		stats["across_policy"] = rs.CapLayerRegistry.GetAcrossLayerPolicy()
 	}
 	
+	if rs.DerivedFormulaRegistry != nil {
+		stats["derived_formula_count"] = rs.DerivedFormulaRegistry.Count()
+	}
+	
 	if rs.PluginRegistry != nil {
 		stats["subsystem_count"] = rs.PluginRegistry.Count()
 	}
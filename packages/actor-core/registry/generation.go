@@ -0,0 +1,13 @@
+package registry
+
+import "sync/atomic"
+
+// generationCounter is shared by all registries so that generations are
+// unique across registries and never reused after a registry is replaced
+var generationCounter int64
+
+// NextGeneration returns a new registry generation, greater than every
+// generation returned before
+func NextGeneration() int64 {
+	return atomic.AddInt64(&generationCounter, 1)
+}
@@ -7,15 +7,21 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"sync"
 )
 
 // CapLayerRegistryImpl implements the CapLayerRegistry interface
 type CapLayerRegistryImpl struct {
-	layerOrder      []string
-	acrossPolicy    string
-	mu              sync.RWMutex
-	filePath        string
+	layerOrder        []string
+	acrossPolicy      string
+	dimensionPolicies map[string]string
+	policies          map[string]interfaces.AcrossLayerPolicy
+	layers            map[string]interfaces.LayerDefinition
+	mu                sync.RWMutex
+	filePath          string
+	generation        int64
 }
 
 // NewCapLayerRegistry creates a new cap layer registry with default configuration
@@ -23,6 +29,9 @@ func NewCapLayerRegistry() interfaces.CapLayerRegistry {
 	return &CapLayerRegistryImpl{
 		layerOrder:   []string{enums.LayerRealm.String(), enums.LayerWorld.String(), enums.LayerEvent.String(), enums.LayerGuild.String(), enums.LayerTotal.String()},
 		acrossPolicy: "intersect",
+		policies:     builtinPolicies(),
+		layers:       builtinLayers(),
+		generation:   NextGeneration(),
 	}
 }
 
@@ -31,7 +40,10 @@ func NewCapLayerRegistryFromFile(filePath string) (interfaces.CapLayerRegistry,
 	registry := &CapLayerRegistryImpl{
 		layerOrder:   []string{enums.LayerRealm.String(), enums.LayerWorld.String(), enums.LayerEvent.String(), enums.LayerGuild.String(), enums.LayerTotal.String()},
 		acrossPolicy: "intersect",
+		policies:     builtinPolicies(),
+		layers:       builtinLayers(),
 		filePath:     filePath,
+		generation:   NextGeneration(),
 	}
 	
 	if err := registry.LoadFromFile(filePath); err != nil {
@@ -65,36 +77,13 @@ func (clr *CapLayerRegistryImpl) SetLayerOrder(order []string) error {
 	clr.mu.Lock()
 	defer clr.mu.Unlock()
 	
-	if len(order) == 0 {
-		return fmt.Errorf("layer order cannot be empty")
-	}
-	
-	// Validate layer names
-	validLayers := map[string]bool{
-		enums.LayerRealm.String(): true,
-		enums.LayerWorld.String(): true,
-		enums.LayerEvent.String(): true,
-		enums.LayerGuild.String(): true,
-		enums.LayerTotal.String(): true,
-	}
-	
-	for _, layer := range order {
-		if !validLayers[layer] {
-			return fmt.Errorf("invalid layer: %s", layer)
-		}
-	}
-	
-	// Check for duplicates
-	seen := make(map[string]bool)
-	for _, layer := range order {
-		if seen[layer] {
-			return fmt.Errorf("duplicate layer in order: %s", layer)
-		}
-		seen[layer] = true
+	if err := validateLayerOrder(order, clr.layers); err != nil {
+		return err
 	}
 	
 	clr.layerOrder = make([]string, len(order))
 	copy(clr.layerOrder, order)
+	clr.generation = NextGeneration()
 	return nil
 }
 
@@ -103,32 +92,52 @@ func (clr *CapLayerRegistryImpl) SetAcrossLayerPolicy(policy string) error {
 	clr.mu.Lock()
 	defer clr.mu.Unlock()
 	
-	validPolicies := map[string]bool{
-		"intersect": true,
-		"union":     true,
-	}
-	
-	if !validPolicies[policy] {
+	if _, exists := clr.policies[policy]; !exists {
 		return fmt.Errorf("invalid across-layer policy: %s", policy)
 	}
 	
 	clr.acrossPolicy = policy
+	clr.generation = NextGeneration()
 	return nil
 }
 
-// LoadFromConfig loads configuration from config
+// LoadFromConfig loads configuration from config. Every section is validated
+// before any of it is applied, so an invalid config leaves the registry
+// unchanged. Layers loaded without an order must still cover the current order.
 func (clr *CapLayerRegistryImpl) LoadFromConfig(config map[string]interface{}) error {
 	clr.mu.Lock()
 	defer clr.mu.Unlock()
-	
+
+	layers, order, acrossPolicy, dimensionPolicies := clr.layers, clr.layerOrder, clr.acrossPolicy, clr.dimensionPolicies
+	changed := false
+
+	// Load layer definitions, which the order may reference. They replace
+	// every layer but the built-in ones.
+	if layersData, exists := config["layers"]; exists {
+		definitions, err := parseLayerDefinitions(layersData)
+		if err != nil {
+			return err
+		}
+
+		layers = builtinLayers()
+		for _, layer := range definitions {
+			if err := clr.validateLayerUnsafe(layer); err != nil {
+				return err
+			}
+			layers[layer.Name] = layer
+		}
+		changed = true
+	}
+
 	// Load layer order
-	if orderData, exists := config["order"]; exists {
+	orderData, hasOrder := config["order"]
+	if hasOrder {
 		orderSlice, ok := orderData.([]interface{})
 		if !ok {
 			return fmt.Errorf("order must be an array")
 		}
-		
-		order := make([]string, len(orderSlice))
+
+		order = make([]string, len(orderSlice))
 		for i, layer := range orderSlice {
 			if layerStr, ok := layer.(string); ok {
 				order[i] = layerStr
@@ -136,23 +145,60 @@ func (clr *CapLayerRegistryImpl) LoadFromConfig(config map[string]interface{}) e
 				return fmt.Errorf("order[%d] must be a string", i)
 			}
 		}
-		
-		if err := clr.setLayerOrderUnsafe(order); err != nil {
-			return err
+		changed = true
+	}
+
+	if err := validateLayerOrder(order, layers); err != nil {
+		if !hasOrder {
+			return fmt.Errorf("layers do not cover the current layer order: %w", err)
 		}
+		return err
 	}
-	
+
 	// Load across-layer policy
 	if policyData, exists := config["across_policy"]; exists {
-		if policy, ok := policyData.(string); ok {
-			if err := clr.setAcrossLayerPolicyUnsafe(policy); err != nil {
-				return err
-			}
-		} else {
+		policy, ok := policyData.(string)
+		if !ok {
 			return fmt.Errorf("across_policy must be a string")
 		}
+		if _, exists := clr.policies[policy]; !exists {
+			return fmt.Errorf("invalid across-layer policy: %s", policy)
+		}
+		acrossPolicy = policy
+		changed = true
 	}
-	
+
+	// Load per-dimension policy overrides
+	if policiesData, exists := config["dimension_policies"]; exists {
+		policiesMap, ok := policiesData.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("dimension_policies must be an object")
+		}
+
+		dimensionPolicies = make(map[string]string, len(policiesMap))
+		for dimension, policyData := range policiesMap {
+			policy, ok := policyData.(string)
+			if !ok {
+				return fmt.Errorf("dimension_policies[%s] must be a string", dimension)
+			}
+			if _, exists := clr.policies[policy]; !exists {
+				return fmt.Errorf("invalid across-layer policy for dimension %s: %s", dimension, policy)
+			}
+			dimensionPolicies[dimension] = policy
+		}
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	clr.layers = layers
+	clr.layerOrder = make([]string, len(order))
+	copy(clr.layerOrder, order)
+	clr.acrossPolicy = acrossPolicy
+	clr.dimensionPolicies = dimensionPolicies
+	clr.generation = NextGeneration()
 	return nil
 }
 
@@ -181,6 +227,18 @@ func (clr *CapLayerRegistryImpl) SaveToFile(filePath string) error {
 		"order":         clr.layerOrder,
 		"across_policy": clr.acrossPolicy,
 	}
+	if len(clr.dimensionPolicies) > 0 {
+		config["dimension_policies"] = clr.dimensionPolicies
+	}
+
+	layers := make([]interfaces.LayerDefinition, 0, len(clr.layers))
+	for _, layer := range clr.layers {
+		layers = append(layers, layer)
+	}
+	sort.Slice(layers, func(i, j int) bool {
+		return layers[i].Name < layers[j].Name
+	})
+	config["layers"] = layers
 	
 	data, err := json.MarshalIndent(config, "", "  ")
 	if err != nil {
@@ -212,16 +270,8 @@ func (clr *CapLayerRegistryImpl) Validate() error {
 	}
 	
 	// Validate layer names
-	validLayers := map[string]bool{
-		enums.LayerRealm.String(): true,
-		enums.LayerWorld.String(): true,
-		enums.LayerEvent.String(): true,
-		enums.LayerGuild.String(): true,
-		enums.LayerTotal.String(): true,
-	}
-	
 	for _, layer := range clr.layerOrder {
-		if !validLayers[layer] {
+		if _, exists := clr.layers[layer]; !exists {
 			return fmt.Errorf("invalid layer: %s", layer)
 		}
 	}
@@ -235,15 +285,22 @@ func (clr *CapLayerRegistryImpl) Validate() error {
 		seen[layer] = true
 	}
 	
-	// Validate across-layer policy
-	validPolicies := map[string]bool{
-		"intersect": true,
-		"union":     true,
-	}
-	
-	if !validPolicies[clr.acrossPolicy] {
+	// Validate across-layer policies
+	if _, exists := clr.policies[clr.acrossPolicy]; !exists {
 		return fmt.Errorf("invalid across-layer policy: %s", clr.acrossPolicy)
 	}
+
+	for dimension, policy := range clr.dimensionPolicies {
+		if _, exists := clr.policies[policy]; !exists {
+			return fmt.Errorf("invalid across-layer policy for dimension %s: %s", dimension, policy)
+		}
+	}
+
+	for name, layer := range clr.layers {
+		if _, exists := clr.policies[layer.DefaultPolicy]; layer.DefaultPolicy != "" && !exists {
+			return fmt.Errorf("invalid default policy for layer %s: %s", name, layer.DefaultPolicy)
+		}
+	}
 	
 	return nil
 }
@@ -325,54 +382,243 @@ func (clr *CapLayerRegistryImpl) Reset() {
 	
 	clr.layerOrder = []string{enums.LayerRealm.String(), enums.LayerWorld.String(), enums.LayerEvent.String(), enums.LayerGuild.String(), enums.LayerTotal.String()}
 	clr.acrossPolicy = "intersect"
+	clr.dimensionPolicies = nil
+	clr.layers = builtinLayers()
+	clr.generation = NextGeneration()
 }
 
-// setLayerOrderUnsafe sets layer order without locking (internal use)
-func (clr *CapLayerRegistryImpl) setLayerOrderUnsafe(order []string) error {
+// GetGeneration returns the generation, bumped on every configuration change
+func (clr *CapLayerRegistryImpl) GetGeneration() int64 {
+	clr.mu.RLock()
+	defer clr.mu.RUnlock()
+	
+	return clr.generation
+}
+
+// GetDimensionPolicy returns the across-layer policy for a dimension
+func (clr *CapLayerRegistryImpl) GetDimensionPolicy(dimension string) string {
+	clr.mu.RLock()
+	defer clr.mu.RUnlock()
+
+	if policy, exists := clr.dimensionPolicies[dimension]; exists {
+		return policy
+	}
+
+	return clr.acrossPolicy
+}
+
+// SetDimensionPolicy overrides the across-layer policy for a dimension
+func (clr *CapLayerRegistryImpl) SetDimensionPolicy(dimension string, policy string) error {
+	clr.mu.Lock()
+	defer clr.mu.Unlock()
+
+	if dimension == "" {
+		return fmt.Errorf("dimension cannot be empty")
+	}
+
+	if policy == "" {
+		delete(clr.dimensionPolicies, dimension)
+		clr.generation = NextGeneration()
+		return nil
+	}
+
+	if _, exists := clr.policies[policy]; !exists {
+		return fmt.Errorf("invalid across-layer policy: %s", policy)
+	}
+
+	if clr.dimensionPolicies == nil {
+		clr.dimensionPolicies = make(map[string]string)
+	}
+
+	clr.dimensionPolicies[dimension] = policy
+	clr.generation = NextGeneration()
+	return nil
+}
+
+// GetDimensionPolicies returns the per-dimension policy overrides
+func (clr *CapLayerRegistryImpl) GetDimensionPolicies() map[string]string {
+	clr.mu.RLock()
+	defer clr.mu.RUnlock()
+
+	// Return a copy to prevent external modification
+	policies := make(map[string]string, len(clr.dimensionPolicies))
+	for dimension, policy := range clr.dimensionPolicies {
+		policies[dimension] = policy
+	}
+	return policies
+}
+
+// RegisterPolicy registers an across-layer policy, so that configuration can
+// select it by name. Built-in policies cannot be replaced.
+func (clr *CapLayerRegistryImpl) RegisterPolicy(policy interfaces.AcrossLayerPolicy) error {
+	if policy == nil {
+		return fmt.Errorf("policy cannot be nil")
+	}
+
+	if policy.Name() == "" {
+		return fmt.Errorf("policy name cannot be empty")
+	}
+
+	clr.mu.Lock()
+	defer clr.mu.Unlock()
+
+	if enums.AcrossLayerPolicy(policy.Name()).IsValid() {
+		return fmt.Errorf("cannot replace built-in policy %s", policy.Name())
+	}
+
+	clr.policies[policy.Name()] = policy
+	clr.generation = NextGeneration()
+	return nil
+}
+
+// GetPolicy returns the across-layer policy registered under a name
+func (clr *CapLayerRegistryImpl) GetPolicy(name string) (interfaces.AcrossLayerPolicy, bool) {
+	clr.mu.RLock()
+	defer clr.mu.RUnlock()
+
+	policy, exists := clr.policies[name]
+	return policy, exists
+}
+
+// RegisterLayer registers a layer or replaces a registered layer's definition
+func (clr *CapLayerRegistryImpl) RegisterLayer(layer interfaces.LayerDefinition) error {
+	clr.mu.Lock()
+	defer clr.mu.Unlock()
+
+	if err := clr.validateLayerUnsafe(layer); err != nil {
+		return err
+	}
+
+	clr.layers[layer.Name] = layer
+	clr.generation = NextGeneration()
+	return nil
+}
+
+// GetLayer returns the definition of a registered layer
+func (clr *CapLayerRegistryImpl) GetLayer(name string) (interfaces.LayerDefinition, bool) {
+	clr.mu.RLock()
+	defer clr.mu.RUnlock()
+
+	layer, exists := clr.layers[name]
+	return layer, exists
+}
+
+// GetLayers returns the definitions of all registered layers, sorted by name
+func (clr *CapLayerRegistryImpl) GetLayers() []interfaces.LayerDefinition {
+	clr.mu.RLock()
+	defer clr.mu.RUnlock()
+
+	layers := make([]interfaces.LayerDefinition, 0, len(clr.layers))
+	for _, layer := range clr.layers {
+		layers = append(layers, layer)
+	}
+
+	sort.Slice(layers, func(i, j int) bool {
+		return layers[i].Name < layers[j].Name
+	})
+	return layers
+}
+
+// ValidateScopes checks that every cap contribution's scope is a registered
+// layer. The error lists each unknown scope with the systems that used it.
+func (clr *CapLayerRegistryImpl) ValidateScopes(caps []interfaces.CapContribution) error {
+	clr.mu.RLock()
+	defer clr.mu.RUnlock()
+
+	unknown := make(map[string]map[string]bool)
+	for _, cap := range caps {
+		if _, exists := clr.layers[cap.Scope]; exists {
+			continue
+		}
+		if unknown[cap.Scope] == nil {
+			unknown[cap.Scope] = make(map[string]bool)
+		}
+		unknown[cap.Scope][cap.System] = true
+	}
+
+	if len(unknown) == 0 {
+		return nil
+	}
+
+	scopes := make([]string, 0, len(unknown))
+	for scope, systems := range unknown {
+		names := make([]string, 0, len(systems))
+		for system := range systems {
+			names = append(names, system)
+		}
+		sort.Strings(names)
+		scopes = append(scopes, fmt.Sprintf("%q (from %s)", scope, strings.Join(names, ", ")))
+	}
+	sort.Strings(scopes)
+
+	return fmt.Errorf("caps reference unknown layers: %s", strings.Join(scopes, "; "))
+}
+
+// validateLayerUnsafe validates a layer definition without locking (internal use)
+func (clr *CapLayerRegistryImpl) validateLayerUnsafe(layer interfaces.LayerDefinition) error {
+	if layer.Name == "" {
+		return fmt.Errorf("layer name cannot be empty")
+	}
+
+	if _, exists := clr.policies[layer.DefaultPolicy]; layer.DefaultPolicy != "" && !exists {
+		return fmt.Errorf("invalid default policy for layer %s: %s", layer.Name, layer.DefaultPolicy)
+	}
+
+	return nil
+}
+
+// parseLayerDefinitions parses the layers section of a layer configuration
+func parseLayerDefinitions(data interface{}) ([]interfaces.LayerDefinition, error) {
+	if _, ok := data.([]interface{}); !ok {
+		return nil, fmt.Errorf("layers must be an array")
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid layers: %w", err)
+	}
+
+	var layers []interfaces.LayerDefinition
+	if err := json.Unmarshal(encoded, &layers); err != nil {
+		return nil, fmt.Errorf("invalid layers: %w", err)
+	}
+
+	return layers, nil
+}
+
+// builtinLayers returns the layers every layer registry starts with
+func builtinLayers() map[string]interfaces.LayerDefinition {
+	layers := []interfaces.LayerDefinition{
+		{Name: enums.LayerRealm.String(), Description: "Caps set by the actor's realm or cultivation stage"},
+		{Name: enums.LayerWorld.String(), Description: "Caps set by the world's rules"},
+		{Name: enums.LayerEvent.String(), Description: "Caps set by temporary events"},
+		{Name: enums.LayerGuild.String(), Description: "Caps set by the actor's guild"},
+		{Name: enums.LayerTotal.String(), Description: "Caps that apply on top of every other layer"},
+	}
+
+	result := make(map[string]interfaces.LayerDefinition, len(layers))
+	for _, layer := range layers {
+		result[layer.Name] = layer
+	}
+	return result
+}
+
+// validateLayerOrder checks that order is a non-empty list of distinct layers
+func validateLayerOrder(order []string, layers map[string]interfaces.LayerDefinition) error {
 	if len(order) == 0 {
 		return fmt.Errorf("layer order cannot be empty")
 	}
-	
-	// Validate layer names
-	validLayers := map[string]bool{
-		enums.LayerRealm.String(): true,
-		enums.LayerWorld.String(): true,
-		enums.LayerEvent.String(): true,
-		enums.LayerGuild.String(): true,
-		enums.LayerTotal.String(): true,
-	}
-	
+
+	seen := make(map[string]bool, len(order))
 	for _, layer := range order {
-		if !validLayers[layer] {
+		if _, exists := layers[layer]; !exists {
 			return fmt.Errorf("invalid layer: %s", layer)
 		}
-	}
-	
-	// Check for duplicates
-	seen := make(map[string]bool)
-	for _, layer := range order {
 		if seen[layer] {
 			return fmt.Errorf("duplicate layer in order: %s", layer)
 		}
 		seen[layer] = true
 	}
-	
-	clr.layerOrder = make([]string, len(order))
-	copy(clr.layerOrder, order)
-	return nil
-}
 
-// setAcrossLayerPolicyUnsafe sets across-layer policy without locking (internal use)
-func (clr *CapLayerRegistryImpl) setAcrossLayerPolicyUnsafe(policy string) error {
-	validPolicies := map[string]bool{
-		"intersect": true,
-		"union":     true,
-	}
-	
-	if !validPolicies[policy] {
-		return fmt.Errorf("invalid across-layer policy: %s", policy)
-	}
-	
-	clr.acrossPolicy = policy
 	return nil
 }
@@ -1,30 +1,51 @@
 package registry
 
 import (
+	"chaos-actor-module/packages/actor-core/constants"
 	"chaos-actor-module/packages/actor-core/interfaces"
+	"errors"
 	"fmt"
 	"sort"
+	"strings"
 	"sync"
+	"time"
 )
 
 // PluginRegistryImpl implements the PluginRegistry interface
 type PluginRegistryImpl struct {
 	subsystems map[string]interfaces.Subsystem
+	order      []interfaces.Subsystem
+	conflicts  []interfaces.PluginConflict
+	logger     interfaces.Logger
 	mu         sync.RWMutex
+	generation int64
+
+	// minAPILevel and maxAPILevel bound the API levels accepted by Register
+	minAPILevel int64
+	maxAPILevel int64
+	shims       map[int64]interfaces.SubsystemShim
 }
 
 // NewPluginRegistry creates a new plugin registry
 func NewPluginRegistry() interfaces.PluginRegistry {
 	return &PluginRegistryImpl{
-		subsystems: make(map[string]interfaces.Subsystem),
+		subsystems:  make(map[string]interfaces.Subsystem),
+		generation:  NextGeneration(),
+		minAPILevel: constants.APIMinimum,
+		maxAPILevel: constants.APICurrent,
+		shims:       make(map[int64]interfaces.SubsystemShim),
 	}
 }
 
-// Register registers a subsystem
+// Register registers a subsystem, initializing it first if it is a
+// LifecycleSubsystem. Subsystems built against an API level outside the
+// supported range are adapted by the shim registered for their level, or
+// rejected with an S007 error. A subsystem that fails to initialize or whose
+// ordering constraints would form a cycle is not registered; the subsystems it
+// depends on need not be registered yet. OVERRIDE conflicts the
+// subsystem takes part in are logged as warnings. Initialize is called without
+// the registry lock held, so it may use the registry.
 func (pr *PluginRegistryImpl) Register(subsystem interfaces.Subsystem) error {
-	pr.mu.Lock()
-	defer pr.mu.Unlock()
-
 	if subsystem == nil {
 		return fmt.Errorf("subsystem cannot be nil")
 	}
@@ -34,28 +55,191 @@ func (pr *PluginRegistryImpl) Register(subsystem interfaces.Subsystem) error {
 		return fmt.Errorf("subsystem system ID cannot be empty")
 	}
 
+	pr.mu.Lock()
+	subsystem, err := pr.checkRegisterLocked(subsystem)
+	pr.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if lifecycle, ok := subsystem.(interfaces.LifecycleSubsystem); ok {
+		if err := lifecycle.Initialize(); err != nil {
+			return fmt.Errorf("failed to initialize subsystem %s: %w", systemID, err)
+		}
+	}
+
+	pr.mu.Lock()
+	err = pr.insertLocked(subsystem)
+	pr.mu.Unlock()
+	if err != nil {
+		// Another registration may have raced this one while it initialized
+		return errors.Join(err, shutdownSubsystem(subsystem))
+	}
+
+	return nil
+}
+
+// checkRegisterLocked negotiates the subsystem's API level and checks that it
+// can be inserted. The caller must hold pr.mu.
+func (pr *PluginRegistryImpl) checkRegisterLocked(subsystem interfaces.Subsystem) (interfaces.Subsystem, error) {
+	systemID := subsystem.SystemID()
+	if _, exists := pr.subsystems[systemID]; exists {
+		return nil, fmt.Errorf("subsystem %s already registered", systemID)
+	}
+
+	subsystem, err := pr.negotiateLocked(subsystem)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := OrderSubsystems(append(pr.orderedLocked(), subsystem), nil); err != nil {
+		return nil, fmt.Errorf("failed to register subsystem %s: %w", systemID, err)
+	}
+
+	return subsystem, nil
+}
+
+// insertLocked adds an initialized subsystem to the registry. The caller must
+// hold pr.mu.
+func (pr *PluginRegistryImpl) insertLocked(subsystem interfaces.Subsystem) error {
+	systemID := subsystem.SystemID()
 	if _, exists := pr.subsystems[systemID]; exists {
 		return fmt.Errorf("subsystem %s already registered", systemID)
 	}
 
+	order, err := OrderSubsystems(append(pr.orderedLocked(), subsystem), nil)
+	if err != nil {
+		return fmt.Errorf("failed to register subsystem %s: %w", systemID, err)
+	}
+
 	pr.subsystems[systemID] = subsystem
+	pr.order = order
+	pr.generation = NextGeneration()
+
+	pr.conflicts = overrideConflicts(order)
+	pr.warnConflictsLocked(systemID)
+
 	return nil
 }
 
-// Unregister unregisters a subsystem
+// Unregister unregisters a subsystem and shuts it down if it is a
+// LifecycleSubsystem. The subsystem is unregistered even if Shutdown fails.
+// Shutdown is called after the registry lock is released.
 func (pr *PluginRegistryImpl) Unregister(systemID string) error {
-	pr.mu.Lock()
-	defer pr.mu.Unlock()
-
 	if systemID == "" {
 		return fmt.Errorf("system ID cannot be empty")
 	}
 
-	if _, exists := pr.subsystems[systemID]; !exists {
+	pr.mu.Lock()
+	subsystem, exists := pr.subsystems[systemID]
+	if !exists {
+		pr.mu.Unlock()
 		return fmt.Errorf("subsystem %s not found", systemID)
 	}
 
 	delete(pr.subsystems, systemID)
+	pr.reorderLocked()
+	pr.generation = NextGeneration()
+	pr.mu.Unlock()
+
+	return shutdownSubsystem(subsystem)
+}
+
+// negotiateLocked returns the subsystem if its API level is supported, or the
+// subsystem adapted by the shim for its level. The caller must hold pr.mu.
+func (pr *PluginRegistryImpl) negotiateLocked(subsystem interfaces.Subsystem) (interfaces.Subsystem, error) {
+	systemID := subsystem.SystemID()
+
+	level := apiLevelOf(subsystem)
+	if pr.supportsLocked(level) {
+		return subsystem, nil
+	}
+
+	shim, exists := pr.shims[level]
+	if !exists {
+		return nil, pr.apiLevelError(systemID, level, "no shim is registered for it")
+	}
+
+	shimmed, err := shim(subsystem)
+	if err != nil {
+		return nil, pr.apiLevelError(systemID, level, fmt.Sprintf("shim failed: %v", err))
+	}
+
+	if shimmed == nil || shimmed.SystemID() != systemID {
+		return nil, pr.apiLevelError(systemID, level, "shim changed the system ID")
+	}
+
+	if shimmedLevel := apiLevelOf(shimmed); !pr.supportsLocked(shimmedLevel) {
+		return nil, pr.apiLevelError(systemID, shimmedLevel, "shim returned an unsupported API level")
+	}
+
+	return shimmed, nil
+}
+
+// supportsLocked checks if an API level is in the supported range. The caller must hold pr.mu.
+func (pr *PluginRegistryImpl) supportsLocked(level int64) bool {
+	return level >= pr.minAPILevel && level <= pr.maxAPILevel
+}
+
+// apiLevelError reports a subsystem whose API level cannot be supported
+func (pr *PluginRegistryImpl) apiLevelError(systemID string, level int64, reason string) *interfaces.ActorCoreError {
+	return &interfaces.ActorCoreError{
+		Type: constants.ErrorTypeSystem,
+		Code: constants.ErrorCodeAPILevelUnsupported,
+		Message: fmt.Sprintf("subsystem %s API level %d is outside the supported range %d-%d: %s",
+			systemID, level, pr.minAPILevel, pr.maxAPILevel, reason),
+		System: systemID,
+		Context: map[string]interface{}{
+			"api_level":     level,
+			"min_api_level": pr.minAPILevel,
+			"max_api_level": pr.maxAPILevel,
+		},
+		Timestamp: time.Now(),
+	}
+}
+
+// apiLevelOf returns the API level a subsystem declares, or constants.APICurrent
+func apiLevelOf(subsystem interfaces.Subsystem) int64 {
+	if versioned, ok := subsystem.(interfaces.VersionedSubsystem); ok {
+		return versioned.APILevel()
+	}
+	return constants.APICurrent
+}
+
+// SetAPILevelRange sets the API levels accepted by Register. Registered
+// subsystems are not re-checked; Validate reports those outside the range.
+func (pr *PluginRegistryImpl) SetAPILevelRange(minLevel, maxLevel int64) error {
+	if minLevel < 1 || minLevel > maxLevel {
+		return fmt.Errorf("invalid API level range %d-%d", minLevel, maxLevel)
+	}
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	pr.minAPILevel = minLevel
+	pr.maxAPILevel = maxLevel
+	return nil
+}
+
+// GetAPILevelRange returns the API levels accepted by Register
+func (pr *PluginRegistryImpl) GetAPILevelRange() (int64, int64) {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	return pr.minAPILevel, pr.maxAPILevel
+}
+
+// RegisterShim registers a shim for subsystems built against an API level
+// outside the supported range
+func (pr *PluginRegistryImpl) RegisterShim(apiLevel int64, shim interfaces.SubsystemShim) error {
+	if shim == nil {
+		return fmt.Errorf("shim cannot be nil")
+	}
+
+	pr.mu.Lock()
+	defer pr.mu.Unlock()
+
+	pr.shims[apiLevel] = shim
 	return nil
 }
 
@@ -81,30 +265,123 @@ func (pr *PluginRegistryImpl) GetAll() []interfaces.Subsystem {
 	return subsystems
 }
 
-// GetByPriority returns subsystems ordered by priority
+// GetByPriority returns subsystems in dependency order, higher priority first
+// where unconstrained, ties by system ID
 func (pr *PluginRegistryImpl) GetByPriority() []interfaces.Subsystem {
 	pr.mu.RLock()
 	defer pr.mu.RUnlock()
 
+	return pr.orderedLocked()
+}
+
+// orderedLocked returns a copy of the subsystem order. The caller must hold pr.mu.
+func (pr *PluginRegistryImpl) orderedLocked() []interfaces.Subsystem {
+	ordered := make([]interfaces.Subsystem, len(pr.order))
+	copy(ordered, pr.order)
+	return ordered
+}
+
+// reorderLocked recomputes the subsystem order and conflicts after a removal.
+// The caller must hold pr.mu.
+func (pr *PluginRegistryImpl) reorderLocked() {
 	subsystems := make([]interfaces.Subsystem, 0, len(pr.subsystems))
 	for _, subsystem := range pr.subsystems {
 		subsystems = append(subsystems, subsystem)
 	}
 
-	// Sort by priority (higher priority first)
-	sort.Slice(subsystems, func(i, j int) bool {
-		return subsystems[i].Priority() > subsystems[j].Priority()
-	})
+	// Removing subsystems cannot introduce a cycle
+	pr.order, _ = OrderSubsystems(subsystems, nil)
+	pr.conflicts = overrideConflicts(pr.order)
+}
 
-	return subsystems
+// warnConflictsLocked logs the conflicts involving a subsystem. The caller must hold pr.mu.
+func (pr *PluginRegistryImpl) warnConflictsLocked(systemID string) {
+	if pr.logger == nil {
+		return
+	}
+
+	for _, conflict := range pr.conflicts {
+		for _, system := range conflict.Systems {
+			if system != systemID {
+				continue
+			}
+
+			pr.logger.Warn("subsystems write OVERRIDE to the same dimension at the same priority",
+				interfaces.StringField("dimension", conflict.Dimension),
+				interfaces.Int64Field("priority", conflict.Priority),
+				interfaces.StringField("systems", strings.Join(conflict.Systems, ",")))
+			break
+		}
+	}
 }
 
-// Clear clears all registered subsystems
-func (pr *PluginRegistryImpl) Clear() {
+// GetConflicts returns the OVERRIDE conflicts between registered subsystems
+func (pr *PluginRegistryImpl) GetConflicts() []interfaces.PluginConflict {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	conflicts := make([]interfaces.PluginConflict, len(pr.conflicts))
+	copy(conflicts, pr.conflicts)
+	return conflicts
+}
+
+// SetLogger sets the logger used to warn about OVERRIDE conflicts
+func (pr *PluginRegistryImpl) SetLogger(logger interfaces.Logger) {
 	pr.mu.Lock()
 	defer pr.mu.Unlock()
 
+	pr.logger = logger
+}
+
+// Clear clears all registered subsystems, shutting down LifecycleSubsystems.
+// Use Close to observe Shutdown errors.
+func (pr *PluginRegistryImpl) Clear() {
+	_ = pr.Close()
+}
+
+// Close unregisters all subsystems and shuts down LifecycleSubsystems in
+// reverse dependency order, returning every Shutdown error. Shutdown is called
+// after the registry lock is released.
+func (pr *PluginRegistryImpl) Close() error {
+	pr.mu.Lock()
+	subsystems := pr.orderedLocked()
+
 	pr.subsystems = make(map[string]interfaces.Subsystem)
+	pr.order = nil
+	pr.conflicts = nil
+	pr.generation = NextGeneration()
+	pr.mu.Unlock()
+
+	var errs []error
+	for i := len(subsystems) - 1; i >= 0; i-- {
+		if err := shutdownSubsystem(subsystems[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// shutdownSubsystem shuts down a subsystem if it is a LifecycleSubsystem
+func shutdownSubsystem(subsystem interfaces.Subsystem) error {
+	lifecycle, ok := subsystem.(interfaces.LifecycleSubsystem)
+	if !ok {
+		return nil
+	}
+
+	if err := lifecycle.Shutdown(); err != nil {
+		return fmt.Errorf("failed to shut down subsystem %s: %w", subsystem.SystemID(), err)
+	}
+
+	return nil
+}
+
+// GetGeneration returns the generation, bumped on every register and unregister
+func (pr *PluginRegistryImpl) GetGeneration() int64 {
+	pr.mu.RLock()
+	defer pr.mu.RUnlock()
+
+	return pr.generation
 }
 
 // Count returns the number of registered subsystems
@@ -236,6 +513,18 @@ func (pr *PluginRegistryImpl) Validate() error {
 		if subsystem.SystemID() == "" {
 			return fmt.Errorf("subsystem %s has empty system ID", systemID)
 		}
+
+		if level := apiLevelOf(subsystem); !pr.supportsLocked(level) {
+			return pr.apiLevelError(systemID, level, "registered before the range changed")
+		}
+
+		if dependent, ok := subsystem.(interfaces.DependentSubsystem); ok {
+			for _, dependency := range dependent.DependsOn() {
+				if _, exists := pr.subsystems[dependency]; !exists {
+					return fmt.Errorf("subsystem %s depends on unregistered subsystem %s", systemID, dependency)
+				}
+			}
+		}
 	}
 
 	return nil
@@ -0,0 +1,289 @@
+package registry
+
+import (
+	"chaos-actor-module/packages/actor-core/interfaces"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// PluginManifest declares the subsystems a plugin registry is built from
+type PluginManifest struct {
+	// MinAPILevel and MaxAPILevel override the supported API-level range when set
+	MinAPILevel int64 `json:"min_api_level,omitempty"`
+	MaxAPILevel int64 `json:"max_api_level,omitempty"`
+
+	// Subsystems are the subsystems to instantiate
+	Subsystems []PluginManifestEntry `json:"subsystems"`
+}
+
+// PluginManifestEntry declares a subsystem to instantiate
+type PluginManifestEntry struct {
+	// SystemID is the system ID the subsystem must report
+	SystemID string `json:"id"`
+
+	// Factory is the name of the registered factory, defaulting to SystemID
+	Factory string `json:"factory,omitempty"`
+
+	// Priority is the priority the subsystem must report, if not zero
+	Priority int64 `json:"priority,omitempty"`
+
+	// Enabled is false for subsystems that are declared but not instantiated
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// Config is passed to the factory
+	Config map[string]interface{} `json:"config,omitempty"`
+}
+
+// IsEnabled checks if the entry is enabled. Entries are enabled unless
+// explicitly disabled.
+func (pme *PluginManifestEntry) IsEnabled() bool {
+	return pme.Enabled == nil || *pme.Enabled
+}
+
+// GetFactory returns the factory name
+func (pme *PluginManifestEntry) GetFactory() string {
+	if pme.Factory == "" {
+		return pme.SystemID
+	}
+	return pme.Factory
+}
+
+// SubsystemFactory creates a subsystem from its manifest entry
+type SubsystemFactory func(entry PluginManifestEntry) (interfaces.Subsystem, error)
+
+// PluginLoader builds plugin registries from manifests using subsystem
+// factories registered by name
+type PluginLoader struct {
+	factories    map[string]SubsystemFactory
+	shims        map[int64]interfaces.SubsystemShim
+	configLoader *ConfigLoaderImpl
+	mu           sync.RWMutex
+}
+
+// NewPluginLoader creates a new plugin loader
+func NewPluginLoader() *PluginLoader {
+	return &PluginLoader{
+		factories:    make(map[string]SubsystemFactory),
+		shims:        make(map[int64]interfaces.SubsystemShim),
+		configLoader: NewConfigLoader().(*ConfigLoaderImpl),
+	}
+}
+
+// RegisterFactory registers a subsystem factory by name
+func (pl *PluginLoader) RegisterFactory(name string, factory SubsystemFactory) error {
+	if name == "" {
+		return fmt.Errorf("factory name cannot be empty")
+	}
+
+	if factory == nil {
+		return fmt.Errorf("factory cannot be nil")
+	}
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	if _, exists := pl.factories[name]; exists {
+		return fmt.Errorf("factory %s already registered", name)
+	}
+
+	pl.factories[name] = factory
+	return nil
+}
+
+// GetFactoryNames returns the names of all registered factories
+func (pl *PluginLoader) GetFactoryNames() []string {
+	pl.mu.RLock()
+	defer pl.mu.RUnlock()
+
+	names := make([]string, 0, len(pl.factories))
+	for name := range pl.factories {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// RegisterShim registers an API-level shim on every registry the loader builds
+func (pl *PluginLoader) RegisterShim(apiLevel int64, shim interfaces.SubsystemShim) error {
+	if shim == nil {
+		return fmt.Errorf("shim cannot be nil")
+	}
+
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	pl.shims[apiLevel] = shim
+	return nil
+}
+
+// LoadManifest loads a manifest from a YAML or JSON file
+func (pl *PluginLoader) LoadManifest(filePath string) (*PluginManifest, error) {
+	config, err := pl.configLoader.Load(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load plugin manifest: %w", err)
+	}
+
+	return ParsePluginManifest(config)
+}
+
+// ParsePluginManifest parses a manifest from configuration
+func ParsePluginManifest(config map[string]interface{}) (*PluginManifest, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config cannot be nil")
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("invalid plugin manifest: %w", err)
+	}
+
+	var manifest PluginManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("invalid plugin manifest: %w", err)
+	}
+
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+// Validate validates the manifest
+func (pm *PluginManifest) Validate() error {
+	if pm.MinAPILevel < 0 || pm.MaxAPILevel < 0 {
+		return fmt.Errorf("invalid plugin manifest: API levels cannot be negative")
+	}
+
+	seen := make(map[string]bool, len(pm.Subsystems))
+	for i, entry := range pm.Subsystems {
+		if entry.SystemID == "" {
+			return fmt.Errorf("invalid plugin manifest: subsystem %d has no id", i)
+		}
+
+		if seen[entry.SystemID] {
+			return fmt.Errorf("invalid plugin manifest: subsystem %s declared twice", entry.SystemID)
+		}
+		seen[entry.SystemID] = true
+	}
+
+	return nil
+}
+
+// Build creates a plugin registry holding the manifest's enabled subsystems.
+// If any subsystem cannot be created or registered, or depends on a subsystem
+// that is not enabled, the build is rolled back: a subsystem the factory
+// created but that could not be registered is shut down, the subsystems
+// already registered are unregistered, and an error is returned.
+func (pl *PluginLoader) Build(manifest *PluginManifest) (interfaces.PluginRegistry, error) {
+	if manifest == nil {
+		return nil, fmt.Errorf("manifest cannot be nil")
+	}
+
+	pl.mu.RLock()
+	defer pl.mu.RUnlock()
+
+	pluginRegistry := NewPluginRegistry().(*PluginRegistryImpl)
+
+	minLevel, maxLevel := pluginRegistry.GetAPILevelRange()
+	if manifest.MinAPILevel != 0 {
+		minLevel = manifest.MinAPILevel
+	}
+	if manifest.MaxAPILevel != 0 {
+		maxLevel = manifest.MaxAPILevel
+	}
+	if err := pluginRegistry.SetAPILevelRange(minLevel, maxLevel); err != nil {
+		return nil, fmt.Errorf("invalid plugin manifest: %w", err)
+	}
+
+	for apiLevel, shim := range pl.shims {
+		if err := pluginRegistry.RegisterShim(apiLevel, shim); err != nil {
+			return nil, err
+		}
+	}
+
+	registered := make([]string, 0, len(manifest.Subsystems))
+	for _, entry := range manifest.Subsystems {
+		if !entry.IsEnabled() {
+			continue
+		}
+
+		subsystem, err := pl.createLocked(entry)
+		if err == nil {
+			err = pluginRegistry.Register(subsystem)
+		}
+
+		if err != nil {
+			return nil, errors.Join(err, rollbackBuild(pluginRegistry, registered, subsystem))
+		}
+		registered = append(registered, entry.SystemID)
+	}
+
+	// Dependencies may be declared in any order, so they are checked once all
+	// subsystems are registered
+	if err := pluginRegistry.Validate(); err != nil {
+		return nil, errors.Join(fmt.Errorf("invalid plugin manifest: %w", err), rollbackBuild(pluginRegistry, registered, nil))
+	}
+
+	return pluginRegistry, nil
+}
+
+// rollbackBuild undoes a failed Build. failed, the subsystem that could not be
+// registered, is shut down if it was created; the registered subsystems are
+// then unregistered, most recent first.
+func rollbackBuild(pluginRegistry *PluginRegistryImpl, registered []string, failed interfaces.Subsystem) error {
+	var errs []error
+	if failed != nil {
+		errs = append(errs, shutdownSubsystem(failed))
+	}
+
+	for i := len(registered) - 1; i >= 0; i-- {
+		errs = append(errs, pluginRegistry.Unregister(registered[i]))
+	}
+
+	return errors.Join(errs...)
+}
+
+// LoadFromFile loads a manifest from a file and builds its plugin registry
+func (pl *PluginLoader) LoadFromFile(filePath string) (interfaces.PluginRegistry, error) {
+	manifest, err := pl.LoadManifest(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return pl.Build(manifest)
+}
+
+// createLocked creates the subsystem for a manifest entry and checks that it
+// reports the declared system ID and priority. A subsystem failing the checks
+// is returned with the error, so that it can be shut down. The caller must
+// hold pl.mu.
+func (pl *PluginLoader) createLocked(entry PluginManifestEntry) (interfaces.Subsystem, error) {
+	factory, exists := pl.factories[entry.GetFactory()]
+	if !exists {
+		return nil, fmt.Errorf("subsystem %s: factory %s not registered", entry.SystemID, entry.GetFactory())
+	}
+
+	subsystem, err := factory(entry)
+	if err != nil {
+		return nil, fmt.Errorf("subsystem %s: factory %s failed: %w", entry.SystemID, entry.GetFactory(), err)
+	}
+
+	if subsystem == nil {
+		return nil, fmt.Errorf("subsystem %s: factory %s returned nil", entry.SystemID, entry.GetFactory())
+	}
+
+	if subsystem.SystemID() != entry.SystemID {
+		return subsystem, fmt.Errorf("subsystem %s: factory %s created subsystem %s", entry.SystemID, entry.GetFactory(), subsystem.SystemID())
+	}
+
+	if entry.Priority != 0 && subsystem.Priority() != entry.Priority {
+		return subsystem, fmt.Errorf("subsystem %s: factory %s ignored priority %d", entry.SystemID, entry.GetFactory(), entry.Priority)
+	}
+
+	return subsystem, nil
+}
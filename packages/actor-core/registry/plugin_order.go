@@ -0,0 +1,222 @@
+package registry
+
+import (
+	"chaos-actor-module/packages/actor-core/enums"
+	"chaos-actor-module/packages/actor-core/interfaces"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OrderSubsystems returns subsystems in a stable topological order of their
+// DependentSubsystem constraints. Among subsystems free to run, higher
+// priority goes first and ties are broken by system ID. priority overrides
+// Subsystem.Priority when not nil. An error naming the cycle is returned if
+// the constraints cannot be satisfied.
+func OrderSubsystems(subsystems []interfaces.Subsystem, priority func(interfaces.Subsystem) int64) ([]interfaces.Subsystem, error) {
+	if priority == nil {
+		priority = func(subsystem interfaces.Subsystem) int64 {
+			return subsystem.Priority()
+		}
+	}
+
+	byID := make(map[string]interfaces.Subsystem, len(subsystems))
+	priorities := make(map[string]int64, len(subsystems))
+	for _, subsystem := range subsystems {
+		byID[subsystem.SystemID()] = subsystem
+		priorities[subsystem.SystemID()] = priority(subsystem)
+	}
+
+	edges := dependencyEdges(byID)
+
+	indegree := make(map[string]int, len(byID))
+	for _, targets := range edges {
+		for target := range targets {
+			indegree[target]++
+		}
+	}
+
+	less := func(a, b string) bool {
+		if priorities[a] != priorities[b] {
+			return priorities[a] > priorities[b]
+		}
+		return a < b
+	}
+
+	ready := make([]string, 0, len(byID))
+	for systemID := range byID {
+		if indegree[systemID] == 0 {
+			ready = append(ready, systemID)
+		}
+	}
+	sort.Slice(ready, func(i, j int) bool { return less(ready[i], ready[j]) })
+
+	ordered := make([]interfaces.Subsystem, 0, len(byID))
+	for len(ready) > 0 {
+		systemID := ready[0]
+		ready = ready[1:]
+		ordered = append(ordered, byID[systemID])
+
+		for target := range edges[systemID] {
+			indegree[target]--
+			if indegree[target] != 0 {
+				continue
+			}
+
+			// Keep ready sorted
+			at := sort.Search(len(ready), func(i int) bool { return less(target, ready[i]) })
+			ready = append(ready, "")
+			copy(ready[at+1:], ready[at:])
+			ready[at] = target
+		}
+	}
+
+	if len(ordered) < len(byID) {
+		return nil, fmt.Errorf("subsystem dependency cycle: %s", strings.Join(findCycle(edges, indegree), " -> "))
+	}
+
+	return ordered, nil
+}
+
+// dependencyEdges returns the "runs before" edges between the given
+// subsystems. Constraints naming unknown subsystems are dropped.
+func dependencyEdges(byID map[string]interfaces.Subsystem) map[string]map[string]bool {
+	edges := make(map[string]map[string]bool, len(byID))
+	addEdge := func(from, to string) {
+		if _, exists := byID[from]; !exists {
+			return
+		}
+		if _, exists := byID[to]; !exists {
+			return
+		}
+		if edges[from] == nil {
+			edges[from] = make(map[string]bool)
+		}
+		edges[from][to] = true
+	}
+
+	for systemID, subsystem := range byID {
+		dependent, ok := subsystem.(interfaces.DependentSubsystem)
+		if !ok {
+			continue
+		}
+
+		for _, dependency := range dependent.DependsOn() {
+			addEdge(dependency, systemID)
+		}
+		for _, after := range dependent.After() {
+			addEdge(after, systemID)
+		}
+		for _, before := range dependent.Before() {
+			addEdge(systemID, before)
+		}
+	}
+
+	return edges
+}
+
+// findCycle returns a cycle among the subsystems left with incoming edges
+// after a topological sort, starting and ending with the same system ID
+func findCycle(edges map[string]map[string]bool, indegree map[string]int) []string {
+	remaining := make([]string, 0)
+	for systemID, degree := range indegree {
+		if degree > 0 {
+			remaining = append(remaining, systemID)
+		}
+	}
+	sort.Strings(remaining)
+
+	// Every remaining subsystem has a remaining predecessor, so walking
+	// predecessors from any of them must revisit one
+	predecessor := make(map[string]string, len(remaining))
+	for from, targets := range edges {
+		for to := range targets {
+			if indegree[from] > 0 && indegree[to] > 0 {
+				if current, exists := predecessor[to]; !exists || from < current {
+					predecessor[to] = from
+				}
+			}
+		}
+	}
+
+	visited := make(map[string]int)
+	path := make([]string, 0)
+	for systemID := remaining[0]; ; systemID = predecessor[systemID] {
+		if at, seen := visited[systemID]; seen {
+			cycle := path[at:]
+			// Report the cycle in run order, starting from its lowest system ID
+			for i, j := 0, len(cycle)-1; i < j; i, j = i+1, j-1 {
+				cycle[i], cycle[j] = cycle[j], cycle[i]
+			}
+			lowest := 0
+			for i := range cycle {
+				if cycle[i] < cycle[lowest] {
+					lowest = i
+				}
+			}
+			rotated := make([]string, 0, len(cycle)+1)
+			rotated = append(rotated, cycle[lowest:]...)
+			rotated = append(rotated, cycle[:lowest]...)
+			return append(rotated, rotated[0])
+		}
+		visited[systemID] = len(path)
+		path = append(path, systemID)
+	}
+}
+
+// overrideConflicts returns the dimensions that more than one DimensionWriter
+// writes with the OVERRIDE bucket at the same priority
+func overrideConflicts(subsystems []interfaces.Subsystem) []interfaces.PluginConflict {
+	type conflictKey struct {
+		dimension string
+		priority  int64
+	}
+
+	writers := make(map[conflictKey]map[string]bool)
+	for _, subsystem := range subsystems {
+		writer, ok := subsystem.(interfaces.DimensionWriter)
+		if !ok {
+			continue
+		}
+
+		for _, write := range writer.WritesDimensions() {
+			if write.Bucket != enums.BucketOverride {
+				continue
+			}
+
+			key := conflictKey{dimension: write.Dimension, priority: subsystem.Priority()}
+			if writers[key] == nil {
+				writers[key] = make(map[string]bool)
+			}
+			writers[key][subsystem.SystemID()] = true
+		}
+	}
+
+	conflicts := make([]interfaces.PluginConflict, 0)
+	for key, systems := range writers {
+		if len(systems) < 2 {
+			continue
+		}
+
+		systemIDs := make([]string, 0, len(systems))
+		for systemID := range systems {
+			systemIDs = append(systemIDs, systemID)
+		}
+		sort.Strings(systemIDs)
+
+		conflicts = append(conflicts, interfaces.PluginConflict{
+			Dimension: key.dimension,
+			Priority:  key.priority,
+			Systems:   systemIDs,
+		})
+	}
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Dimension != conflicts[j].Dimension {
+			return conflicts[i].Dimension < conflicts[j].Dimension
+		}
+		return conflicts[i].Priority > conflicts[j].Priority
+	})
+
+	return conflicts
+}
@@ -0,0 +1,274 @@
+package registry
+
+import (
+	"chaos-actor-module/packages/actor-core/constants"
+	"chaos-actor-module/packages/actor-core/enums"
+	"chaos-actor-module/packages/actor-core/interfaces"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RealmCapTable holds the caps of each cultivation realm and produces them as
+// realm-scoped cap contributions
+type RealmCapTable struct {
+	layer         string
+	realms        []RealmCaps
+	contributions []interfaces.CapContribution
+}
+
+// RealmCaps are the caps of a realm and of its sub-realms
+type RealmCaps struct {
+	// ID is the realm ID, the first segment of the realm path
+	ID string
+
+	// Name is the display name
+	Name string
+
+	// Caps apply throughout the realm
+	Caps map[string]interfaces.Caps
+
+	// SubRealms maps sub-realm paths below the realm, such as "so" for a stage
+	// or "pill_grade/cuc" for an entry of another group, to their caps
+	SubRealms map[string]map[string]interfaces.Caps
+}
+
+// NewRealmCapTableFromFile loads a realm cap table from a YAML or JSON file
+func NewRealmCapTableFromFile(filePath string) (*RealmCapTable, error) {
+	config, err := NewConfigLoader().Load(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load realm cap table: %w", err)
+	}
+
+	return ParseRealmCapTable(config)
+}
+
+// ParseRealmCapTable parses a realm cap table from configuration:
+//
+//	layer: REALM                # optional, the layer the caps are scoped to
+//	realms:
+//	  - id: foundation
+//	    name: Foundation Establishment
+//	    caps:   { qi_purity: [0.25, 0.70] }           # path "foundation"
+//	    stages:
+//	      so:   { dantian_capacity: [12000, 20000] }  # path "foundation/so"
+//	    pill_grade:
+//	      cuc:  { dantian_stability: [240, 400] }     # path "foundation/pill_grade/cuc"
+//
+// Entries of stages and per_tier are sub-realms directly below the realm;
+// entries of any other group are sub-realms below the group's name.
+func ParseRealmCapTable(config map[string]interface{}) (*RealmCapTable, error) {
+	if config == nil {
+		return nil, fmt.Errorf("config cannot be nil")
+	}
+
+	table := &RealmCapTable{layer: enums.LayerRealm.String()}
+
+	if layerData, exists := config["layer"]; exists {
+		layer, ok := layerData.(string)
+		if !ok || layer == "" {
+			return nil, fmt.Errorf("invalid realm cap table: layer must be a non-empty string")
+		}
+		table.layer = layer
+	}
+
+	realmsData, ok := config["realms"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid realm cap table: realms must be an array")
+	}
+
+	seen := make(map[string]bool, len(realmsData))
+	for i, realmData := range realmsData {
+		realmMap, ok := realmData.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid realm cap table: realms[%d] must be an object", i)
+		}
+
+		realm, err := parseRealmCaps(realmMap)
+		if err != nil {
+			return nil, fmt.Errorf("invalid realm cap table: realms[%d]: %w", i, err)
+		}
+
+		if seen[realm.ID] {
+			return nil, fmt.Errorf("invalid realm cap table: realm %s declared twice", realm.ID)
+		}
+		seen[realm.ID] = true
+
+		table.realms = append(table.realms, realm)
+	}
+
+	table.contributions = table.buildContributions()
+	return table, nil
+}
+
+// parseRealmCaps parses a realm entry
+func parseRealmCaps(config map[string]interface{}) (RealmCaps, error) {
+	realm := RealmCaps{SubRealms: make(map[string]map[string]interfaces.Caps)}
+
+	id, ok := config["id"].(string)
+	if !ok || id == "" || strings.Contains(id, "/") {
+		return realm, fmt.Errorf("id must be a non-empty string without '/'")
+	}
+	realm.ID = id
+
+	if nameData, exists := config["name"]; exists {
+		name, ok := nameData.(string)
+		if !ok {
+			return realm, fmt.Errorf("realm %s: name must be a string", id)
+		}
+		realm.Name = name
+	}
+
+	// Sort the groups so that path conflicts are reported deterministically
+	groups := make([]string, 0, len(config))
+	for key := range config {
+		groups = append(groups, key)
+	}
+	sort.Strings(groups)
+
+	for _, group := range groups {
+		switch group {
+		case "id", "name":
+			continue
+
+		case "caps":
+			caps, err := parseCapRanges(config[group])
+			if err != nil {
+				return realm, fmt.Errorf("realm %s: caps: %w", id, err)
+			}
+			realm.Caps = caps
+			continue
+		}
+
+		entries, ok := config[group].(map[string]interface{})
+		if !ok {
+			return realm, fmt.Errorf("realm %s: %s must map sub-realms to caps", id, group)
+		}
+
+		for key, entry := range entries {
+			path := group + "/" + key
+			if group == "stages" || group == "per_tier" {
+				path = key
+			}
+
+			if _, exists := realm.SubRealms[path]; exists {
+				return realm, fmt.Errorf("realm %s: sub-realm %s declared twice", id, path)
+			}
+
+			caps, err := parseCapRanges(entry)
+			if err != nil {
+				return realm, fmt.Errorf("realm %s: %s.%s: %w", id, group, key, err)
+			}
+			realm.SubRealms[path] = caps
+		}
+	}
+
+	return realm, nil
+}
+
+// parseCapRanges parses a map of dimensions to [min, max] ranges
+func parseCapRanges(data interface{}) (map[string]interfaces.Caps, error) {
+	ranges, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("must map dimensions to [min, max] ranges")
+	}
+
+	caps := make(map[string]interfaces.Caps, len(ranges))
+	for dimension, rangeData := range ranges {
+		bounds, ok := rangeData.([]interface{})
+		if !ok || len(bounds) != 2 {
+			return nil, fmt.Errorf("%s must be a [min, max] range", dimension)
+		}
+
+		lower, lowerOK := bounds[0].(float64)
+		upper, upperOK := bounds[1].(float64)
+		if !lowerOK || !upperOK {
+			return nil, fmt.Errorf("%s range must be numeric", dimension)
+		}
+
+		if lower > upper {
+			return nil, fmt.Errorf("%s range min (%v) is greater than max (%v)", dimension, lower, upper)
+		}
+
+		caps[dimension] = interfaces.Caps{Min: lower, Max: upper}
+	}
+
+	return caps, nil
+}
+
+// buildContributions converts the table into BASELINE min and max cap
+// contributions, sorted by realm path, dimension and kind. Each contribution's
+// priority is the depth of its realm path, so that a sub-realm's range takes
+// precedence over its realm's.
+func (rct *RealmCapTable) buildContributions() []interfaces.CapContribution {
+	var contributions []interfaces.CapContribution
+	add := func(path string, caps map[string]interfaces.Caps) {
+		priority := int64(strings.Count(path, "/") + 1)
+		for dimension, cap := range caps {
+			for _, bound := range []struct {
+				kind  string
+				value float64
+			}{{"min", cap.Min}, {"max", cap.Max}} {
+				contributions = append(contributions, interfaces.CapContribution{
+					System:    constants.SystemIDRealmCaps,
+					Dimension: dimension,
+					Mode:      enums.CapModeBaseline,
+					Kind:      bound.kind,
+					Value:     bound.value,
+					Priority:  priority,
+					Scope:     rct.layer,
+					Realm:     path,
+				})
+			}
+		}
+	}
+
+	for _, realm := range rct.realms {
+		add(realm.ID, realm.Caps)
+		for subRealm, caps := range realm.SubRealms {
+			add(realm.ID+"/"+subRealm, caps)
+		}
+	}
+
+	sort.Slice(contributions, func(i, j int) bool {
+		a, b := contributions[i], contributions[j]
+		if a.Realm != b.Realm {
+			return a.Realm < b.Realm
+		}
+		if a.Dimension != b.Dimension {
+			return a.Dimension < b.Dimension
+		}
+		return a.Kind < b.Kind
+	})
+
+	return contributions
+}
+
+// GetLayer returns the layer the table's caps are scoped to
+func (rct *RealmCapTable) GetLayer() string {
+	return rct.layer
+}
+
+// GetRealms returns the realms in table order
+func (rct *RealmCapTable) GetRealms() []RealmCaps {
+	realms := make([]RealmCaps, len(rct.realms))
+	copy(realms, rct.realms)
+	return realms
+}
+
+// GetRealm returns a realm by ID
+func (rct *RealmCapTable) GetRealm(id string) (RealmCaps, bool) {
+	for _, realm := range rct.realms {
+		if realm.ID == id {
+			return realm, true
+		}
+	}
+	return RealmCaps{}, false
+}
+
+// GetContributions returns the cap contributions of every realm and sub-realm
+func (rct *RealmCapTable) GetContributions() []interfaces.CapContribution {
+	contributions := make([]interfaces.CapContribution, len(rct.contributions))
+	copy(contributions, rct.contributions)
+	return contributions
+}
@@ -1,21 +1,57 @@
 package services
 
 import (
+	"chaos-actor-module/packages/actor-core/constants"
+	"chaos-actor-module/packages/actor-core/enums"
 	"chaos-actor-module/packages/actor-core/interfaces"
+	"chaos-actor-module/packages/actor-core/registry"
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"runtime"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 )
 
 // AggregatorImpl implements the Aggregator interface
 type AggregatorImpl struct {
-	combinerRegistry interfaces.CombinerRegistry
-	capsProvider     interfaces.CapsProvider
-	pluginRegistry   interfaces.PluginRegistry
-	cache            interfaces.Cache
-	mu               sync.RWMutex
+	combinerRegistry       interfaces.CombinerRegistry
+	capsProvider           interfaces.CapsProvider
+	pluginRegistry         interfaces.PluginRegistry
+	derivedFormulaRegistry interfaces.DerivedFormulaRegistry
+	cache                  interfaces.Cache
+	errorPolicies          map[string]enums.ErrorPolicy
+	defaultErrorPolicy     enums.ErrorPolicy
+	subsystemWorkers       int
+	batchWorkers           int
+	cacheTTL               time.Duration
+	generation             int64
+	mu                     sync.RWMutex
+
+	// cacheKeys holds the current snapshot cache key per actor, so snapshots
+	// can be looked up and invalidated by actor ID
+	cacheKeys map[string]cacheKey
+	keysMu    sync.Mutex
+
+	// outputs holds the last successful output per actor per subsystem
+	outputs *outputStore
+
+	// subsystemCalls tracks the subsystem calls made against the current
+	// plugin registry, including calls abandoned after a timeout
+	subsystemCalls *sync.WaitGroup
+
+	// configureLocks serialize Configure and Contribute per subsystem while
+	// an actor's own configuration is applied
+	configureLocks map[string]*sync.Mutex
+	configureMu    sync.Mutex
+
+	metrics *metricsRecorder
+
+	// conditions caches parsed contribution conditions by source
+	conditions *conditionCache
 }
 
 // NewAggregator creates a new aggregator
@@ -25,11 +61,24 @@ func NewAggregator(
 	pluginRegistry interfaces.PluginRegistry,
 	cache interfaces.Cache,
 ) interfaces.Aggregator {
+	cacheTTL, _ := time.ParseDuration(constants.DefaultCacheTTL)
+
 	return &AggregatorImpl{
-		combinerRegistry: combinerRegistry,
-		capsProvider:     capsProvider,
-		pluginRegistry:   pluginRegistry,
-		cache:            cache,
+		combinerRegistry:   combinerRegistry,
+		capsProvider:       capsProvider,
+		pluginRegistry:     pluginRegistry,
+		cache:              cache,
+		errorPolicies:      make(map[string]enums.ErrorPolicy),
+		defaultErrorPolicy: enums.ErrorPolicySkipAndRecord,
+		batchWorkers:       runtime.GOMAXPROCS(0),
+		cacheTTL:           cacheTTL,
+		generation:         registry.NextGeneration(),
+		cacheKeys:          make(map[string]cacheKey),
+		outputs:            newOutputStore(cacheTTL),
+		subsystemCalls:     &sync.WaitGroup{},
+		configureLocks:     make(map[string]*sync.Mutex),
+		metrics:            newMetricsRecorder(),
+		conditions:         newConditionCache(constants.DefaultConditionCacheSize),
 	}
 }
 
@@ -38,101 +87,887 @@ func (a *AggregatorImpl) Resolve(ctx context.Context, actor *interfaces.Actor) (
 	return a.ResolveWithContext(ctx, actor, nil)
 }
 
-// ResolveWithContext resolves actor stats with additional context
+// ResolveWithContext resolves actor stats with additional context. The
+// context map may filter contributions by tag (constants.ResolveContextIncludeTags,
+// constants.ResolveContextExcludeTags) and request a provenance index
+// (constants.ResolveContextProvenance), and override the realms realm-scoped
+// caps are filtered by (constants.ResolveContextRealm). Its numeric and
+// boolean values are visible to CONDITIONAL contribution conditions. Snapshots resolved with a non-empty
+// context are neither served from nor stored in the cache, since the context
+// can change the result.
 func (a *AggregatorImpl) ResolveWithContext(ctx context.Context, actor *interfaces.Actor, context map[string]interface{}) (*interfaces.Snapshot, error) {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
+	if actor == nil {
+		err := fmt.Errorf("actor cannot be nil")
+		a.metrics.recordRequest("", 0, err)
+		return nil, err
+	}
+
+	start := time.Now()
+
+	var snapshot *interfaces.Snapshot
+	options, err := parseResolveOptions(context)
+	if err == nil {
+		if len(context) == 0 {
+			snapshot, err = a.resolveActor(ctx, actor)
+		} else {
+			snapshot, err = a.resolve(ctx, actor, nil, options, nil)
+		}
+	}
+	a.metrics.recordRequest(actor.ID, time.Since(start), err)
+
+	return snapshot, err
+}
+
+// resolveActor returns the cached snapshot for an actor, or resolves and caches it
+func (a *AggregatorImpl) resolveActor(ctx context.Context, actor *interfaces.Actor) (*interfaces.Snapshot, error) {
+	// The key is taken before resolving so that a registry change during the
+	// resolve leaves the snapshot under the old, unreachable generation
+	key := a.snapshotCacheKey(actor.ID, actor.Version)
+	if snapshot, exists := a.cachedSnapshot(key); exists {
+		return snapshot, nil
+	}
+
+	snapshot, err := a.resolve(ctx, actor, nil, resolveOptions{}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	a.cacheSnapshot(key, snapshot)
+
+	return snapshot, nil
+}
+
+// cacheKey is the cache key of an actor's latest snapshot
+type cacheKey struct {
+	key     string
+	version int64
+}
+
+// configGeneration returns the latest generation of the aggregator and its
+// registries. Generations are globally increasing, so any change to any of
+// them changes the result.
+func (a *AggregatorImpl) configGeneration() int64 {
+	generation := a.generation
+	observe := func(g int64) {
+		if g > generation {
+			generation = g
+		}
+	}
+
+	if a.combinerRegistry != nil {
+		observe(a.combinerRegistry.GetGeneration())
+	}
+	if a.capsProvider != nil {
+		observe(a.capsProvider.GetGeneration())
+	}
+	if a.pluginRegistry != nil {
+		observe(a.pluginRegistry.GetGeneration())
+	}
+	if a.derivedFormulaRegistry != nil {
+		observe(a.derivedFormulaRegistry.GetGeneration())
+	}
+
+	return generation
+}
+
+// snapshotCacheKey returns the cache key of an actor version under the current
+// registry generation, or the actor's own generation if it was marked dirty later
+func (a *AggregatorImpl) snapshotCacheKey(actorID string, version int64) string {
+	generation := a.configGeneration()
+	if actorGeneration := a.outputs.generation(actorID); actorGeneration > generation {
+		generation = actorGeneration
+	}
+
+	return fmt.Sprintf("%s%s:v%d:g%d", constants.CacheKeySnapshotPrefix, actorID, version, generation)
+}
+
+// cachedSnapshot returns the snapshot cached under key
+func (a *AggregatorImpl) cachedSnapshot(key string) (*interfaces.Snapshot, bool) {
+	if a.cache == nil {
+		return nil, false
+	}
+
+	if cached, exists := a.cache.Get(key); exists {
+		if snapshot, ok := cached.(*interfaces.Snapshot); ok {
+			return snapshot, true
+		}
+	}
+
+	return nil, false
+}
+
+// cacheSnapshot caches a resolved snapshot under key, replacing the actor's
+// previous entry. Partial results are not cached so that a recovered
+// subsystem is picked up on the next resolve.
+func (a *AggregatorImpl) cacheSnapshot(key string, snapshot *interfaces.Snapshot) {
+	if a.cache == nil || snapshot.HasFailures() {
+		return
+	}
+
+	a.keysMu.Lock()
+	defer a.keysMu.Unlock()
+
+	if previous, exists := a.cacheKeys[snapshot.ActorID]; exists && previous.key != key {
+		a.cache.Delete(previous.key)
+	}
+
+	a.cache.Set(key, snapshot, a.cacheTTL.String())
+	a.cacheKeys[snapshot.ActorID] = cacheKey{key: key, version: snapshot.Version}
+}
+
+// Explain resolves the actor without the cache and returns a trace of how the
+// given dimension was merged, capped and clamped
+func (a *AggregatorImpl) Explain(ctx context.Context, actor *interfaces.Actor, dimension string) (*interfaces.AggregationTrace, error) {
+	return a.ExplainWithContext(ctx, actor, dimension, nil)
+}
+
+// ExplainWithContext explains a dimension as resolved by ResolveWithContext
+// with the given context
+func (a *AggregatorImpl) ExplainWithContext(ctx context.Context, actor *interfaces.Actor, dimension string, context map[string]interface{}) (*interfaces.AggregationTrace, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
 	if actor == nil {
 		return nil, fmt.Errorf("actor cannot be nil")
 	}
 
-	// Check cache first
-	if a.cache != nil {
-		if cached, exists := a.cache.Get(actor.ID); exists {
-			if snapshot, ok := cached.(*interfaces.Snapshot); ok {
-				return snapshot, nil
+	if dimension == "" {
+		return nil, fmt.Errorf("dimension cannot be empty")
+	}
+
+	trace := &interfaces.AggregationTrace{
+		ActorID:       actor.ID,
+		Dimension:     dimension,
+		Contributions: make([]interfaces.ContributionTrace, 0),
+		Steps:         make([]interfaces.AggregationStep, 0),
+		ClampSource:   "none",
+		CreatedAt:     time.Now(),
+	}
+
+	options, err := parseResolveOptions(context)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := a.resolve(ctx, actor, nil, options, trace)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, exists := snapshot.Primary[dimension]; exists {
+		trace.Kind = "primary"
+	} else if _, exists := snapshot.Derived[dimension]; exists {
+		trace.Kind = "derived"
+	} else {
+		return nil, fmt.Errorf("dimension %s was not resolved for actor %s", dimension, actor.ID)
+	}
+
+	return trace, nil
+}
+
+// resolve runs the aggregation pipeline for an actor. Subsystems with a result
+// in prefetched are not invoked again. Contributions are filtered by the
+// options' tag filters and CONDITIONAL contribution conditions before caps and
+// stats are computed. When trace is not nil, the steps for the traced
+// dimension are recorded into it.
+func (a *AggregatorImpl) resolve(ctx context.Context, actor *interfaces.Actor, prefetched map[string]contributionResult, options resolveOptions, trace *interfaces.AggregationTrace) (*interfaces.Snapshot, error) {
+	start := time.Now()
+
+	// Collect subsystem outputs
+	collected, err := a.collectOutputs(ctx, actor, prefetched, options, trace)
+	if err != nil {
+		return nil, err
+	}
+
+	// Realm-scoped caps follow the context's realm over the actor's
+	if options.realms != nil {
+		ctx = WithRealms(ctx, options.realms...)
+	}
+
+	// Merging is retried without the outputs a bucket could not merge, as far
+	// as their subsystems' error policies allow
+	var effectiveCaps interfaces.EffectiveCaps
+	var primaryStats, derivedStats map[string]float64
+	for {
+		effectiveCaps, primaryStats, derivedStats, err = a.merge(ctx, actor, collected.outputs, trace)
+
+		var bucketErr *bucketError
+		if err == nil || !errors.As(err, &bucketErr) {
+			break
+		}
+
+		if err := a.dropContributors(actor, collected, bucketErr, options, err); err != nil {
+			return nil, err
+		}
+		resetAggregationTrace(trace)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	a.retainFresh(actor, collected)
+	outputs := collected.outputs
+
+	if trace != nil {
+		if err := a.traceLayerCaps(ctx, actor, outputs, effectiveCaps, trace); err != nil {
+			return nil, err
+		}
+	}
+
+	var provenance map[string][]string
+	if options.provenance {
+		var formulaDimensions []string
+		if a.derivedFormulaRegistry != nil {
+			// The order was already validated while aggregating derived stats
+			formulaDimensions, _ = a.derivedFormulaRegistry.GetEvaluationOrder()
+		}
+		provenance = buildProvenance(outputs, formulaDimensions, derivedStats)
+	}
+
+	// Create snapshot
+	snapshot := &interfaces.Snapshot{
+		ActorID:             actor.ID,
+		Primary:             primaryStats,
+		Derived:             derivedStats,
+		CapsUsed:            effectiveCaps,
+		Context:             a.mergeContextModifiers(outputs),
+		Failures:            collected.failures,
+		Provenance:          provenance,
+		Version:             actor.Version,
+		Timestamp:           time.Now(),
+		SubsystemsProcessed: collected.systems,
+	}
+	snapshot.ProcessingTime = snapshot.Timestamp.Sub(start)
+
+	// Explanations re-resolve for a trace, so they are not counted as resolves
+	if trace == nil {
+		a.capsProvider.RecordResolve(snapshot)
+	}
+
+	return snapshot, nil
+}
+
+// merge computes the effective caps and the primary and derived stats of the
+// outputs
+func (a *AggregatorImpl) merge(ctx context.Context, actor *interfaces.Actor, outputs []*interfaces.SubsystemOutput, trace *interfaces.AggregationTrace) (interfaces.EffectiveCaps, map[string]float64, map[string]float64, error) {
+	// Calculate effective caps
+	effectiveCaps, err := a.capsProvider.EffectiveCapsAcrossLayers(ctx, actor, outputs)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to calculate effective caps: %w", err)
+	}
+
+	// Aggregate primary stats
+	primaryStats, err := a.aggregatePrimaryStats(outputs, effectiveCaps, trace)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to aggregate primary stats: %w", err)
+	}
+
+	// Aggregate derived stats
+	derivedStats, err := a.aggregateDerivedStats(outputs, primaryStats, effectiveCaps, trace)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to aggregate derived stats: %w", err)
+	}
+
+	return effectiveCaps, primaryStats, derivedStats, nil
+}
+
+// collection holds the outputs collected for a resolve. outputs and systems
+// are aligned: systems[i] is the subsystem that produced outputs[i].
+type collection struct {
+	outputs  []*interfaces.SubsystemOutput
+	systems  []string
+	failures []interfaces.SubsystemFailure
+
+	// fresh are the outputs computed by this resolve, keyed by system ID. They
+	// are only retained once they have been merged.
+	fresh map[string]freshOutput
+}
+
+// freshOutput is an output computed by a resolve, with what it is retained under
+type freshOutput struct {
+	output     *interfaces.SubsystemOutput
+	cacheKey   string
+	generation int64
+}
+
+// dropContributors applies the error policies of the subsystems whose outputs
+// contributed to the bucket bucketErr reports: FAIL_FAST returns the error,
+// USE_LAST_GOOD replaces the output with the last good one once, and
+// otherwise the output is dropped. The failures are recorded in collected.
+// If no output contributed, as when only a derived formula did, err is
+// returned.
+func (a *AggregatorImpl) dropContributors(actor *interfaces.Actor, collected *collection, bucketErr *bucketError, options resolveOptions, err error) error {
+	env := conditionEnvironment{actor: actor, context: options.context}
+	contributed := false
+
+	for i := 0; i < len(collected.outputs); i++ {
+		if !bucketErr.contributedBy(collected.outputs[i]) {
+			continue
+		}
+		contributed = true
+
+		systemID := collected.systems[i]
+		policy := a.errorPolicyFor(systemID)
+		failure := interfaces.SubsystemFailure{
+			System: systemID,
+			Policy: policy.String(),
+			Error:  newSubsystemError(actor, systemID, policy, bucketErr),
+		}
+
+		if policy == enums.ErrorPolicyFailFast {
+			return failure.Error
+		}
+
+		delete(collected.fresh, systemID)
+
+		// A subsystem that already failed, and is merged from its last good
+		// output, is not given another one
+		failed := false
+		for j := range collected.failures {
+			if collected.failures[j].System == systemID {
+				collected.failures[j] = failure
+				failed = true
+			}
+		}
+
+		if policy == enums.ErrorPolicyUseLastGood && !failed {
+			if lastGood, exists := a.outputs.lastGood(actor.ID, systemID); exists {
+				if lastGood, err := a.applyConditions(options.filterOutput(lastGood), env, nil); err == nil {
+					collected.outputs[i] = lastGood
+					failure.UsedLastGood = true
+					collected.failures = append(collected.failures, failure)
+					continue
+				}
 			}
 		}
+
+		if !failed {
+			collected.failures = append(collected.failures, failure)
+		}
+		collected.outputs = append(collected.outputs[:i], collected.outputs[i+1:]...)
+		collected.systems = append(collected.systems[:i], collected.systems[i+1:]...)
+		i--
 	}
 
-	// Get all subsystems
-	subsystems := a.pluginRegistry.GetByPriority()
+	if !contributed {
+		return err
+	}
 
-	// Collect subsystem outputs
-	outputs := make([]*interfaces.SubsystemOutput, 0, len(subsystems))
+	return nil
+}
+
+// retainFresh retains the outputs computed by a resolve that were merged
+func (a *AggregatorImpl) retainFresh(actor *interfaces.Actor, collected *collection) {
+	for systemID, fresh := range collected.fresh {
+		a.outputs.store(actor.ID, systemID, fresh.output, actor.Version, fresh.cacheKey, fresh.generation)
+	}
+}
+
+// resetAggregationTrace clears what merging recorded into trace, before the
+// merge is retried
+func resetAggregationTrace(trace *interfaces.AggregationTrace) {
+	if trace == nil {
+		return
+	}
+
+	trace.Rule = nil
+	trace.Contributions = trace.Contributions[:0]
+	trace.Steps = trace.Steps[:0]
+	trace.Candidate = 0
+	trace.Clamp = nil
+	trace.ClampSource = "none"
+	trace.Final = 0
+}
+
+// contributionResult holds the outcome of a single Contribute call
+type contributionResult struct {
+	output *interfaces.SubsystemOutput
+	err    error
+
+	// reused is set when output was retained from an earlier resolve
+	reused bool
+
+	// generation is the actor generation the output was computed against
+	generation int64
+}
+
+// collectOutputs invokes every subsystem that runs for the actor and whose
+// retained output cannot be reused, filters each output by the options' tag
+// filters and CONDITIONAL contribution conditions, and applies each
+// subsystem's error policy to failures, including outputs rejected by
+// checkOutput, outputs with caps scoped to unknown layers and outputs with
+// invalid conditions.
+// Results are processed in priority order regardless of the order in which
+// subsystems complete. The IDs of the subsystems whose output was used are
+// collected alongside the outputs.
+func (a *AggregatorImpl) collectOutputs(ctx context.Context, actor *interfaces.Actor, prefetched map[string]contributionResult, options resolveOptions, trace *interfaces.AggregationTrace) (*collection, error) {
+	subsystems := subsystemsFor(a.pluginRegistry.GetByPriority(), actor)
+	results := a.contributeAll(ctx, actor, subsystems, prefetched)
+	a.metrics.recordSubsystemsProcessed(len(subsystems))
+
+	minAPILevel, maxAPILevel := a.pluginRegistry.GetAPILevelRange()
+	env := conditionEnvironment{actor: actor, context: options.context}
+
+	collected := &collection{
+		outputs: make([]*interfaces.SubsystemOutput, 0, len(subsystems)),
+		systems: make([]string, 0, len(subsystems)),
+		fresh:   make(map[string]freshOutput),
+	}
+
+	for i, subsystem := range subsystems {
+		systemID := subsystem.SystemID()
+		policy := a.errorPolicyFor(systemID)
+
+		output, err := results[i].output, results[i].err
+		if err == nil && !results[i].reused {
+			err = checkOutput(systemID, output, minAPILevel, maxAPILevel)
+		}
+
+		if err == nil && output != nil {
+			if scopeErr := a.capsProvider.ValidateScopes(output.Caps); scopeErr != nil {
+				err = &scopeError{err: scopeErr}
+			}
+		}
+
+		var used *interfaces.SubsystemOutput
+		if err == nil && output != nil {
+			used, err = a.applyConditions(options.filterOutput(output), env, trace)
+		}
 
-	for _, subsystem := range subsystems {
-		output, err := subsystem.Contribute(ctx, actor)
 		if err != nil {
-			// Log error but continue with other subsystems
+			failure := interfaces.SubsystemFailure{
+				System: systemID,
+				Policy: policy.String(),
+				Error:  newSubsystemError(actor, systemID, policy, err),
+			}
+
+			switch policy {
+			case enums.ErrorPolicyFailFast:
+				return nil, failure.Error
+			case enums.ErrorPolicyUseLastGood:
+				if lastGood, exists := a.outputs.lastGood(actor.ID, systemID); exists {
+					if lastGood, err := a.applyConditions(options.filterOutput(lastGood), env, trace); err == nil {
+						collected.outputs = append(collected.outputs, lastGood)
+						collected.systems = append(collected.systems, systemID)
+						failure.UsedLastGood = true
+					}
+				}
+			}
+
+			collected.failures = append(collected.failures, failure)
+			continue
+		}
+
+		if !results[i].reused && output != nil {
+			cacheKey, _ := subsystemCacheKey(subsystem, actor)
+			collected.fresh[systemID] = freshOutput{output: output, cacheKey: cacheKey, generation: results[i].generation}
+		}
+
+		if output != nil {
+			collected.outputs = append(collected.outputs, used)
+			collected.systems = append(collected.systems, systemID)
+		}
+	}
+
+	return collected, nil
+}
+
+// contributeAll calls Contribute on every subsystem without a prefetched
+// result or a reusable retained output, sequentially or on a bounded worker
+// pool, and returns the results aligned with subsystems
+func (a *AggregatorImpl) contributeAll(ctx context.Context, actor *interfaces.Actor, subsystems []interfaces.Subsystem, prefetched map[string]contributionResult) []contributionResult {
+	results := make([]contributionResult, len(subsystems))
+
+	// Taken before any subsystem is invoked, so that a MarkDirty during the
+	// resolve leaves the new outputs dirty
+	generation := a.outputs.generation(actor.ID)
+
+	pending := make([]int, 0, len(subsystems))
+	for i, subsystem := range subsystems {
+		if result, exists := prefetched[subsystem.SystemID()]; exists {
+			results[i] = result
 			continue
 		}
 
-		if output != nil {
-			outputs = append(outputs, output)
-		}
+		if output, exists := a.reusableOutput(actor, subsystem); exists {
+			results[i] = contributionResult{output: output, reused: true}
+			continue
+		}
+
+		results[i].generation = generation
+		pending = append(pending, i)
+	}
+
+	workers := a.subsystemWorkers
+	if workers > len(pending) {
+		workers = len(pending)
+	}
+
+	if workers <= 1 {
+		for _, i := range pending {
+			results[i].output, results[i].err = a.contribute(ctx, actor, subsystems[i])
+		}
+		return results
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i].output, results[i].err = a.contribute(ctx, actor, subsystems[i])
+			}
+		}()
+	}
+
+	for _, i := range pending {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// reusableOutput returns the retained output of a subsystem if it is still
+// valid for the actor, and records the lookup in the subsystem's metrics
+func (a *AggregatorImpl) reusableOutput(actor *interfaces.Actor, subsystem interfaces.Subsystem) (*interfaces.SubsystemOutput, bool) {
+	cacheKey, cacheable := subsystemCacheKey(subsystem, actor)
+	if !cacheable {
+		return nil, false
+	}
+
+	output, exists := a.outputs.reusable(actor.ID, subsystem.SystemID(), actor.Version, cacheKey)
+	a.metrics.recordSubsystemCache(subsystem.SystemID(), exists)
+
+	return output, exists
+}
+
+// contribute validates the actor and calls Contribute with the actor's own
+// configuration, enforcing the subsystem's own timeout if it declares one, and
+// records the call in the subsystem's metrics
+func (a *AggregatorImpl) contribute(ctx context.Context, actor *interfaces.Actor, subsystem interfaces.Subsystem) (*interfaces.SubsystemOutput, error) {
+	var result contributionResult
+
+	start := time.Now()
+	err := callWithTimeout(ctx, a.subsystemCalls, subsystem, func(ctx context.Context) {
+		if result.err = validateActor(subsystem, actor); result.err != nil {
+			return
+		}
+
+		configurable, ok := subsystem.(interfaces.ConfigurableSubsystem)
+		config, hasConfig := actor.GetSubsystemConfig(subsystem.SystemID())
+		if !ok || !hasConfig {
+			result.output, result.err = subsystem.Contribute(ctx, actor)
+			return
+		}
+
+		// Configuration is subsystem-wide, so it is held until Contribute returns
+		lock := a.configureLock(subsystem.SystemID())
+		lock.Lock()
+		defer lock.Unlock()
+
+		if err := configurable.Configure(config); err != nil {
+			result.err = fmt.Errorf("failed to configure subsystem: %w", err)
+			return
+		}
+		result.output, result.err = subsystem.Contribute(ctx, actor)
+	})
+	if err == nil {
+		err = result.err
+	}
+	a.metrics.recordSubsystem(subsystem.SystemID(), time.Since(start), err)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return result.output, nil
+}
+
+// configureLock returns the lock serializing Configure and Contribute for a subsystem
+func (a *AggregatorImpl) configureLock(systemID string) *sync.Mutex {
+	a.configureMu.Lock()
+	defer a.configureMu.Unlock()
+
+	lock, exists := a.configureLocks[systemID]
+	if !exists {
+		lock = &sync.Mutex{}
+		a.configureLocks[systemID] = lock
+	}
+	return lock
+}
+
+// subsystemsFor returns the registered subsystems that run for the actor, in
+// dependency order with the actor's priority overrides applied (higher first,
+// ties by system ID)
+func subsystemsFor(registered []interfaces.Subsystem, actor *interfaces.Actor) []interfaces.Subsystem {
+	if len(actor.Subsystems) > 0 {
+		// The registry rejected cycles, and overrides do not add constraints
+		if ordered, err := registry.OrderSubsystems(registered, func(subsystem interfaces.Subsystem) int64 {
+			return priorityFor(subsystem, actor)
+		}); err == nil {
+			registered = ordered
+		}
+	}
+
+	subsystems := make([]interfaces.Subsystem, 0, len(registered))
+	for _, subsystem := range registered {
+		if runsFor(subsystem, actor) {
+			subsystems = append(subsystems, subsystem)
+		}
+	}
+
+	return subsystems
+}
+
+// runsFor reports whether a subsystem runs for the actor: it must be enabled
+// in the actor's subsystem list, if any, and ConditionalSubsystems may opt out
+func runsFor(subsystem interfaces.Subsystem, actor *interfaces.Actor) bool {
+	if !actor.IsSubsystemEnabled(subsystem.SystemID()) {
+		return false
+	}
+
+	conditional, ok := subsystem.(interfaces.ConditionalSubsystem)
+	return !ok || conditional.IsActive(actor)
+}
+
+// priorityFor returns the actor's priority override for a subsystem, or the
+// registered priority if the actor has none
+func priorityFor(subsystem interfaces.Subsystem, actor *interfaces.Actor) int64 {
+	if ref, exists := actor.GetSubsystem(subsystem.SystemID()); exists && ref.Priority != 0 {
+		return ref.Priority
+	}
+	return subsystem.Priority()
+}
+
+// validationError marks an actor rejected by ValidatingSubsystem.Validate
+type validationError struct {
+	err error
+}
+
+func (e *validationError) Error() string {
+	return fmt.Sprintf("actor validation failed: %v", e.err)
+}
+
+func (e *validationError) Unwrap() error {
+	return e.err
+}
+
+// validateActor runs ValidatingSubsystem.Validate if the subsystem implements it
+func validateActor(subsystem interfaces.Subsystem, actor *interfaces.Actor) error {
+	validating, ok := subsystem.(interfaces.ValidatingSubsystem)
+	if !ok {
+		return nil
+	}
+
+	if err := validating.Validate(actor); err != nil {
+		return &validationError{err: err}
+	}
+	return nil
+}
+
+// incompatibleOutputError marks an output rejected by checkOutput
+type incompatibleOutputError struct {
+	meta   interfaces.SubsystemMeta
+	reason string
+}
+
+func (e *incompatibleOutputError) Error() string {
+	return fmt.Sprintf("incompatible output: %s", e.reason)
+}
+
+// scopeError reports cap contributions whose scope is not a known layer
+type scopeError struct {
+	err error
+}
+
+func (e *scopeError) Error() string {
+	return fmt.Sprintf("invalid cap scope: %v", e.err)
+}
+
+func (e *scopeError) Unwrap() error {
+	return e.err
+}
+
+// conditionError reports a CONDITIONAL contribution whose condition is invalid
+type conditionError struct {
+	err error
+}
+
+func (e *conditionError) Error() string {
+	return fmt.Sprintf("invalid condition: %v", e.err)
+}
+
+func (e *conditionError) Unwrap() error {
+	return e.err
+}
+
+// bucketError reports the contributions of a bucket that could not be merged
+type bucketError struct {
+	dimension string
+	bucket    enums.Bucket
+	systems   []string
+	err       error
+
+	// derived is set for derived dimensions
+	derived bool
+}
+
+func (e *bucketError) Error() string {
+	return fmt.Sprintf("%s bucket of %s from %s: %v", e.bucket, e.dimension, strings.Join(e.systems, ", "), e.err)
+}
+
+func (e *bucketError) Unwrap() error {
+	return e.err
+}
+
+// contributedBy checks if the output contributed to the failed bucket
+func (e *bucketError) contributedBy(output *interfaces.SubsystemOutput) bool {
+	contributions := output.Primary
+	if e.derived {
+		contributions = output.Derived
+	}
+
+	for _, contribution := range contributions {
+		if contribution.Dimension == e.dimension && contribution.Bucket == e.bucket {
+			return true
+		}
+	}
+	return false
+}
+
+// checkOutput rejects outputs that declare themselves incompatible, were
+// produced by another system or declare an API level outside the supported
+// range. Outputs without metadata (no Meta.System) are accepted.
+func checkOutput(systemID string, output *interfaces.SubsystemOutput, minAPILevel, maxAPILevel int64) error {
+	if output == nil || output.Meta.System == "" {
+		return nil
 	}
 
-	// Calculate effective caps
-	effectiveCaps, err := a.capsProvider.EffectiveCapsAcrossLayers(ctx, actor, outputs)
-	if err != nil {
-		return nil, fmt.Errorf("failed to calculate effective caps: %w", err)
+	meta := output.Meta
+	switch {
+	case meta.System != systemID:
+		return &incompatibleOutputError{meta: meta, reason: fmt.Sprintf("produced by system %s", meta.System)}
+	case !meta.Compatible:
+		return &incompatibleOutputError{meta: meta, reason: "marked incompatible"}
+	case meta.APILevel != 0 && (meta.APILevel < minAPILevel || meta.APILevel > maxAPILevel):
+		return &incompatibleOutputError{meta: meta, reason: fmt.Sprintf("API level %d is outside the supported range %d-%d", meta.APILevel, minAPILevel, maxAPILevel)}
 	}
 
-	// Aggregate primary stats
-	primaryStats, err := a.aggregatePrimaryStats(outputs, effectiveCaps)
-	if err != nil {
-		return nil, fmt.Errorf("failed to aggregate primary stats: %w", err)
+	return nil
+}
+
+// callWithTimeout runs call with the subsystem's own timeout if it declares
+// one. A subsystem that ignores its context is abandoned once the deadline
+// passes; call's results must then not be read. calls tracks the call until it
+// returns, even if it was abandoned.
+func callWithTimeout(ctx context.Context, calls *sync.WaitGroup, subsystem interfaces.Subsystem, call func(ctx context.Context)) error {
+	calls.Add(1)
+
+	timed, ok := subsystem.(interfaces.TimeoutSubsystem)
+	if !ok || timed.Timeout() <= 0 {
+		defer calls.Done()
+		call(ctx)
+		return nil
 	}
 
-	// Aggregate derived stats
-	derivedStats, err := a.aggregateDerivedStats(outputs, primaryStats, effectiveCaps)
-	if err != nil {
-		return nil, fmt.Errorf("failed to aggregate derived stats: %w", err)
+	ctx, cancel := context.WithTimeout(ctx, timed.Timeout())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer calls.Done()
+		call(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
 
-	// Create snapshot
-	snapshot := &interfaces.Snapshot{
-		ActorID:   actor.ID,
-		Primary:   primaryStats,
-		Derived:   derivedStats,
-		CapsUsed:  effectiveCaps,
-		Version:   actor.Version,
-		CreatedAt: time.Now(),
+// newSubsystemError creates the structured error for a failed subsystem contribution
+func newSubsystemError(actor *interfaces.Actor, systemID string, policy enums.ErrorPolicy, err error) *interfaces.ActorCoreError {
+	errorType, code := constants.ErrorTypeSystem, constants.ErrorCodeSubsystemContributionFailed
+	errorContext := map[string]interface{}{
+		"actor_id": actor.ID,
+		"policy":   policy.String(),
+		"cause":    err.Error(),
 	}
 
-	// Cache the result
-	if a.cache != nil {
-		a.cache.Set(actor.ID, snapshot, "1h")
+	var invalid *validationError
+	var incompatible *incompatibleOutputError
+	var scope *scopeError
+	var condition *conditionError
+	var bucket *bucketError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		errorType, code = constants.ErrorTypePerformance, constants.ErrorCodeOperationTimeout
+	case errors.As(err, &invalid):
+		errorType, code = constants.ErrorTypeValidation, constants.ErrorCodeActorValidationFailed
+	case errors.As(err, &scope):
+		errorType, code = constants.ErrorTypeValidation, constants.ErrorCodeInvalidLayerScope
+	case errors.As(err, &condition):
+		errorType, code = constants.ErrorTypeValidation, constants.ErrorCodeInvalidCondition
+	case errors.As(err, &bucket):
+		errorType, code = constants.ErrorTypeValidation, constants.ErrorCodeValueOutOfRange
+		errorContext["dimension"] = bucket.dimension
+		errorContext["bucket"] = bucket.bucket.String()
+	case errors.As(err, &incompatible):
+		code = constants.ErrorCodeIncompatibleOutput
+		errorContext["output_system"] = incompatible.meta.System
+		errorContext["compatible"] = incompatible.meta.Compatible
+		errorContext["api_level"] = incompatible.meta.APILevel
 	}
 
-	return snapshot, nil
+	return &interfaces.ActorCoreError{
+		Type:      errorType,
+		Code:      code,
+		Message:   fmt.Sprintf("subsystem %s failed to contribute for actor %s: %v", systemID, actor.ID, err),
+		System:    systemID,
+		Context:   errorContext,
+		Timestamp: time.Now(),
+	}
 }
 
-// ResolveBatch resolves multiple actors
-func (a *AggregatorImpl) ResolveBatch(ctx context.Context, actors []*interfaces.Actor) ([]*interfaces.Snapshot, error) {
-	if actors == nil {
-		return nil, fmt.Errorf("actors cannot be nil")
+// errorPolicyFor returns the error policy for a subsystem
+func (a *AggregatorImpl) errorPolicyFor(systemID string) enums.ErrorPolicy {
+	if policy, exists := a.errorPolicies[systemID]; exists {
+		return policy
 	}
+	return a.defaultErrorPolicy
+}
 
-	snapshots := make([]*interfaces.Snapshot, 0, len(actors))
-
-	for _, actor := range actors {
-		if actor == nil {
-			continue
+// traceLayerCaps records the within-layer and across-layer caps for the traced dimension
+func (a *AggregatorImpl) traceLayerCaps(ctx context.Context, actor *interfaces.Actor, outputs []*interfaces.SubsystemOutput, effectiveCaps interfaces.EffectiveCaps, trace *interfaces.AggregationTrace) error {
+	for _, layer := range a.capsProvider.GetLayerOrder() {
+		layerCaps, err := a.capsProvider.EffectiveCapsWithinLayer(ctx, actor, outputs, layer)
+		if err != nil {
+			return fmt.Errorf("failed to calculate caps for layer %s: %w", layer, err)
 		}
 
-		snapshot, err := a.Resolve(ctx, actor)
-		if err != nil {
-			// Log error but continue with other actors
-			continue
+		if caps, exists := layerCaps[trace.Dimension]; exists {
+			trace.LayerCaps = append(trace.LayerCaps, interfaces.LayerCapsTrace{Layer: layer, Caps: caps})
 		}
+	}
+
+	trace.AcrossLayerPolicy = a.capsProvider.GetDimensionPolicy(trace.Dimension)
 
-		snapshots = append(snapshots, snapshot)
+	if caps, exists := effectiveCaps[trace.Dimension]; exists {
+		trace.EffectiveCaps = &caps
 	}
 
-	return snapshots, nil
+	return nil
 }
 
 // GetCachedSnapshot returns a cached snapshot
@@ -140,17 +975,16 @@ func (a *AggregatorImpl) GetCachedSnapshot(actorID string) (*interfaces.Snapshot
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
-	if a.cache == nil {
-		return nil, false
-	}
+	a.keysMu.Lock()
+	current, exists := a.cacheKeys[actorID]
+	a.keysMu.Unlock()
 
-	if cached, exists := a.cache.Get(actorID); exists {
-		if snapshot, ok := cached.(*interfaces.Snapshot); ok {
-			return snapshot, true
-		}
+	// Snapshots cached under an older registry generation are stale
+	if !exists || current.key != a.snapshotCacheKey(actorID, current.version) {
+		return nil, false
 	}
 
-	return nil, false
+	return a.cachedSnapshot(current.key)
 }
 
 // InvalidateCache invalidates cache for an actor
@@ -158,8 +992,39 @@ func (a *AggregatorImpl) InvalidateCache(actorID string) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	if a.cache != nil {
-		a.cache.Delete(actorID)
+	a.keysMu.Lock()
+	defer a.keysMu.Unlock()
+
+	if current, exists := a.cacheKeys[actorID]; exists {
+		if a.cache != nil {
+			a.cache.Delete(current.key)
+		}
+		delete(a.cacheKeys, actorID)
+	}
+
+	a.outputs.forget(actorID)
+}
+
+// MarkDirty marks subsystems of an actor dirty, or all of them if no system
+// IDs are given. The next resolve invokes the dirty subsystems again and
+// reuses the retained outputs of the other caching subsystems (see
+// interfaces.CachingSubsystem); subsystems that do not opt in to caching are
+// invoked on every resolve regardless.
+func (a *AggregatorImpl) MarkDirty(actorID string, systemIDs ...string) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	// The new actor generation moves the actor to a new snapshot cache key
+	a.outputs.markDirty(actorID, systemIDs...)
+
+	a.keysMu.Lock()
+	defer a.keysMu.Unlock()
+
+	if current, exists := a.cacheKeys[actorID]; exists {
+		if a.cache != nil {
+			a.cache.Delete(current.key)
+		}
+		delete(a.cacheKeys, actorID)
 	}
 }
 
@@ -168,13 +1033,20 @@ func (a *AggregatorImpl) ClearCache() {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	a.keysMu.Lock()
+	defer a.keysMu.Unlock()
+
 	if a.cache != nil {
 		a.cache.Clear()
 	}
+	a.cacheKeys = make(map[string]cacheKey)
+
+	a.outputs.clear()
+	a.conditions.clear()
 }
 
 // aggregatePrimaryStats aggregates primary stats from subsystem outputs
-func (a *AggregatorImpl) aggregatePrimaryStats(outputs []*interfaces.SubsystemOutput, effectiveCaps interfaces.EffectiveCaps) (map[string]float64, error) {
+func (a *AggregatorImpl) aggregatePrimaryStats(outputs []*interfaces.SubsystemOutput, effectiveCaps interfaces.EffectiveCaps, trace *interfaces.AggregationTrace) (map[string]float64, error) {
 	// Collect all primary contributions
 	contributions := make(map[string][]interfaces.Contribution)
 
@@ -196,21 +1068,9 @@ func (a *AggregatorImpl) aggregatePrimaryStats(outputs []*interfaces.SubsystemOu
 			continue
 		}
 
-		// Get merge rule for this dimension
-		rule, err := a.combinerRegistry.GetRule(dimension)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get rule for dimension %s: %w", dimension, err)
-		}
-
-		// Aggregate contributions
-		value, err := a.aggregateContributions(contribs, rule)
+		value, err := a.aggregateDimension(dimension, contribs, effectiveCaps, traceFor(trace, dimension))
 		if err != nil {
-			return nil, fmt.Errorf("failed to aggregate contributions for dimension %s: %w", dimension, err)
-		}
-
-		// Apply caps
-		if caps, exists := effectiveCaps[dimension]; exists {
-			value = a.applyCaps(value, caps)
+			return nil, err
 		}
 
 		primaryStats[dimension] = value
@@ -219,8 +1079,11 @@ func (a *AggregatorImpl) aggregatePrimaryStats(outputs []*interfaces.SubsystemOu
 	return primaryStats, nil
 }
 
-// aggregateDerivedStats aggregates derived stats from subsystem outputs
-func (a *AggregatorImpl) aggregateDerivedStats(outputs []*interfaces.SubsystemOutput, primaryStats map[string]float64, effectiveCaps interfaces.EffectiveCaps) (map[string]float64, error) {
+// aggregateDerivedStats aggregates derived stats from subsystem outputs.
+// Dimensions with a registered formula are evaluated in dependency order from
+// the primary stats and previously resolved derived stats; the formula result
+// is the base value that subsystem Derived contributions layer on top of.
+func (a *AggregatorImpl) aggregateDerivedStats(outputs []*interfaces.SubsystemOutput, primaryStats map[string]float64, effectiveCaps interfaces.EffectiveCaps, trace *interfaces.AggregationTrace) (map[string]float64, error) {
 	// Collect all derived contributions
 	contributions := make(map[string][]interfaces.Contribution)
 
@@ -234,113 +1097,326 @@ func (a *AggregatorImpl) aggregateDerivedStats(outputs []*interfaces.SubsystemOu
 		}
 	}
 
+	// Formula dimensions in dependency order
+	var formulaOrder []string
+	if a.derivedFormulaRegistry != nil {
+		order, err := a.derivedFormulaRegistry.GetEvaluationOrder()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get derived formula order: %w", err)
+		}
+		formulaOrder = order
+	}
+
+	hasFormula := make(map[string]bool, len(formulaOrder))
+	for _, dimension := range formulaOrder {
+		hasFormula[dimension] = true
+	}
+
+	// Contribution-only dimensions have no dependencies, so they resolve first
+	order := make([]string, 0, len(contributions)+len(formulaOrder))
+	for dimension := range contributions {
+		if !hasFormula[dimension] {
+			order = append(order, dimension)
+		}
+	}
+	sort.Strings(order)
+	order = append(order, formulaOrder...)
+
+	// Values visible to formulas: primary stats plus resolved derived stats
+	values := make(map[string]float64, len(primaryStats)+len(order))
+	for dimension, value := range primaryStats {
+		values[dimension] = value
+	}
+
 	// Aggregate each dimension
 	derivedStats := make(map[string]float64)
 
-	for dimension, contribs := range contributions {
+	for _, dimension := range order {
+		contribs := contributions[dimension]
+
+		if hasFormula[dimension] {
+			base, err := a.derivedFormulaRegistry.Evaluate(dimension, values)
+			if err != nil {
+				return nil, fmt.Errorf("failed to evaluate formula for dimension %s: %w", dimension, err)
+			}
+
+			// The formula result enters the merge as a FLAT base contribution
+			formulaContrib := interfaces.Contribution{
+				Dimension: dimension,
+				Bucket:    "FLAT",
+				Value:     base,
+				System:    constants.SystemIDDerivedFormula,
+			}
+			contribs = append([]interfaces.Contribution{formulaContrib}, contribs...)
+		}
+
 		if len(contribs) == 0 {
 			continue
 		}
 
-		// Get merge rule for this dimension
-		rule, err := a.combinerRegistry.GetRule(dimension)
+		value, err := a.aggregateDimension(dimension, contribs, effectiveCaps, traceFor(trace, dimension))
 		if err != nil {
-			return nil, fmt.Errorf("failed to get rule for dimension %s: %w", dimension, err)
+			var bucketErr *bucketError
+			if errors.As(err, &bucketErr) {
+				bucketErr.derived = true
+			}
+			return nil, err
 		}
 
-		// Aggregate contributions
-		value, err := a.aggregateContributions(contribs, rule)
-		if err != nil {
-			return nil, fmt.Errorf("failed to aggregate contributions for dimension %s: %w", dimension, err)
+		derivedStats[dimension] = value
+		values[dimension] = value
+	}
+
+	return derivedStats, nil
+}
+
+// mergeContextModifiers merges the context modifier packs of all outputs per context type
+func (a *AggregatorImpl) mergeContextModifiers(outputs []*interfaces.SubsystemOutput) map[string]interfaces.ModifierPack {
+	contextModifiers := make(map[string]interfaces.ModifierPack)
+
+	for _, output := range outputs {
+		if output == nil {
+			continue
 		}
 
-		// Apply caps
-		if caps, exists := effectiveCaps[dimension]; exists {
-			value = a.applyCaps(value, caps)
+		for contextType, modifier := range output.Context {
+			contextModifiers[contextType] = contextModifiers[contextType].Merge(modifier)
 		}
+	}
 
-		derivedStats[dimension] = value
+	return contextModifiers
+}
+
+// aggregateDimension merges the contributions for a single dimension using its
+// merge rule, tapers the result along the rule's soft cap and clamps it to the
+// effective caps, falling back to the rule's ClampDefault when no layer
+// produced a cap for the dimension
+func (a *AggregatorImpl) aggregateDimension(dimension string, contribs []interfaces.Contribution, effectiveCaps interfaces.EffectiveCaps, trace *interfaces.AggregationTrace) (float64, error) {
+	// Get merge rule for this dimension
+	rule, err := a.combinerRegistry.GetRule(dimension)
+	if err != nil {
+		return 0.0, fmt.Errorf("failed to get rule for dimension %s: %w", dimension, err)
 	}
 
-	return derivedStats, nil
+	// Aggregate contributions
+	value, err := a.aggregateContributions(contribs, rule, trace)
+	if err != nil {
+		return 0.0, fmt.Errorf("failed to aggregate contributions for dimension %s: %w", dimension, err)
+	}
+
+	// Soft caps taper the merged value before hard caps clamp it
+	if rule != nil && rule.SoftCap != nil {
+		value = rule.SoftCap.Apply(value)
+		trace.AddStep("SOFT_CAP", value)
+	}
+
+	if trace != nil {
+		trace.Rule = rule
+		trace.Candidate = value
+	}
+
+	// Apply caps
+	if caps, exists := effectiveCaps[dimension]; exists {
+		value = a.applyCaps(value, caps)
+		if trace != nil {
+			trace.Clamp = &caps
+			trace.ClampSource = "effective_caps"
+		}
+	} else if hasClampDefault(rule) {
+		clamp := rule.GetDefaultClampRange()
+		value = a.applyCaps(value, clamp)
+		if trace != nil {
+			trace.Clamp = &clamp
+			trace.ClampSource = "clamp_default"
+		}
+	}
+
+	if trace != nil {
+		trace.Final = value
+	}
+
+	return value, nil
 }
 
-// aggregateContributions aggregates contributions for a dimension
-func (a *AggregatorImpl) aggregateContributions(contribs []interfaces.Contribution, rule *interfaces.MergeRule) (float64, error) {
+// aggregateContributions aggregates contributions for a dimension according to the merge rule
+func (a *AggregatorImpl) aggregateContributions(contribs []interfaces.Contribution, rule *interfaces.MergeRule, trace *interfaces.AggregationTrace) (float64, error) {
 	if len(contribs) == 0 {
 		return 0.0, fmt.Errorf("no contributions provided")
 	}
 
-	// Sort by priority (higher priority first)
-	sort.Slice(contribs, func(i, j int) bool {
+	// Sort by priority (higher priority first), keeping subsystem order on ties
+	sort.SliceStable(contribs, func(i, j int) bool {
 		return contribs[i].Priority > contribs[j].Priority
 	})
 
+	if trace != nil {
+		for _, contrib := range contribs {
+			trace.Contributions = append(trace.Contributions, interfaces.ContributionTrace{
+				System:   contrib.System,
+				Bucket:   contrib.Bucket.String(),
+				Priority: contrib.Priority,
+				Value:    contrib.Value,
+			})
+		}
+	}
+
+	if rule == nil || rule.ShouldUsePipeline() {
+		return a.aggregatePipeline(contribs, rule, trace)
+	}
+
+	return a.aggregateOperator(contribs, enums.Operator(rule.GetOperator()), trace)
+}
+
+// aggregatePipeline runs the bucket pipeline over priority-sorted contributions
+// in the rule's bucket order, recording the running value after each non-empty
+// bucket into trace
+func (a *AggregatorImpl) aggregatePipeline(contribs []interfaces.Contribution, rule *interfaces.MergeRule, trace *interfaces.AggregationTrace) (float64, error) {
 	// Group by bucket
-	buckets := make(map[string][]interfaces.Contribution)
+	buckets := make(map[enums.Bucket][]interfaces.Contribution)
 
 	for _, contrib := range contribs {
 		buckets[contrib.Bucket] = append(buckets[contrib.Bucket], contrib)
 	}
 
+	order, logBase := enums.DefaultBucketOrder(), math.E
+	if rule != nil {
+		order, logBase = rule.GetBucketOrder(), rule.GetLogBase()
+	}
+
 	// Process buckets in order
 	var result float64
 
-	// FLAT bucket (additive)
-	if flatContribs, exists := buckets["FLAT"]; exists {
-		for _, contrib := range flatContribs {
-			result += contrib.Value
+	for _, bucket := range order {
+		bucketContribs, exists := buckets[bucket]
+		if !exists {
+			continue
 		}
-	}
 
-	// MULT bucket (multiplicative)
-	if multContribs, exists := buckets["MULT"]; exists {
-		for _, contrib := range multContribs {
-			result *= contrib.Value
+		value, err := applyBucket(bucket, result, bucketContribs, logBase)
+		if err != nil {
+			return 0.0, err
 		}
+
+		result = value
+		trace.AddStep(bucket.String(), result)
 	}
 
-	// POST_ADD bucket (post-additive)
-	if postAddContribs, exists := buckets["POST_ADD"]; exists {
-		for _, contrib := range postAddContribs {
+	return result, nil
+}
+
+// applyBucket applies the contributions of a bucket to the running value:
+//
+//	FLAT, POST_ADD, CONDITIONAL  result + Σ v
+//	MULT                         result × Π v
+//	EXPONENTIAL                  result ^ v, for each v in turn
+//	LOGARITHMIC                  result × (1 + log_base(1 + Σ v))
+//	OVERRIDE                     v of the highest priority contribution
+func applyBucket(bucket enums.Bucket, result float64, contribs []interfaces.Contribution, logBase float64) (float64, error) {
+	switch bucket {
+	case enums.BucketFlat, enums.BucketPostAdd, enums.BucketConditional:
+		for _, contrib := range contribs {
 			result += contrib.Value
 		}
-	}
 
-	// OVERRIDE bucket (overrides all previous)
-	if overrideContribs, exists := buckets["OVERRIDE"]; exists {
-		if len(overrideContribs) > 0 {
-			// Use the highest priority override
-			result = overrideContribs[0].Value
+	case enums.BucketMult:
+		for _, contrib := range contribs {
+			result *= contrib.Value
 		}
-	}
 
-	// EXPONENTIAL bucket (exponential)
-	if expContribs, exists := buckets["EXPONENTIAL"]; exists {
-		for _, contrib := range expContribs {
-			result = result * (1.0 + contrib.Value)
+	case enums.BucketExponential:
+		for _, contrib := range contribs {
+			result = math.Pow(result, contrib.Value)
 		}
+
+	case enums.BucketLogarithmic:
+		var sum float64
+		for _, contrib := range contribs {
+			sum += contrib.Value
+		}
+		if sum <= -1 {
+			return 0.0, newBucketError(bucket, contribs, fmt.Errorf("contributions sum to %v, want more than -1", sum))
+		}
+		result *= 1 + math.Log(1+sum)/math.Log(logBase)
+
+	case enums.BucketOverride:
+		// Contributions are sorted by priority, highest first
+		result = contribs[0].Value
 	}
 
-	// LOGARITHMIC bucket (logarithmic)
-	if logContribs, exists := buckets["LOGARITHMIC"]; exists {
-		for _, contrib := range logContribs {
-			result = result * (1.0 + contrib.Value/100.0)
+	if math.IsNaN(result) || math.IsInf(result, 0) {
+		return 0.0, newBucketError(bucket, contribs, fmt.Errorf("produced a non-finite value"))
+	}
+
+	return result, nil
+}
+
+// newBucketError creates a bucketError naming the systems of the bucket's contributions
+func newBucketError(bucket enums.Bucket, contribs []interfaces.Contribution, err error) *bucketError {
+	seen := make(map[string]bool, len(contribs))
+	systems := make([]string, 0, len(contribs))
+	for _, contrib := range contribs {
+		if !seen[contrib.System] {
+			seen[contrib.System] = true
+			systems = append(systems, contrib.System)
 		}
 	}
+	sort.Strings(systems)
 
-	// CONDITIONAL bucket (conditional)
-	if condContribs, exists := buckets["CONDITIONAL"]; exists {
-		for _, contrib := range condContribs {
-			// For now, just add the value
-			// In a real implementation, this would evaluate conditions
-			result += contrib.Value
+	return &bucketError{dimension: contribs[0].Dimension, bucket: bucket, systems: systems, err: err}
+}
+
+// aggregateOperator folds all contribution values with the rule operator,
+// ignoring buckets
+func (a *AggregatorImpl) aggregateOperator(contribs []interfaces.Contribution, operator enums.Operator, trace *interfaces.AggregationTrace) (float64, error) {
+	if !operator.IsValid() {
+		return 0.0, fmt.Errorf("invalid operator: %s", operator)
+	}
+
+	var result float64
+
+	switch operator {
+	case enums.OperatorAverage:
+		var sum float64
+		for _, contrib := range contribs {
+			sum += contrib.Value
+		}
+		result = sum / float64(len(contribs))
+	default:
+		// SUM, MAX, MIN, MULTIPLY and INTERSECT (tightest value wins) are
+		// associative, so a left fold over the values is enough
+		result = contribs[0].Value
+		for _, contrib := range contribs[1:] {
+			result = operator.Apply(result, contrib.Value)
 		}
 	}
 
+	trace.AddStep(operator.String(), result)
+
 	return result, nil
 }
 
+// traceFor returns trace if it targets the given dimension, nil otherwise
+func traceFor(trace *interfaces.AggregationTrace, dimension string) *interfaces.AggregationTrace {
+	if trace == nil || trace.Dimension != dimension {
+		return nil
+	}
+	return trace
+}
+
+// hasClampDefault checks if the rule carries a usable default clamp range
+func hasClampDefault(rule *interfaces.MergeRule) bool {
+	if rule == nil {
+		return false
+	}
+
+	clamp := rule.GetDefaultClampRange()
+	if clamp.Min == 0 && clamp.Max == 0 {
+		return false
+	}
+
+	return clamp.Min <= clamp.Max
+}
+
 // applyCaps applies caps to a value
 func (a *AggregatorImpl) applyCaps(value float64, caps interfaces.Caps) float64 {
 	if value < caps.Min {
@@ -355,11 +1431,12 @@ func (a *AggregatorImpl) applyCaps(value float64, caps interfaces.Caps) float64
 }
 
 // SetCombinerRegistry sets the combiner registry
-func (a *AggregatorImpl) SetCombinerRegistry(registry interfaces.CombinerRegistry) {
+func (a *AggregatorImpl) SetCombinerRegistry(combinerRegistry interfaces.CombinerRegistry) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	a.combinerRegistry = registry
+	a.combinerRegistry = combinerRegistry
+	a.generation = registry.NextGeneration()
 }
 
 // SetCapsProvider sets the caps provider
@@ -368,14 +1445,184 @@ func (a *AggregatorImpl) SetCapsProvider(provider interfaces.CapsProvider) {
 	defer a.mu.Unlock()
 
 	a.capsProvider = provider
+	a.generation = registry.NextGeneration()
+}
+
+// SetPluginRegistry sets the plugin registry. Retained subsystem outputs and
+// parsed conditions are dropped, since they came from the previous registry's
+// subsystems.
+func (a *AggregatorImpl) SetPluginRegistry(pluginRegistry interfaces.PluginRegistry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pluginRegistry = pluginRegistry
+	a.generation = registry.NextGeneration()
+	a.outputs.clear()
+	a.conditions.clear()
+}
+
+// SwapPluginRegistry atomically replaces the plugin registry and closes the
+// previous one. Resolves in flight finish against the previous registry
+// before the swap; resolves started afterwards use the new one. The previous
+// registry is closed once its subsystem calls have returned, including calls
+// abandoned after a timeout, so a subsystem that never returns blocks the swap.
+func (a *AggregatorImpl) SwapPluginRegistry(pluginRegistry interfaces.PluginRegistry) error {
+	if pluginRegistry == nil {
+		return fmt.Errorf("plugin registry cannot be nil")
+	}
+
+	a.mu.Lock()
+	previous := a.pluginRegistry
+	previousCalls := a.subsystemCalls
+	a.pluginRegistry = pluginRegistry
+	a.subsystemCalls = &sync.WaitGroup{}
+	a.generation = registry.NextGeneration()
+	a.outputs.clear()
+	a.conditions.clear()
+	a.mu.Unlock()
+
+	if previous == nil || previous == pluginRegistry {
+		return nil
+	}
+
+	// No resolve can reach the previous registry any more, but subsystem
+	// calls abandoned by earlier resolves may still be running
+	previousCalls.Wait()
+
+	if err := previous.Close(); err != nil {
+		return fmt.Errorf("failed to close previous plugin registry: %w", err)
+	}
+
+	return nil
+}
+
+// SetConditionCacheSize sets the number of parsed contribution conditions
+// kept; the least recently used ones are parsed again when next needed
+func (a *AggregatorImpl) SetConditionCacheSize(size int) error {
+	if size <= 0 {
+		return fmt.Errorf("condition cache size must be positive, got %d", size)
+	}
+
+	a.conditions.resize(size)
+	return nil
+}
+
+// SetDerivedFormulaRegistry sets the derived formula registry
+func (a *AggregatorImpl) SetDerivedFormulaRegistry(derivedFormulaRegistry interfaces.DerivedFormulaRegistry) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.derivedFormulaRegistry = derivedFormulaRegistry
+	a.generation = registry.NextGeneration()
+}
+
+// SetErrorPolicy sets the error policy for a subsystem
+func (a *AggregatorImpl) SetErrorPolicy(systemID string, policy enums.ErrorPolicy) error {
+	if systemID == "" {
+		return fmt.Errorf("system ID cannot be empty")
+	}
+
+	if !policy.IsValid() {
+		return fmt.Errorf("invalid error policy: %s", policy)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.errorPolicies[systemID] = policy
+	return nil
+}
+
+// SetDefaultErrorPolicy sets the error policy for subsystems without their own policy
+func (a *AggregatorImpl) SetDefaultErrorPolicy(policy enums.ErrorPolicy) error {
+	if !policy.IsValid() {
+		return fmt.Errorf("invalid error policy: %s", policy)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.defaultErrorPolicy = policy
+	return nil
+}
+
+// GetErrorPolicy returns the error policy applied to a subsystem
+func (a *AggregatorImpl) GetErrorPolicy(systemID string) enums.ErrorPolicy {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.errorPolicyFor(systemID)
+}
+
+// SetSubsystemWorkers sets the number of workers used to call subsystems in
+// parallel. Values of 1 or less call subsystems sequentially.
+func (a *AggregatorImpl) SetSubsystemWorkers(workers int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.subsystemWorkers = workers
+}
+
+// GetSubsystemWorkers returns the number of workers used to call subsystems
+func (a *AggregatorImpl) GetSubsystemWorkers() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.subsystemWorkers
 }
 
-// SetPluginRegistry sets the plugin registry
-func (a *AggregatorImpl) SetPluginRegistry(registry interfaces.PluginRegistry) {
+// SetBatchWorkers sets the number of workers ResolveBatch shards actors across
+func (a *AggregatorImpl) SetBatchWorkers(workers int) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
-	a.pluginRegistry = registry
+	a.batchWorkers = workers
+}
+
+// GetBatchWorkers returns the number of workers ResolveBatch shards actors across
+func (a *AggregatorImpl) GetBatchWorkers() int {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.batchWorkers
+}
+
+// GetSubsystemMetrics returns the recorded metrics for a subsystem, merged with
+// what it reports if it is a registered PerformanceSubsystem
+func (a *AggregatorImpl) GetSubsystemMetrics(systemID string) (*interfaces.SubsystemMetrics, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	metrics, exists := a.metrics.subsystemMetrics(systemID)
+
+	subsystem, registered := a.pluginRegistry.Get(systemID)
+	if !registered {
+		return metrics, exists
+	}
+
+	reporter, ok := subsystem.(interfaces.PerformanceSubsystem)
+	if !ok {
+		return metrics, exists
+	}
+
+	if !exists {
+		metrics = &interfaces.SubsystemMetrics{Latency: interfaces.NewLatencyHistogram()}
+	}
+	mergeReportedMetrics(metrics, reporter.GetMetrics())
+
+	return metrics, true
+}
+
+// mergeReportedMetrics merges the metrics a PerformanceSubsystem reports into
+// the metrics recorded for it
+func mergeReportedMetrics(metrics, reported *interfaces.SubsystemMetrics) {
+	if reported == nil {
+		return
+	}
+
+	metrics.MemoryUsage = reported.MemoryUsage
+	metrics.CacheHits += reported.CacheHits
+	metrics.CacheMisses += reported.CacheMisses
 }
 
 // SetCache sets the cache
@@ -383,7 +1630,35 @@ func (a *AggregatorImpl) SetCache(cache interfaces.Cache) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
+	a.keysMu.Lock()
+	defer a.keysMu.Unlock()
+
 	a.cache = cache
+	a.cacheKeys = make(map[string]cacheKey)
+}
+
+// SetCacheTTL sets how long resolved snapshots and retained subsystem outputs
+// stay cached
+func (a *AggregatorImpl) SetCacheTTL(ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("cache TTL must be positive: %s", ttl)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.cacheTTL = ttl
+	a.outputs.setTTL(ttl)
+	return nil
+}
+
+// GetCacheTTL returns how long resolved snapshots and retained subsystem
+// outputs stay cached
+func (a *AggregatorImpl) GetCacheTTL() time.Duration {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.cacheTTL
 }
 
 // GetCombinerRegistry returns the combiner registry
@@ -410,6 +1685,14 @@ func (a *AggregatorImpl) GetPluginRegistry() interfaces.PluginRegistry {
 	return a.pluginRegistry
 }
 
+// GetDerivedFormulaRegistry returns the derived formula registry
+func (a *AggregatorImpl) GetDerivedFormulaRegistry() interfaces.DerivedFormulaRegistry {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.derivedFormulaRegistry
+}
+
 // GetCache returns the cache
 func (a *AggregatorImpl) GetCache() interfaces.Cache {
 	a.mu.RLock()
@@ -443,14 +1726,21 @@ func (a *AggregatorImpl) GetMetrics() *interfaces.AggregatorMetrics {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
-	metrics := &interfaces.AggregatorMetrics{
-		TotalRequests:         0, // This would be tracked in a real implementation
-		TotalErrors:           0,
-		AverageProcessingTime: 0,
-		CacheHits:             0,
-		CacheMisses:           0,
-		ActiveActors:          0,
-		MemoryUsage:           0,
+	metrics := &interfaces.AggregatorMetrics{}
+	a.metrics.fill(metrics)
+
+	for _, subsystem := range a.pluginRegistry.GetAll() {
+		reporter, ok := subsystem.(interfaces.PerformanceSubsystem)
+		if !ok {
+			continue
+		}
+
+		subsystemMetrics, exists := metrics.Subsystems[subsystem.SystemID()]
+		if !exists {
+			subsystemMetrics = &interfaces.SubsystemMetrics{Latency: interfaces.NewLatencyHistogram()}
+			metrics.Subsystems[subsystem.SystemID()] = subsystemMetrics
+		}
+		mergeReportedMetrics(subsystemMetrics, reporter.GetMetrics())
 	}
 
 	if a.cache != nil {
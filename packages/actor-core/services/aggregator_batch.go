@@ -0,0 +1,238 @@
+package services
+
+import (
+	"chaos-actor-module/packages/actor-core/interfaces"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ResolveBatch resolves multiple actors. Actors sharing an ID are resolved
+// once (using the highest version) and share the resulting snapshot. Cache
+// misses are sharded across the batch workers after BatchSubsystems have
+// contributed for all of them in one call. Snapshots and per-actor errors are
+// aligned with the input slice; actors not started before ctx is cancelled
+// fail with the context error.
+func (a *AggregatorImpl) ResolveBatch(ctx context.Context, actors []*interfaces.Actor) ([]*interfaces.Snapshot, error) {
+	if actors == nil {
+		return nil, fmt.Errorf("actors cannot be nil")
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	start := time.Now()
+
+	snapshots := make([]*interfaces.Snapshot, len(actors))
+	errs := make([]error, len(actors))
+
+	// Deduplicate by actor ID
+	unique := make([]*interfaces.Actor, 0, len(actors))
+	uniqueIndex := make(map[string]int, len(actors))
+	slots := make([]int, len(actors))
+
+	for i, actor := range actors {
+		if actor == nil {
+			errs[i] = fmt.Errorf("actor cannot be nil")
+			slots[i] = -1
+			a.metrics.recordRequest("", 0, errs[i])
+			continue
+		}
+
+		idx, exists := uniqueIndex[actor.ID]
+		if !exists {
+			idx = len(unique)
+			uniqueIndex[actor.ID] = idx
+			unique = append(unique, actor)
+		} else if actor.Version > unique[idx].Version {
+			unique[idx] = actor
+		}
+		slots[i] = idx
+	}
+
+	uniqueSnapshots := make([]*interfaces.Snapshot, len(unique))
+	uniqueErrs := make([]error, len(unique))
+
+	// Serve cache hits, collect misses
+	misses := make([]int, 0, len(unique))
+	keys := make([]string, 0, len(unique))
+	for idx, actor := range unique {
+		lookupStart := time.Now()
+		key := a.snapshotCacheKey(actor.ID, actor.Version)
+		if snapshot, exists := a.cachedSnapshot(key); exists {
+			uniqueSnapshots[idx] = snapshot
+			a.metrics.recordRequest(actor.ID, time.Since(lookupStart), nil)
+			continue
+		}
+		misses = append(misses, idx)
+		keys = append(keys, key)
+	}
+
+	missActors := make([]*interfaces.Actor, len(misses))
+	for i, idx := range misses {
+		missActors[i] = unique[idx]
+	}
+	prefetched := a.contributeBatches(ctx, missActors)
+
+	a.resolveShards(ctx, missActors, keys, prefetched, func(i int, snapshot *interfaces.Snapshot, err error) {
+		uniqueSnapshots[misses[i]] = snapshot
+		uniqueErrs[misses[i]] = err
+	})
+
+	// Fan results back out to the input positions
+	failed := false
+	for i, idx := range slots {
+		if idx < 0 {
+			failed = true
+			continue
+		}
+
+		snapshots[i] = uniqueSnapshots[idx]
+		errs[i] = uniqueErrs[idx]
+		if errs[i] != nil {
+			failed = true
+		}
+	}
+
+	a.metrics.recordBatch(len(unique), time.Since(start))
+
+	if failed {
+		return snapshots, &interfaces.BatchError{Errors: errs}
+	}
+
+	return snapshots, nil
+}
+
+// resolveShards splits actors into contiguous shards, one per batch worker,
+// caches each snapshot under its key and reports each actor's result through done
+func (a *AggregatorImpl) resolveShards(ctx context.Context, actors []*interfaces.Actor, keys []string, prefetched []map[string]contributionResult, done func(i int, snapshot *interfaces.Snapshot, err error)) {
+	workers := a.batchWorkers
+	if workers > len(actors) {
+		workers = len(actors)
+	}
+	if workers < 1 {
+		workers = 1
+	}
+
+	shardSize := (len(actors) + workers - 1) / workers
+
+	var wg sync.WaitGroup
+	for from := 0; from < len(actors); from += shardSize {
+		to := from + shardSize
+		if to > len(actors) {
+			to = len(actors)
+		}
+
+		wg.Add(1)
+		go func(from, to int) {
+			defer wg.Done()
+
+			for i := from; i < to; i++ {
+				if err := ctx.Err(); err != nil {
+					a.metrics.recordRequest(actors[i].ID, 0, err)
+					done(i, nil, err)
+					continue
+				}
+
+				actorStart := time.Now()
+				snapshot, err := a.resolve(ctx, actors[i], prefetched[i], resolveOptions{}, nil)
+				if err == nil {
+					a.cacheSnapshot(keys[i], snapshot)
+				}
+				a.metrics.recordRequest(actors[i].ID, time.Since(actorStart), err)
+				done(i, snapshot, err)
+			}
+		}(from, to)
+	}
+
+	wg.Wait()
+}
+
+// contributeBatches calls ContributeBatch once on every BatchSubsystem for the
+// valid actors it runs for whose retained output cannot be reused, and returns
+// the results per actor, keyed by system ID. Actors with their own
+// configuration for a ConfigurableSubsystem are left to contribute individually.
+func (a *AggregatorImpl) contributeBatches(ctx context.Context, actors []*interfaces.Actor) []map[string]contributionResult {
+	prefetched := make([]map[string]contributionResult, len(actors))
+	for i := range prefetched {
+		prefetched[i] = make(map[string]contributionResult)
+	}
+
+	if len(actors) == 0 {
+		return prefetched
+	}
+
+	generations := make([]int64, len(actors))
+	for i, actor := range actors {
+		generations[i] = a.outputs.generation(actor.ID)
+	}
+
+	for _, subsystem := range a.pluginRegistry.GetByPriority() {
+		batcher, ok := subsystem.(interfaces.BatchSubsystem)
+		if !ok {
+			continue
+		}
+
+		_, configurable := subsystem.(interfaces.ConfigurableSubsystem)
+
+		pending := make([]int, 0, len(actors))
+		for i, actor := range actors {
+			if !runsFor(subsystem, actor) {
+				continue
+			}
+
+			// Actors with their own configuration contribute one at a time
+			if _, hasConfig := actor.GetSubsystemConfig(subsystem.SystemID()); configurable && hasConfig {
+				continue
+			}
+
+			if output, exists := a.reusableOutput(actor, subsystem); exists {
+				prefetched[i][subsystem.SystemID()] = contributionResult{output: output, reused: true}
+				continue
+			}
+
+			if err := validateActor(subsystem, actor); err != nil {
+				prefetched[i][subsystem.SystemID()] = contributionResult{err: err, generation: generations[i]}
+				continue
+			}
+
+			pending = append(pending, i)
+		}
+
+		if len(pending) == 0 {
+			continue
+		}
+
+		pendingActors := make([]*interfaces.Actor, len(pending))
+		for j, i := range pending {
+			pendingActors[j] = actors[i]
+		}
+
+		var outputs []*interfaces.SubsystemOutput
+		var batchErr error
+
+		start := time.Now()
+		err := callWithTimeout(ctx, a.subsystemCalls, subsystem, func(ctx context.Context) {
+			outputs, batchErr = batcher.ContributeBatch(ctx, pendingActors)
+		})
+		if err == nil {
+			err = batchErr
+		}
+		a.metrics.recordSubsystem(subsystem.SystemID(), time.Since(start), err)
+
+		if err == nil && len(outputs) != len(pendingActors) {
+			err = fmt.Errorf("batch contribution returned %d outputs for %d actors", len(outputs), len(pendingActors))
+		}
+
+		for j, i := range pending {
+			if err != nil {
+				prefetched[i][subsystem.SystemID()] = contributionResult{err: err, generation: generations[i]}
+				continue
+			}
+			prefetched[i][subsystem.SystemID()] = contributionResult{output: outputs[j], generation: generations[i]}
+		}
+	}
+
+	return prefetched
+}
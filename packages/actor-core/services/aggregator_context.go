@@ -0,0 +1,500 @@
+package services
+
+import (
+	"chaos-actor-module/packages/actor-core/constants"
+	"chaos-actor-module/packages/actor-core/enums"
+	"chaos-actor-module/packages/actor-core/expression"
+	"chaos-actor-module/packages/actor-core/interfaces"
+	"chaos-actor-module/packages/actor-core/types"
+	"container/list"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// resolveOptions are the options ResolveWithContext reads from its context map
+type resolveOptions struct {
+	// includeTags keeps only contributions carrying all of these tags
+	includeTags map[string]string
+
+	// excludeTags drops contributions carrying any of these tags
+	excludeTags map[string]string
+
+	// provenance records the contributing systems per dimension
+	provenance bool
+
+	// realms, if not nil, are the realm paths realm-scoped caps are filtered by
+	realms []string
+
+	// context is the full context map, visible to contribution conditions
+	context map[string]interface{}
+}
+
+// parseResolveOptions reads the resolve options from a ResolveWithContext
+// context map. Tag filters may be given as a map of tags or as a list of
+// "key=value" strings (see types.TagsFromList).
+func parseResolveOptions(context map[string]interface{}) (resolveOptions, error) {
+	options := resolveOptions{context: context}
+	var err error
+
+	if options.includeTags, err = contextTags(context, constants.ResolveContextIncludeTags); err != nil {
+		return options, err
+	}
+
+	if options.excludeTags, err = contextTags(context, constants.ResolveContextExcludeTags); err != nil {
+		return options, err
+	}
+
+	if options.realms, err = contextRealms(context); err != nil {
+		return options, err
+	}
+
+	if value, exists := context[constants.ResolveContextProvenance]; exists {
+		enabled, ok := value.(bool)
+		if !ok {
+			return options, fmt.Errorf("invalid %s: expected a bool, got %T", constants.ResolveContextProvenance, value)
+		}
+		options.provenance = enabled
+	}
+
+	return options, nil
+}
+
+// contextTags reads a tag set from the context map
+func contextTags(context map[string]interface{}, key string) (map[string]string, error) {
+	value, exists := context[key]
+	if !exists || value == nil {
+		return nil, nil
+	}
+
+	switch tags := value.(type) {
+	case map[string]string:
+		return tags, nil
+	case map[string]interface{}:
+		result := make(map[string]string, len(tags))
+		for tag, tagValue := range tags {
+			s, ok := tagValue.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s: tag %s has a %T value", key, tag, tagValue)
+			}
+			result[tag] = s
+		}
+		return result, nil
+	case []string:
+		return types.TagsFromList(tags), nil
+	case []interface{}:
+		list := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			s, ok := tag.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s: tag %v is a %T", key, tag, tag)
+			}
+			list = append(list, s)
+		}
+		return types.TagsFromList(list), nil
+	case string:
+		return types.TagsFromList([]string{tags}), nil
+	}
+
+	return nil, fmt.Errorf("invalid %s: unsupported type %T", key, value)
+}
+
+// contextRealms reads the realm paths from the context map, given as a path
+// or a list of paths
+func contextRealms(context map[string]interface{}) ([]string, error) {
+	value, exists := context[constants.ResolveContextRealm]
+	if !exists || value == nil {
+		return nil, nil
+	}
+
+	switch realms := value.(type) {
+	case string:
+		return []string{realms}, nil
+	case []string:
+		return realms, nil
+	case []interface{}:
+		list := make([]string, 0, len(realms))
+		for _, realm := range realms {
+			s, ok := realm.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s: realm %v is a %T", constants.ResolveContextRealm, realm, realm)
+			}
+			list = append(list, s)
+		}
+		return list, nil
+	}
+
+	return nil, fmt.Errorf("invalid %s: unsupported type %T", constants.ResolveContextRealm, value)
+}
+
+// filters reports whether the options filter contributions
+func (o resolveOptions) filters() bool {
+	return len(o.includeTags) > 0 || len(o.excludeTags) > 0
+}
+
+// matches reports whether a contribution with the given tags passes the tag filters
+func (o resolveOptions) matches(tags map[string]string) bool {
+	for key, value := range o.excludeTags {
+		if tag, exists := tags[key]; exists && tag == value {
+			return false
+		}
+	}
+
+	for key, value := range o.includeTags {
+		if tag, exists := tags[key]; !exists || tag != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// filterOutput applies the tag filters to the primary, derived and cap
+// contributions of an output. The output is copied rather than modified, since
+// it may be retained for later resolves.
+func (o resolveOptions) filterOutput(output *interfaces.SubsystemOutput) *interfaces.SubsystemOutput {
+	if !o.filters() {
+		return output
+	}
+
+	copied := *output
+	copied.Primary = o.filterContributions(output.Primary)
+	copied.Derived = o.filterContributions(output.Derived)
+	copied.Caps = o.filterCaps(output.Caps)
+	return &copied
+}
+
+// filterContributions returns the contributions that pass the tag filters
+func (o resolveOptions) filterContributions(contributions []interfaces.Contribution) []interfaces.Contribution {
+	if contributions == nil {
+		return nil
+	}
+
+	kept := make([]interfaces.Contribution, 0, len(contributions))
+	for _, contribution := range contributions {
+		if o.matches(contribution.Tags) {
+			kept = append(kept, contribution)
+		}
+	}
+	return kept
+}
+
+// filterCaps returns the cap contributions that pass the tag filters
+func (o resolveOptions) filterCaps(caps []interfaces.CapContribution) []interfaces.CapContribution {
+	if caps == nil {
+		return nil
+	}
+
+	kept := make([]interfaces.CapContribution, 0, len(caps))
+	for _, capContribution := range caps {
+		if o.matches(capContribution.Tags) {
+			kept = append(kept, capContribution)
+		}
+	}
+	return kept
+}
+
+// buildProvenance maps each dimension to the sorted systems that contributed
+// to it. Dimensions computed by a derived formula list
+// constants.SystemIDDerivedFormula as well.
+func buildProvenance(outputs []*interfaces.SubsystemOutput, formulaDimensions []string, derivedStats map[string]float64) map[string][]string {
+	systems := make(map[string]map[string]bool)
+	add := func(dimension, system string) {
+		if systems[dimension] == nil {
+			systems[dimension] = make(map[string]bool)
+		}
+		systems[dimension][system] = true
+	}
+
+	for _, output := range outputs {
+		if output == nil {
+			continue
+		}
+
+		for _, contribution := range output.Primary {
+			add(contribution.Dimension, contribution.System)
+		}
+		for _, contribution := range output.Derived {
+			add(contribution.Dimension, contribution.System)
+		}
+	}
+
+	for _, dimension := range formulaDimensions {
+		if _, resolved := derivedStats[dimension]; resolved {
+			add(dimension, constants.SystemIDDerivedFormula)
+		}
+	}
+
+	provenance := make(map[string][]string, len(systems))
+	for dimension, set := range systems {
+		list := make([]string, 0, len(set))
+		for system := range set {
+			list = append(list, system)
+		}
+		sort.Strings(list)
+		provenance[dimension] = list
+	}
+
+	return provenance
+}
+
+// conditionEnvironment evaluates CONDITIONAL contribution predicates against
+// the actor and the resolve context. Variables are read from the context map,
+// then from the actor's data; booleans are 1 or 0. Variables that are not set
+// to a number or boolean are undefined, so a misspelled name is an error
+// rather than false, except for the actor flags that read as false when unset.
+type conditionEnvironment struct {
+	actor   *interfaces.Actor
+	context map[string]interface{}
+}
+
+// conditionFlags are the actor data flags that read as false when unset
+var conditionFlags = map[string]bool{
+	"in_combat": true,
+}
+
+// Lookup returns the value of a context or actor data variable
+func (e conditionEnvironment) Lookup(name string) (float64, bool) {
+	if value, ok := numericValue(e.context[name]); ok {
+		return value, true
+	}
+
+	if value, ok := numericValue(e.actor.Data[name]); ok {
+		return value, true
+	}
+
+	if conditionFlags[name] {
+		return 0.0, true
+	}
+
+	return 0.0, false
+}
+
+// Call implements has_buff(name), in_guild() and in_guild(id)
+func (e conditionEnvironment) Call(name string, args []interface{}) (float64, bool, error) {
+	switch name {
+	case "has_buff":
+		if len(args) != 1 {
+			return 0.0, true, fmt.Errorf("expects 1 argument, got %d", len(args))
+		}
+		buff, ok := args[0].(string)
+		if !ok {
+			return 0.0, true, fmt.Errorf("expects a buff name")
+		}
+		return boolValue(e.actor.HasBuff(buff)), true, nil
+
+	case "in_guild":
+		switch len(args) {
+		case 0:
+			return boolValue(e.actor.GetGuildID() != ""), true, nil
+		case 1:
+			guildID, ok := args[0].(string)
+			if !ok {
+				return 0.0, true, fmt.Errorf("expects a guild ID")
+			}
+			return boolValue(e.actor.GetGuildID() == guildID), true, nil
+		default:
+			return 0.0, true, fmt.Errorf("expects at most 1 argument, got %d", len(args))
+		}
+	}
+
+	return 0.0, false, nil
+}
+
+// numericValue converts a numeric or boolean value to a float64
+func numericValue(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case bool:
+		return boolValue(v), true
+	}
+	return 0.0, false
+}
+
+// boolValue converts a boolean to 1 or 0
+func boolValue(b bool) float64 {
+	if b {
+		return 1.0
+	}
+	return 0.0
+}
+
+// applyConditions drops the CONDITIONAL contributions of an output whose
+// TagCondition predicate does not hold for env. CONDITIONAL contributions
+// without a condition always apply. Outcomes for the traced dimension are
+// recorded into trace. The output is copied rather than modified, since it may
+// be retained; invalid conditions are reported as a conditionError.
+func (a *AggregatorImpl) applyConditions(output *interfaces.SubsystemOutput, env conditionEnvironment, trace *interfaces.AggregationTrace) (*interfaces.SubsystemOutput, error) {
+	if !hasConditions(output.Primary) && !hasConditions(output.Derived) {
+		return output, nil
+	}
+
+	primary, err := a.conditionalContributions(output.Primary, env, trace)
+	if err != nil {
+		return nil, &conditionError{err: err}
+	}
+
+	derived, err := a.conditionalContributions(output.Derived, env, trace)
+	if err != nil {
+		return nil, &conditionError{err: err}
+	}
+
+	copied := *output
+	copied.Primary = primary
+	copied.Derived = derived
+	return &copied, nil
+}
+
+// hasConditions checks if any contribution is CONDITIONAL with a condition
+func hasConditions(contributions []interfaces.Contribution) bool {
+	for _, contribution := range contributions {
+		if _, exists := contribution.Tags[constants.TagCondition]; exists && contribution.Bucket == enums.BucketConditional {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionalContributions returns the contributions whose condition holds
+func (a *AggregatorImpl) conditionalContributions(contributions []interfaces.Contribution, env conditionEnvironment, trace *interfaces.AggregationTrace) ([]interfaces.Contribution, error) {
+	if contributions == nil {
+		return nil, nil
+	}
+
+	kept := make([]interfaces.Contribution, 0, len(contributions))
+	for _, contribution := range contributions {
+		source, exists := contribution.Tags[constants.TagCondition]
+		if !exists || contribution.Bucket != enums.BucketConditional {
+			kept = append(kept, contribution)
+			continue
+		}
+
+		met, err := a.evaluateCondition(source, env)
+		if err != nil {
+			return nil, fmt.Errorf("%s contribution to %s: %w", contribution.System, contribution.Dimension, err)
+		}
+
+		if traced := traceFor(trace, contribution.Dimension); traced != nil {
+			traced.Conditions = append(traced.Conditions, interfaces.ConditionTrace{
+				System:    contribution.System,
+				Condition: source,
+				Value:     contribution.Value,
+				Met:       met,
+			})
+		}
+
+		if met {
+			kept = append(kept, contribution)
+		}
+	}
+
+	return kept, nil
+}
+
+// evaluateCondition evaluates a condition, parsing its source only if it is
+// not cached
+func (a *AggregatorImpl) evaluateCondition(source string, env conditionEnvironment) (bool, error) {
+	condition, exists := a.conditions.get(source)
+	if !exists {
+		parsed, err := expression.ParseCondition(source)
+		if err != nil {
+			return false, err
+		}
+		condition = a.conditions.put(source, parsed)
+	}
+
+	return condition.EvaluateCondition(env)
+}
+
+// conditionCache keeps the most recently used parsed conditions by source
+type conditionCache struct {
+	mu   sync.Mutex
+	size int
+
+	// order holds the cached sources, most recently used first
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// conditionEntry is a parsed condition and its source
+type conditionEntry struct {
+	source    string
+	condition *expression.Expression
+}
+
+// newConditionCache creates a cache keeping up to size conditions
+func newConditionCache(size int) *conditionCache {
+	return &conditionCache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns the cached condition for source
+func (cc *conditionCache) get(source string) (*expression.Expression, bool) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	element, exists := cc.entries[source]
+	if !exists {
+		return nil, false
+	}
+
+	cc.order.MoveToFront(element)
+	return element.Value.(*conditionEntry).condition, true
+}
+
+// put caches the condition for source, evicting the least recently used
+// conditions beyond the size, and returns the cached condition
+func (cc *conditionCache) put(source string, condition *expression.Expression) *expression.Expression {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	// Another resolve may have parsed the same source meanwhile
+	if element, exists := cc.entries[source]; exists {
+		cc.order.MoveToFront(element)
+		return element.Value.(*conditionEntry).condition
+	}
+
+	cc.entries[source] = cc.order.PushFront(&conditionEntry{source: source, condition: condition})
+	cc.evictLocked()
+	return condition
+}
+
+// resize changes the number of conditions kept
+func (cc *conditionCache) resize(size int) {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cc.size = size
+	cc.evictLocked()
+}
+
+// evictLocked evicts the least recently used conditions beyond the size. The
+// caller must hold cc.mu.
+func (cc *conditionCache) evictLocked() {
+	for cc.order.Len() > cc.size {
+		oldest := cc.order.Back()
+		cc.order.Remove(oldest)
+		delete(cc.entries, oldest.Value.(*conditionEntry).source)
+	}
+}
+
+// clear drops all cached conditions
+func (cc *conditionCache) clear() {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+
+	cc.order.Init()
+	cc.entries = make(map[string]*list.Element)
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"chaos-actor-module/packages/actor-core/interfaces"
+	"sync"
+	"time"
+)
+
+// defaultActiveActorWindow is how long an actor counts as active after its last resolve
+const defaultActiveActorWindow = 5 * time.Minute
+
+// metricsRecorder tracks aggregator and per-subsystem metrics
+type metricsRecorder struct {
+	mu sync.Mutex
+
+	totalRequests       int64
+	totalErrors         int64
+	totalProcessingTime time.Duration
+	subsystemsProcessed int64
+	lastProcessed       time.Time
+
+	activeActors      map[string]time.Time
+	activeActorWindow time.Duration
+	lastPruned        time.Time
+
+	batchesProcessed     int64
+	batchActorsProcessed int64
+	batchProcessingTime  time.Duration
+
+	subsystems map[string]*interfaces.SubsystemMetrics
+}
+
+// newMetricsRecorder creates a new metrics recorder
+func newMetricsRecorder() *metricsRecorder {
+	return &metricsRecorder{
+		activeActors:      make(map[string]time.Time),
+		activeActorWindow: defaultActiveActorWindow,
+		subsystems:        make(map[string]*interfaces.SubsystemMetrics),
+	}
+}
+
+// recordRequest records a resolve request for an actor
+func (mr *metricsRecorder) recordRequest(actorID string, elapsed time.Duration, err error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	now := time.Now()
+
+	mr.totalRequests++
+	mr.totalProcessingTime += elapsed
+	mr.lastProcessed = now
+
+	if err != nil {
+		mr.totalErrors++
+	}
+
+	if actorID != "" {
+		mr.activeActors[actorID] = now
+	}
+
+	// Prune at most once per window, so that the active actors stay bounded
+	// by the actors seen in the last two windows even if metrics are never read
+	if now.Sub(mr.lastPruned) >= mr.activeActorWindow {
+		mr.pruneActorsLocked(now)
+	}
+}
+
+// pruneActorsLocked removes the actors that are no longer active. The caller
+// must hold mr.mu.
+func (mr *metricsRecorder) pruneActorsLocked(now time.Time) {
+	cutoff := now.Add(-mr.activeActorWindow)
+	for actorID, lastSeen := range mr.activeActors {
+		if lastSeen.Before(cutoff) {
+			delete(mr.activeActors, actorID)
+		}
+	}
+	mr.lastPruned = now
+}
+
+// recordSubsystem records a single Contribute call
+func (mr *metricsRecorder) recordSubsystem(systemID string, elapsed time.Duration, err error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	metrics := mr.subsystemLocked(systemID)
+	metrics.Calls++
+	metrics.ProcessingTime += elapsed
+	metrics.Latency.Observe(elapsed)
+	metrics.LastProcessed = time.Now()
+
+	if err != nil {
+		metrics.Errors++
+	}
+}
+
+// recordSubsystemCache records whether a subsystem's retained output was reused
+func (mr *metricsRecorder) recordSubsystemCache(systemID string, hit bool) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	metrics := mr.subsystemLocked(systemID)
+	if hit {
+		metrics.CacheHits++
+	} else {
+		metrics.CacheMisses++
+	}
+}
+
+// recordSubsystemsProcessed records the number of subsystem outputs processed by a resolve
+func (mr *metricsRecorder) recordSubsystemsProcessed(count int) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.subsystemsProcessed += int64(count)
+}
+
+// recordBatch records batch throughput counters
+func (mr *metricsRecorder) recordBatch(actors int, elapsed time.Duration) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.batchesProcessed++
+	mr.batchActorsProcessed += int64(actors)
+	mr.batchProcessingTime += elapsed
+}
+
+// subsystemLocked returns the metrics for a subsystem, creating them if
+// needed. The caller must hold mr.mu.
+func (mr *metricsRecorder) subsystemLocked(systemID string) *interfaces.SubsystemMetrics {
+	metrics, exists := mr.subsystems[systemID]
+	if !exists {
+		metrics = &interfaces.SubsystemMetrics{Latency: interfaces.NewLatencyHistogram()}
+		mr.subsystems[systemID] = metrics
+	}
+	return metrics
+}
+
+// subsystemMetrics returns a copy of the metrics for a subsystem
+func (mr *metricsRecorder) subsystemMetrics(systemID string) (*interfaces.SubsystemMetrics, bool) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	metrics, exists := mr.subsystems[systemID]
+	if !exists {
+		return nil, false
+	}
+
+	return metrics.Clone(), true
+}
+
+// fill copies the recorded metrics into metrics, pruning actors that are no
+// longer active
+func (mr *metricsRecorder) fill(metrics *interfaces.AggregatorMetrics) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.pruneActorsLocked(time.Now())
+
+	metrics.TotalRequests = mr.totalRequests
+	metrics.TotalErrors = mr.totalErrors
+	metrics.ActiveActors = int64(len(mr.activeActors))
+	metrics.SubsystemsProcessed = mr.subsystemsProcessed
+	metrics.LastProcessed = mr.lastProcessed
+
+	if mr.totalRequests > 0 {
+		metrics.AverageProcessingTime = mr.totalProcessingTime / time.Duration(mr.totalRequests)
+	}
+
+	metrics.BatchesProcessed = mr.batchesProcessed
+	metrics.BatchActorsProcessed = mr.batchActorsProcessed
+	if mr.batchProcessingTime > 0 {
+		metrics.BatchThroughput = float64(mr.batchActorsProcessed) / mr.batchProcessingTime.Seconds()
+	}
+
+	metrics.Subsystems = make(map[string]*interfaces.SubsystemMetrics, len(mr.subsystems))
+	for systemID, subsystem := range mr.subsystems {
+		metrics.Subsystems[systemID] = subsystem.Clone()
+	}
+}
@@ -0,0 +1,251 @@
+package services
+
+import (
+	"chaos-actor-module/packages/actor-core/interfaces"
+	"chaos-actor-module/packages/actor-core/registry"
+	"sync"
+	"time"
+)
+
+// retainedOutput is the last successful output of a subsystem for an actor
+type retainedOutput struct {
+	output *interfaces.SubsystemOutput
+
+	// version is the actor version the output was computed for
+	version int64
+
+	// cacheKey is the CachingSubsystem key the output was computed for
+	cacheKey string
+
+	// generation is the actor generation the output was computed against
+	generation int64
+
+	// dirtyAt is the actor generation at which the subsystem was last marked dirty
+	dirtyAt int64
+
+	// storedAt is when the output or dirty mark was stored; it expires a TTL later
+	storedAt time.Time
+}
+
+// actorOutputs holds the retained outputs of an actor
+type actorOutputs struct {
+	// generation is bumped by every MarkDirty for the actor
+	generation int64
+
+	// allDirtyAt is the generation at which every subsystem was last marked dirty
+	allDirtyAt int64
+
+	outputs map[string]*retainedOutput
+
+	// touched is when the actor was last stored to or marked dirty
+	touched time.Time
+}
+
+// outputStore retains the last successful output per actor per subsystem, so
+// that caching subsystems are only invoked again when dirty and failed
+// subsystems can fall back to their last good output. Retained outputs expire
+// after the TTL and are swept as new outputs are stored.
+type outputStore struct {
+	mu        sync.Mutex
+	actors    map[string]*actorOutputs
+	ttl       time.Duration
+	lastSwept time.Time
+}
+
+// newOutputStore creates a new output store whose outputs expire after ttl
+func newOutputStore(ttl time.Duration) *outputStore {
+	return &outputStore{
+		actors: make(map[string]*actorOutputs),
+		ttl:    ttl,
+	}
+}
+
+// setTTL sets how long retained outputs are kept
+func (st *outputStore) setTTL(ttl time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.ttl = ttl
+}
+
+// generation returns the current generation of an actor
+func (st *outputStore) generation(actorID string) int64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if actor, exists := st.actors[actorID]; exists {
+		return actor.generation
+	}
+	return 0
+}
+
+// reusable returns the retained output of a subsystem if it was computed for
+// the given actor version and cache key and has not been marked dirty since
+func (st *outputStore) reusable(actorID, systemID string, version int64, cacheKey string) (*interfaces.SubsystemOutput, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	actor, exists := st.actors[actorID]
+	if !exists {
+		return nil, false
+	}
+
+	retained, exists := actor.outputs[systemID]
+	if !exists || retained.output == nil || st.expiredLocked(retained, time.Now()) {
+		return nil, false
+	}
+
+	if retained.version != version || retained.cacheKey != cacheKey {
+		return nil, false
+	}
+
+	if retained.generation < retained.dirtyAt || retained.generation < actor.allDirtyAt {
+		return nil, false
+	}
+
+	return retained.output, true
+}
+
+// lastGood returns the last successful output of a subsystem, dirty or not
+func (st *outputStore) lastGood(actorID, systemID string) (*interfaces.SubsystemOutput, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	actor, exists := st.actors[actorID]
+	if !exists {
+		return nil, false
+	}
+
+	retained, exists := actor.outputs[systemID]
+	if !exists || retained.output == nil || st.expiredLocked(retained, time.Now()) {
+		return nil, false
+	}
+
+	return retained.output, true
+}
+
+// store retains a subsystem output computed against the given actor
+// generation. Dirty marks made after that generation are kept.
+func (st *outputStore) store(actorID, systemID string, output *interfaces.SubsystemOutput, version int64, cacheKey string, generation int64) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := time.Now()
+	actor := st.actorLocked(actorID, now)
+
+	var dirtyAt int64
+	if previous, exists := actor.outputs[systemID]; exists {
+		dirtyAt = previous.dirtyAt
+	}
+
+	actor.outputs[systemID] = &retainedOutput{
+		output:     output,
+		version:    version,
+		cacheKey:   cacheKey,
+		generation: generation,
+		dirtyAt:    dirtyAt,
+		storedAt:   now,
+	}
+
+	// Sweep at most once per TTL, so that the store stays bounded by the
+	// actors resolved in the last two TTLs
+	if now.Sub(st.lastSwept) >= st.ttl {
+		st.sweepLocked(now)
+	}
+}
+
+// markDirty marks subsystems of an actor dirty, or all of them if no system
+// IDs are given, and returns the actor's new generation
+func (st *outputStore) markDirty(actorID string, systemIDs ...string) int64 {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	now := time.Now()
+	actor := st.actorLocked(actorID, now)
+	actor.generation = registry.NextGeneration()
+
+	if len(systemIDs) == 0 {
+		actor.allDirtyAt = actor.generation
+		return actor.generation
+	}
+
+	for _, systemID := range systemIDs {
+		retained, exists := actor.outputs[systemID]
+		if !exists {
+			// Keep the mark for an output that is still being computed
+			retained = &retainedOutput{storedAt: now}
+			actor.outputs[systemID] = retained
+		}
+		retained.dirtyAt = actor.generation
+	}
+
+	return actor.generation
+}
+
+// forget drops everything retained for an actor
+func (st *outputStore) forget(actorID string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	delete(st.actors, actorID)
+}
+
+// clear drops everything retained for all actors
+func (st *outputStore) clear() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.actors = make(map[string]*actorOutputs)
+}
+
+// actorLocked returns the retained outputs of an actor, creating them if
+// needed, and marks the actor touched. The caller must hold st.mu.
+func (st *outputStore) actorLocked(actorID string, now time.Time) *actorOutputs {
+	actor, exists := st.actors[actorID]
+	if !exists {
+		actor = &actorOutputs{outputs: make(map[string]*retainedOutput)}
+		st.actors[actorID] = actor
+	}
+	actor.touched = now
+	return actor
+}
+
+// expiredLocked checks if a retained output has outlived the TTL. The caller
+// must hold st.mu.
+func (st *outputStore) expiredLocked(retained *retainedOutput, now time.Time) bool {
+	return now.Sub(retained.storedAt) > st.ttl
+}
+
+// sweepLocked drops expired outputs, and actors left without outputs that
+// have not been touched within the TTL. The caller must hold st.mu.
+func (st *outputStore) sweepLocked(now time.Time) {
+	for actorID, actor := range st.actors {
+		for systemID, retained := range actor.outputs {
+			if st.expiredLocked(retained, now) {
+				delete(actor.outputs, systemID)
+			}
+		}
+
+		if len(actor.outputs) == 0 && now.Sub(actor.touched) > st.ttl {
+			delete(st.actors, actorID)
+		}
+	}
+	st.lastSwept = now
+}
+
+// subsystemCacheKey returns the CachingSubsystem key of a subsystem for an
+// actor and whether its output may be reused at all. Only subsystems that
+// implement CachingSubsystem and opt in through ShouldCache are reused; the
+// outputs of the others are retained only as last good outputs.
+func subsystemCacheKey(subsystem interfaces.Subsystem, actor *interfaces.Actor) (string, bool) {
+	caching, ok := subsystem.(interfaces.CachingSubsystem)
+	if !ok {
+		return "", false
+	}
+
+	if !caching.ShouldCache() {
+		return "", false
+	}
+
+	return caching.GetCacheKey(actor), true
+}
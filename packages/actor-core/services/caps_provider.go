@@ -1,9 +1,13 @@
 package services
 
 import (
+	"chaos-actor-module/packages/actor-core/constants"
+	"chaos-actor-module/packages/actor-core/enums"
 	"chaos-actor-module/packages/actor-core/interfaces"
+	"chaos-actor-module/packages/actor-core/registry"
 	"context"
 	"fmt"
+	"math"
 	"sort"
 	"sync"
 )
@@ -11,13 +15,20 @@ import (
 // CapsProviderImpl implements the CapsProvider interface
 type CapsProviderImpl struct {
 	layerRegistry interfaces.CapLayerRegistry
+	realmCaps     *registry.RealmCapTable
+	catalog       interfaces.DimensionCatalog
+	statistics    *capStatistics
+	generation    int64
 	mu            sync.RWMutex
 }
 
-// NewCapsProvider creates a new caps provider
+// NewCapsProvider creates a new caps provider with the built-in dimension catalog
 func NewCapsProvider(layerRegistry interfaces.CapLayerRegistry) interfaces.CapsProvider {
 	return &CapsProviderImpl{
 		layerRegistry: layerRegistry,
+		catalog:       registry.NewDimensionCatalog(),
+		statistics:    newCapStatistics(constants.DefaultCapStatisticsWindow),
+		generation:    registry.NextGeneration(),
 	}
 }
 
@@ -26,6 +37,13 @@ func (cp *CapsProviderImpl) EffectiveCapsWithinLayer(ctx context.Context, actor
 	cp.mu.RLock()
 	defer cp.mu.RUnlock()
 
+	return cp.effectiveCapsWithinLayerLocked(ctx, actor, outputs, layer)
+}
+
+// effectiveCapsWithinLayerLocked implements EffectiveCapsWithinLayer. The
+// caller must hold cp.mu, since read-locking it again could deadlock behind a
+// queued writer.
+func (cp *CapsProviderImpl) effectiveCapsWithinLayerLocked(ctx context.Context, actor *interfaces.Actor, outputs []*interfaces.SubsystemOutput, layer string) (interfaces.EffectiveCaps, error) {
 	if actor == nil {
 		return nil, fmt.Errorf("actor cannot be nil")
 	}
@@ -52,21 +70,28 @@ func (cp *CapsProviderImpl) EffectiveCapsWithinLayer(ctx context.Context, actor
 		return nil, fmt.Errorf("invalid layer: %s", layer)
 	}
 
-	// Collect caps for this layer
+	// Collect caps for this layer that apply in the actor's realm
 	layerCaps := make(map[string][]interfaces.CapContribution)
+	realms := actorRealms(ctx, actor)
 
-	for _, output := range outputs {
-		if output == nil {
-			continue
-		}
-
-		for _, cap := range output.Caps {
-			if cap.Scope == layer {
+	collect := func(caps []interfaces.CapContribution) {
+		for _, cap := range caps {
+			if cap.Scope == layer && inRealm(cap.Realm, realms) {
 				layerCaps[cap.Dimension] = append(layerCaps[cap.Dimension], cap)
 			}
 		}
 	}
 
+	for _, output := range outputs {
+		if output != nil {
+			collect(output.Caps)
+		}
+	}
+
+	if cp.realmCaps != nil && len(realms) > 0 {
+		collect(cp.realmCaps.GetContributions())
+	}
+
 	// Calculate effective caps for each dimension
 	effectiveCaps := make(interfaces.EffectiveCaps)
 
@@ -91,6 +116,12 @@ func (cp *CapsProviderImpl) EffectiveCapsAcrossLayers(ctx context.Context, actor
 	cp.mu.RLock()
 	defer cp.mu.RUnlock()
 
+	return cp.effectiveCapsAcrossLayersLocked(ctx, actor, outputs)
+}
+
+// effectiveCapsAcrossLayersLocked implements EffectiveCapsAcrossLayers. The
+// caller must hold cp.mu.
+func (cp *CapsProviderImpl) effectiveCapsAcrossLayersLocked(ctx context.Context, actor *interfaces.Actor, outputs []*interfaces.SubsystemOutput) (interfaces.EffectiveCaps, error) {
 	if actor == nil {
 		return nil, fmt.Errorf("actor cannot be nil")
 	}
@@ -99,15 +130,32 @@ func (cp *CapsProviderImpl) EffectiveCapsAcrossLayers(ctx context.Context, actor
 		return nil, fmt.Errorf("outputs cannot be nil")
 	}
 
+	// Report caps that no layer would pick up
+	for _, output := range outputs {
+		if output == nil {
+			continue
+		}
+
+		if err := cp.layerRegistry.ValidateScopes(output.Caps); err != nil {
+			return nil, err
+		}
+	}
+
+	if cp.realmCaps != nil {
+		if err := cp.layerRegistry.ValidateScopes(cp.realmCaps.GetContributions()); err != nil {
+			return nil, fmt.Errorf("realm cap table: %w", err)
+		}
+	}
+
 	// Get layer order
 	layerOrder := cp.layerRegistry.GetLayerOrder()
-	acrossPolicy := cp.layerRegistry.GetAcrossLayerPolicy()
+	dimensionPolicies := cp.layerRegistry.GetDimensionPolicies()
 
 	// Collect caps by layer
 	layerCaps := make(map[string]interfaces.EffectiveCaps)
 
 	for _, layer := range layerOrder {
-		effectiveCaps, err := cp.EffectiveCapsWithinLayer(ctx, actor, outputs, layer)
+		effectiveCaps, err := cp.effectiveCapsWithinLayerLocked(ctx, actor, outputs, layer)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get effective caps for layer %s: %w", layer, err)
 		}
@@ -128,7 +176,7 @@ func (cp *CapsProviderImpl) EffectiveCapsAcrossLayers(ctx context.Context, actor
 
 	// Calculate effective caps for each dimension
 	for dimension := range allDimensions {
-		effectiveCap, err := cp.combineCapsAcrossLayers(dimension, layerCaps, acrossPolicy)
+		effectiveCap, err := cp.combineCapsAcrossLayers(dimension, layerOrder, layerCaps, dimensionPolicies)
 		if err != nil {
 			return nil, fmt.Errorf("failed to combine caps for dimension %s: %w", dimension, err)
 		}
@@ -155,7 +203,15 @@ func (cp *CapsProviderImpl) GetAcrossLayerPolicy() string {
 	return cp.layerRegistry.GetAcrossLayerPolicy()
 }
 
-// GetCapsForDimension returns caps for a specific dimension
+// GetDimensionPolicy returns the across-layer policy for a dimension
+func (cp *CapsProviderImpl) GetDimensionPolicy(dimension string) string {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+
+	return cp.layerRegistry.GetDimensionPolicy(dimension)
+}
+
+// GetCapsForDimension returns the default caps of a dimension in the dimension catalog
 func (cp *CapsProviderImpl) GetCapsForDimension(dimension string) (interfaces.Caps, error) {
 	cp.mu.RLock()
 	defer cp.mu.RUnlock()
@@ -164,60 +220,122 @@ func (cp *CapsProviderImpl) GetCapsForDimension(dimension string) (interfaces.Ca
 		return interfaces.Caps{}, fmt.Errorf("dimension cannot be empty")
 	}
 
-	// Return default caps for the dimension
-	// In a real implementation, this would query the combiner registry
-	return interfaces.Caps{
-		Min: 0.0,
-		Max: 1000000.0,
-	}, nil
+	definition, exists := cp.catalog.GetDimension(dimension)
+	if !exists {
+		return interfaces.Caps{}, fmt.Errorf("unknown dimension: %s", dimension)
+	}
+
+	return definition.DefaultCaps, nil
+}
+
+// capBound accumulates the BASELINE, ADDITIVE and OVERRIDE contributions to
+// one bound of a cap
+type capBound struct {
+	baseline    float64
+	additive    float64
+	override    float64
+	hasBaseline bool
+	hasAdditive bool
+	hasOverride bool
 }
 
-// calculateEffectiveCap calculates effective cap for a dimension within a layer
+// value returns the bound: the override if any, else the baseline plus the
+// additive contributions. Bounds nothing contributed to return unset.
+func (cb *capBound) value(unset float64) float64 {
+	if cb.hasOverride {
+		return cb.override
+	}
+	if cb.hasBaseline || cb.hasAdditive {
+		return cb.baseline + cb.additive
+	}
+	return unset
+}
+
+// calculateEffectiveCap calculates effective cap for a dimension within a layer.
+// Contributions apply to the bound named by their kind, or to both bounds if
+// the kind is empty:
+//   - BASELINE sets the starting bound; the highest-priority baseline wins
+//   - ADDITIVE raises or lowers the bound by its value
+//   - OVERRIDE replaces the bound; the highest-priority override wins
+//   - HARD_MAX and HARD_MIN are ceilings and floors on both bounds that no
+//     other contribution can exceed; the lowest ceiling and highest floor win
+//
+// Contributions are ordered by priority, then by CapContribution.GetSortKey,
+// so the result does not depend on the order subsystems ran in. A bound
+// nothing contributed to is left open (±math.MaxFloat64).
 func (cp *CapsProviderImpl) calculateEffectiveCap(caps []interfaces.CapContribution, dimension string) (interfaces.Caps, error) {
 	if len(caps) == 0 {
 		return interfaces.Caps{}, fmt.Errorf("no caps provided")
 	}
 
-	// Sort caps by priority (higher priority first)
-	sort.Slice(caps, func(i, j int) bool {
-		return caps[i].Priority > caps[j].Priority
+	// Sort a copy by priority (higher priority first), then sort key
+	sorted := make([]interfaces.CapContribution, len(caps))
+	copy(sorted, caps)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Priority != sorted[j].Priority {
+			return sorted[i].Priority > sorted[j].Priority
+		}
+		if keyI, keyJ := sorted[i].GetSortKey(), sorted[j].GetSortKey(); keyI != keyJ {
+			return keyI < keyJ
+		}
+		return sorted[i].Value < sorted[j].Value
 	})
 
-	// Apply caps based on mode
-	var effectiveCap interfaces.Caps
-	first := true
+	var minBound, maxBound capBound
+	hardMin, hardMax := -math.MaxFloat64, math.MaxFloat64
+
+	for _, cap := range sorted {
+		var bounds []*capBound
+		switch cap.Kind {
+		case "min":
+			bounds = []*capBound{&minBound}
+		case "max":
+			bounds = []*capBound{&maxBound}
+		case "":
+			bounds = []*capBound{&minBound, &maxBound}
+		default:
+			return interfaces.Caps{}, fmt.Errorf("invalid cap kind %q from %s", cap.Kind, cap.System)
+		}
 
-	for _, cap := range caps {
-		if first {
-			effectiveCap = interfaces.Caps{
-				Min: cap.Value,
-				Max: cap.Value,
+		switch cap.Mode {
+		case enums.CapModeBaseline:
+			for _, bound := range bounds {
+				if !bound.hasBaseline {
+					bound.baseline = cap.Value
+					bound.hasBaseline = true
+				}
 			}
-			first = false
-		} else {
-			switch cap.Mode {
-			case "BASELINE":
-				// Baseline sets the base value
-				effectiveCap.Min = cap.Value
-				effectiveCap.Max = cap.Value
-			case "ADDITIVE":
-				// Additive adds to the current range
-				effectiveCap.Min += cap.Value
-				effectiveCap.Max += cap.Value
-			case "HARD_MAX":
-				// Hard max sets the maximum
-				effectiveCap.Max = cap.Value
-			case "HARD_MIN":
-				// Hard min sets the minimum
-				effectiveCap.Min = cap.Value
-			case "OVERRIDE":
-				// Override replaces the current range
-				effectiveCap.Min = cap.Value
-				effectiveCap.Max = cap.Value
+		case enums.CapModeAdditive:
+			for _, bound := range bounds {
+				bound.additive += cap.Value
+				bound.hasAdditive = true
 			}
+		case enums.CapModeOverride:
+			for _, bound := range bounds {
+				if !bound.hasOverride {
+					bound.override = cap.Value
+					bound.hasOverride = true
+				}
+			}
+		case enums.CapModeHardMax:
+			hardMax = min(hardMax, cap.Value)
+		case enums.CapModeHardMin:
+			hardMin = max(hardMin, cap.Value)
+		default:
+			return interfaces.Caps{}, fmt.Errorf("invalid cap mode %q from %s", cap.Mode, cap.System)
 		}
 	}
 
+	// A ceiling below a floor leaves the ceiling in force
+	if hardMin > hardMax {
+		hardMin = hardMax
+	}
+
+	effectiveCap := interfaces.Caps{
+		Min: min(max(minBound.value(-math.MaxFloat64), hardMin), hardMax),
+		Max: min(max(maxBound.value(math.MaxFloat64), hardMin), hardMax),
+	}
+
 	// Ensure min <= max
 	if effectiveCap.Min > effectiveCap.Max {
 		effectiveCap.Min = effectiveCap.Max
@@ -226,79 +344,117 @@ func (cp *CapsProviderImpl) calculateEffectiveCap(caps []interfaces.CapContribut
 	return effectiveCap, nil
 }
 
-// combineCapsAcrossLayers combines caps across layers
-func (cp *CapsProviderImpl) combineCapsAcrossLayers(dimension string, layerCaps map[string]interfaces.EffectiveCaps, policy string) (interfaces.Caps, error) {
-	// Collect caps from all layers
-	var caps []interfaces.Caps
+// combineCapsAcrossLayers combines the caps the layers set for a dimension.
+// A dimension with a policy override is combined by that policy. Otherwise
+// each layer with a default policy is combined with the layers before it by
+// its default policy, and the other layers by the across-layer policy.
+func (cp *CapsProviderImpl) combineCapsAcrossLayers(dimension string, layerOrder []string, layerCaps map[string]interfaces.EffectiveCaps, dimensionPolicies map[string]string) (interfaces.Caps, error) {
+	// Collect caps from all layers, in layer order
+	var layers []interfaces.LayerCaps
+	var layerPolicies []string
+	hasLayerPolicies := false
 
-	for _, effectiveCaps := range layerCaps {
-		if cap, exists := effectiveCaps[dimension]; exists {
-			caps = append(caps, cap)
+	for _, layer := range layerOrder {
+		if cap, exists := layerCaps[layer][dimension]; exists {
+			definition, _ := cp.layerRegistry.GetLayer(layer)
+			layers = append(layers, interfaces.LayerCaps{Layer: layer, Caps: cap})
+			layerPolicies = append(layerPolicies, definition.DefaultPolicy)
+			hasLayerPolicies = hasLayerPolicies || len(layers) > 1 && definition.DefaultPolicy != ""
 		}
 	}
 
-	if len(caps) == 0 {
+	if len(layers) == 0 {
 		return interfaces.Caps{}, fmt.Errorf("no caps found for dimension %s", dimension)
 	}
 
 	// Combine based on policy
-	switch policy {
-	case "intersect":
-		return cp.intersectCaps(caps)
-	case "union":
-		return cp.unionCaps(caps)
-	default:
-		return cp.intersectCaps(caps) // Default to intersect
+	if policyName, exists := dimensionPolicies[dimension]; exists || !hasLayerPolicies {
+		policy, err := cp.policy(policyName)
+		if err != nil {
+			return interfaces.Caps{}, err
+		}
+		return policy.Combine(dimension, layers)
 	}
-}
 
-// intersectCaps intersects multiple caps
-func (cp *CapsProviderImpl) intersectCaps(caps []interfaces.Caps) (interfaces.Caps, error) {
-	if len(caps) == 0 {
-		return interfaces.Caps{}, fmt.Errorf("no caps provided")
+	result := layers[0]
+	for i, layer := range layers[1:] {
+		policy, err := cp.policy(layerPolicies[i+1])
+		if err != nil {
+			return interfaces.Caps{}, err
+		}
+
+		caps, err := policy.Combine(dimension, []interfaces.LayerCaps{result, layer})
+		if err != nil {
+			return interfaces.Caps{}, err
+		}
+		result = interfaces.LayerCaps{Layer: layer.Layer, Caps: caps}
 	}
 
-	result := caps[0]
+	return result.Caps, nil
+}
 
-	for i := 1; i < len(caps); i++ {
-		result = interfaces.Caps{
-			Min: max(result.Min, caps[i].Min),
-			Max: min(result.Max, caps[i].Max),
-		}
+// policy returns the across-layer policy registered under a name, or the
+// across-layer policy if the name is empty
+func (cp *CapsProviderImpl) policy(name string) (interfaces.AcrossLayerPolicy, error) {
+	if name == "" {
+		name = cp.layerRegistry.GetAcrossLayerPolicy()
 	}
 
-	// Ensure min <= max
-	if result.Min > result.Max {
-		result.Min = result.Max
+	policy, exists := cp.layerRegistry.GetPolicy(name)
+	if !exists {
+		return nil, fmt.Errorf("invalid across-layer policy: %s", name)
 	}
 
-	return result, nil
+	return policy, nil
 }
 
-// unionCaps unions multiple caps
-func (cp *CapsProviderImpl) unionCaps(caps []interfaces.Caps) (interfaces.Caps, error) {
-	if len(caps) == 0 {
-		return interfaces.Caps{}, fmt.Errorf("no caps provided")
-	}
+// SetLayerRegistry sets the layer registry
+func (cp *CapsProviderImpl) SetLayerRegistry(layerRegistry interfaces.CapLayerRegistry) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
 
-	result := caps[0]
+	cp.layerRegistry = layerRegistry
+	cp.generation = registry.NextGeneration()
+}
 
-	for i := 1; i < len(caps); i++ {
-		result = interfaces.Caps{
-			Min: min(result.Min, caps[i].Min),
-			Max: max(result.Max, caps[i].Max),
-		}
-	}
+// SetRealmCapTable sets the realm cap table whose caps apply in addition to
+// the subsystems' caps. A nil table removes it.
+func (cp *CapsProviderImpl) SetRealmCapTable(table *registry.RealmCapTable) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
 
-	return result, nil
+	cp.realmCaps = table
+	cp.generation = registry.NextGeneration()
 }
 
-// SetLayerRegistry sets the layer registry
-func (cp *CapsProviderImpl) SetLayerRegistry(registry interfaces.CapLayerRegistry) {
+// GetRealmCapTable returns the realm cap table, if any
+func (cp *CapsProviderImpl) GetRealmCapTable() *registry.RealmCapTable {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+
+	return cp.realmCaps
+}
+
+// SetDimensionCatalog sets the dimension catalog the provider answers
+// dimension queries from
+func (cp *CapsProviderImpl) SetDimensionCatalog(catalog interfaces.DimensionCatalog) error {
+	if catalog == nil {
+		return fmt.Errorf("dimension catalog cannot be nil")
+	}
+
 	cp.mu.Lock()
 	defer cp.mu.Unlock()
 
-	cp.layerRegistry = registry
+	cp.catalog = catalog
+	return nil
+}
+
+// GetDimensionCatalog returns the dimension catalog
+func (cp *CapsProviderImpl) GetDimensionCatalog() interfaces.DimensionCatalog {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+
+	return cp.catalog
 }
 
 // GetLayerRegistry returns the layer registry
@@ -309,6 +465,19 @@ func (cp *CapsProviderImpl) GetLayerRegistry() interfaces.CapLayerRegistry {
 	return cp.layerRegistry
 }
 
+// GetGeneration returns the latest generation of the provider and its layer registry
+func (cp *CapsProviderImpl) GetGeneration() int64 {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+
+	generation := cp.generation
+	if cp.layerRegistry != nil && cp.layerRegistry.GetGeneration() > generation {
+		generation = cp.layerRegistry.GetGeneration()
+	}
+
+	return generation
+}
+
 // Validate validates the caps provider
 func (cp *CapsProviderImpl) Validate() error {
 	cp.mu.RLock()
@@ -318,25 +487,31 @@ func (cp *CapsProviderImpl) Validate() error {
 		return fmt.Errorf("layer registry is nil")
 	}
 
-	return nil
+	if cp.catalog == nil {
+		return fmt.Errorf("dimension catalog is nil")
+	}
+
+	return cp.catalog.Validate()
 }
 
-// GetSupportedDimensions returns all supported dimensions
+// GetSupportedDimensions returns the names of the dimensions in the dimension catalog, sorted
 func (cp *CapsProviderImpl) GetSupportedDimensions() []string {
-	// In a real implementation, this would query the combiner registry
-	return []string{
-		"strength", "vitality", "dexterity", "intelligence", "spirit", "charisma",
-		"hp_max", "mp_max", "stamina_max",
-		"attack_power", "defense", "magic_power", "magic_resistance",
-		"crit_rate", "crit_damage", "accuracy",
-		"move_speed", "attack_speed", "cast_speed",
-		"cooldown_reduction", "mana_efficiency", "energy_efficiency",
-		"learning_rate", "cultivation_speed", "breakthrough_success",
-		"lifespan_years", "poise_rank", "stealth", "perception", "luck",
-	}
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+
+	return cp.catalog.GetDimensionNames()
 }
 
-// ValidateCaps validates effective caps
+// ValidateScopes checks that every cap contribution's scope is a known layer
+func (cp *CapsProviderImpl) ValidateScopes(caps []interfaces.CapContribution) error {
+	cp.mu.RLock()
+	defer cp.mu.RUnlock()
+
+	return cp.layerRegistry.ValidateScopes(caps)
+}
+
+// ValidateCaps validates effective caps. Bounds are non-negative, except for
+// the open lower bound (-math.MaxFloat64) of caps without a minimum.
 func (cp *CapsProviderImpl) ValidateCaps(caps interfaces.EffectiveCaps) error {
 	if caps == nil {
 		return fmt.Errorf("caps cannot be nil")
@@ -351,7 +526,7 @@ func (cp *CapsProviderImpl) ValidateCaps(caps interfaces.EffectiveCaps) error {
 			return fmt.Errorf("min cap (%f) cannot be greater than max cap (%f) for dimension %s", cap.Min, cap.Max, dimension)
 		}
 
-		if cap.Min < 0 {
+		if cap.Min < 0 && cap.Min != -math.MaxFloat64 {
 			return fmt.Errorf("min cap (%f) cannot be negative for dimension %s", cap.Min, dimension)
 		}
 	}
@@ -359,7 +534,12 @@ func (cp *CapsProviderImpl) ValidateCaps(caps interfaces.EffectiveCaps) error {
 	return nil
 }
 
-// GetCapStatistics returns statistics about caps
+// GetCapStatistics returns statistics about caps:
+//   - layer_caps: the number of dimensions the outputs cap in each layer
+//   - total_caps: the number of dimensions the outputs cap across layers
+//   - across_policy: the across-layer policy
+//   - resolves: the number of recent resolves recorded by RecordResolve
+//   - cap_hits: per dimension, how often it was at its caps in those resolves
 func (cp *CapsProviderImpl) GetCapStatistics(ctx context.Context, actor *interfaces.Actor, outputs []*interfaces.SubsystemOutput) (map[string]interface{}, error) {
 	cp.mu.RLock()
 	defer cp.mu.RUnlock()
@@ -379,7 +559,7 @@ func (cp *CapsProviderImpl) GetCapStatistics(ctx context.Context, actor *interfa
 	layerStats := make(map[string]int)
 
 	for _, layer := range layerOrder {
-		effectiveCaps, err := cp.EffectiveCapsWithinLayer(ctx, actor, outputs, layer)
+		effectiveCaps, err := cp.effectiveCapsWithinLayerLocked(ctx, actor, outputs, layer)
 		if err != nil {
 			continue
 		}
@@ -390,17 +570,52 @@ func (cp *CapsProviderImpl) GetCapStatistics(ctx context.Context, actor *interfa
 	stats["layer_caps"] = layerStats
 
 	// Get total caps across all layers
-	totalCaps, err := cp.EffectiveCapsAcrossLayers(ctx, actor, outputs)
+	totalCaps, err := cp.effectiveCapsAcrossLayersLocked(ctx, actor, outputs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get total caps: %w", err)
 	}
 
 	stats["total_caps"] = len(totalCaps)
 	stats["across_policy"] = cp.layerRegistry.GetAcrossLayerPolicy()
+	stats["resolves"], stats["cap_hits"] = cp.statistics.snapshot()
 
 	return stats, nil
 }
 
+// RecordResolve records which dimensions of a resolved snapshot were at their
+// effective caps. Only the most recent resolves are kept (see
+// SetCapStatisticsWindow).
+func (cp *CapsProviderImpl) RecordResolve(snapshot *interfaces.Snapshot) {
+	if snapshot == nil {
+		return
+	}
+
+	cp.statistics.record(capHits(snapshot))
+}
+
+// SetCapStatisticsWindow sets the number of recent resolves cap hit
+// statistics are kept for, and clears the recorded resolves. A window of zero
+// disables recording.
+func (cp *CapsProviderImpl) SetCapStatisticsWindow(window int) error {
+	if window < 0 {
+		return fmt.Errorf("cap statistics window cannot be negative: %d", window)
+	}
+
+	cp.statistics.setWindow(window)
+	return nil
+}
+
+// GetCapStatisticsWindow returns the number of recent resolves cap hit
+// statistics are kept for
+func (cp *CapsProviderImpl) GetCapStatisticsWindow() int {
+	return cp.statistics.getWindow()
+}
+
+// ResetCapStatistics clears the recorded resolves
+func (cp *CapsProviderImpl) ResetCapStatistics() {
+	cp.statistics.clear()
+}
+
 // Helper functions
 func max(a, b float64) float64 {
 	if a > b {
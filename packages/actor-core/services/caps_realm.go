@@ -0,0 +1,46 @@
+package services
+
+import (
+	"chaos-actor-module/packages/actor-core/interfaces"
+	"context"
+	"strings"
+)
+
+// realmContextKey is the context key WithRealms stores realm paths under
+type realmContextKey struct{}
+
+// WithRealms returns a context under which caps are resolved as if the actor
+// were in the given realm paths, instead of the realms in its data
+func WithRealms(ctx context.Context, realms ...string) context.Context {
+	return context.WithValue(ctx, realmContextKey{}, realms)
+}
+
+// RealmsFromContext returns the realm paths set by WithRealms
+func RealmsFromContext(ctx context.Context) ([]string, bool) {
+	realms, ok := ctx.Value(realmContextKey{}).([]string)
+	return realms, ok
+}
+
+// actorRealms returns the realm paths realm-scoped caps are filtered by: the
+// context's if set, else the actor's
+func actorRealms(ctx context.Context, actor *interfaces.Actor) []string {
+	if realms, ok := RealmsFromContext(ctx); ok {
+		return realms
+	}
+	return actor.GetRealms()
+}
+
+// inRealm checks if a cap scoped to realm applies in any of the realm paths.
+// Caps without a realm apply everywhere; a realm's caps apply in its sub-realms.
+func inRealm(realm string, realms []string) bool {
+	if realm == "" {
+		return true
+	}
+
+	for _, path := range realms {
+		if path == realm || strings.HasPrefix(path, realm+"/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package services
+
+import (
+	"chaos-actor-module/packages/actor-core/interfaces"
+	"sort"
+	"sync"
+)
+
+// capHit records whether a capped dimension was at its caps in one resolve
+type capHit struct {
+	dimension string
+	atMin     bool
+	atMax     bool
+}
+
+// capStatistics counts cap hits over a sliding window of recent resolves
+type capStatistics struct {
+	mu       sync.Mutex
+	window   int
+	resolves [][]capHit
+	next     int
+	counts   map[string]*interfaces.CapHitStatistics
+}
+
+// newCapStatistics creates cap statistics over the given number of resolves
+func newCapStatistics(window int) *capStatistics {
+	return &capStatistics{
+		window: window,
+		counts: make(map[string]*interfaces.CapHitStatistics),
+	}
+}
+
+// capHits returns the hits of a snapshot's capped dimensions, sorted by
+// dimension. Caps without a value in the snapshot are skipped.
+func capHits(snapshot *interfaces.Snapshot) []capHit {
+	hits := make([]capHit, 0, len(snapshot.CapsUsed))
+	for dimension, caps := range snapshot.CapsUsed {
+		value, exists := snapshot.Primary[dimension]
+		if !exists {
+			if value, exists = snapshot.Derived[dimension]; !exists {
+				continue
+			}
+		}
+
+		hits = append(hits, capHit{
+			dimension: dimension,
+			atMin:     value <= caps.Min,
+			atMax:     value >= caps.Max,
+		})
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].dimension < hits[j].dimension
+	})
+	return hits
+}
+
+// record adds a resolve's hits, evicting the oldest resolve once the window is full
+func (cs *capStatistics) record(hits []capHit) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if cs.window <= 0 {
+		return
+	}
+
+	if len(cs.resolves) < cs.window {
+		cs.resolves = append(cs.resolves, hits)
+	} else {
+		cs.count(cs.resolves[cs.next], -1)
+		cs.resolves[cs.next] = hits
+		cs.next = (cs.next + 1) % cs.window
+	}
+
+	cs.count(hits, 1)
+}
+
+// count adds delta to the counts of each hit without locking (internal use)
+func (cs *capStatistics) count(hits []capHit, delta int64) {
+	for _, hit := range hits {
+		stats := cs.counts[hit.dimension]
+		if stats == nil {
+			stats = &interfaces.CapHitStatistics{}
+			cs.counts[hit.dimension] = stats
+		}
+
+		stats.Capped += delta
+		if hit.atMin {
+			stats.AtMin += delta
+		}
+		if hit.atMax {
+			stats.AtMax += delta
+		}
+		if hit.atMin || hit.atMax {
+			stats.Hits += delta
+		}
+
+		if stats.Capped == 0 {
+			delete(cs.counts, hit.dimension)
+		}
+	}
+}
+
+// snapshot returns the number of recorded resolves and the per-dimension counts
+func (cs *capStatistics) snapshot() (int, map[string]interfaces.CapHitStatistics) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	counts := make(map[string]interfaces.CapHitStatistics, len(cs.counts))
+	for dimension, stats := range cs.counts {
+		counts[dimension] = *stats
+	}
+	return len(cs.resolves), counts
+}
+
+// setWindow sets the window size and clears the recorded resolves
+func (cs *capStatistics) setWindow(window int) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.window = window
+	cs.clearUnsafe()
+}
+
+// clear clears the recorded resolves
+func (cs *capStatistics) clear() {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.clearUnsafe()
+}
+
+// clearUnsafe clears the recorded resolves without locking (internal use)
+func (cs *capStatistics) clearUnsafe() {
+	cs.resolves = nil
+	cs.next = 0
+	cs.counts = make(map[string]*interfaces.CapHitStatistics)
+}
+
+// getWindow returns the number of resolves statistics are kept for
+func (cs *capStatistics) getWindow() int {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	return cs.window
+}
@@ -47,6 +47,10 @@ func (sf *ServiceFactory) CreateAllServices() (*ServiceSet, error) {
 		registrySet.GetCache(),
 	)
 
+	if impl, ok := aggregator.(*AggregatorImpl); ok {
+		impl.SetDerivedFormulaRegistry(registrySet.GetDerivedFormulaRegistry())
+	}
+
 	return &ServiceSet{
 		CapsProvider: capsProvider,
 		Aggregator:   aggregator,
@@ -69,6 +73,10 @@ func (sf *ServiceFactory) CreateAllServicesFromRegistries(registrySet *registry.
 		registrySet.GetCache(),
 	)
 
+	if impl, ok := aggregator.(*AggregatorImpl); ok {
+		impl.SetDerivedFormulaRegistry(registrySet.GetDerivedFormulaRegistry())
+	}
+
 	return &ServiceSet{
 		CapsProvider: capsProvider,
 		Aggregator:   aggregator,
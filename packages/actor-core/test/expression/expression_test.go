@@ -0,0 +1,191 @@
+package expression
+
+import (
+	"chaos-actor-module/packages/actor-core/expression"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestParse_Evaluate(t *testing.T) {
+	variables := map[string]float64{
+		"strength": 10,
+		"vitality": 20,
+		"spirit":   4,
+	}
+
+	tests := []struct {
+		name   string
+		source string
+		want   float64
+	}{
+		{name: "Literal", source: "42", want: 42},
+		{name: "Precedence", source: "vitality * 10 + strength * 2", want: 220},
+		{name: "Parentheses", source: "(vitality + strength) * 2", want: 60},
+		{name: "UnaryMinus", source: "-strength + 5", want: -5},
+		{name: "Power", source: "2 ^ 3 ^ 2", want: 512},
+		{name: "Modulo", source: "vitality % 6", want: 2},
+		{name: "Decimal", source: "strength * 0.5", want: 5},
+		{name: "Exponent", source: "1.5e2", want: 150},
+		{name: "Min", source: "min(strength, vitality, spirit)", want: 4},
+		{name: "Max", source: "max(strength, vitality)", want: 20},
+		{name: "Clamp", source: "clamp(vitality, 0, 15)", want: 15},
+		{name: "Sqrt", source: "sqrt(spirit)", want: 2},
+		{name: "Nested", source: "floor(sqrt(vitality * 5) + 0.5)", want: 10},
+		{name: "Comparison", source: "vitality > strength * 2", want: 0},
+		{name: "ComparisonPrecedence", source: "vitality >= strength * 2 && spirit != 4 || 1", want: 1},
+		{name: "Not", source: "!(strength < vitality)", want: 0},
+		{name: "Equality", source: "spirit == 4", want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := expression.Parse(tt.source)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+
+			got, err := expr.Evaluate(variables)
+			if err != nil {
+				t.Fatalf("Evaluate() error = %v", err)
+			}
+
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("Evaluate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []string{
+		"",
+		"strength +",
+		"(strength",
+		"strength)",
+		"strength $ 2",
+		"unknown(strength)",
+		"clamp(strength, 1)",
+		"min()",
+		"strength = 2",
+		"strength & 1",
+		"max(\"strength\", 1)",
+		"\"strength\"",
+		"has_buff(\"berserk\")",
+		"'unterminated",
+	}
+
+	for _, source := range tests {
+		if _, err := expression.Parse(source); err == nil {
+			t.Errorf("Parse(%q) should return error", source)
+		}
+	}
+}
+
+func TestExpression_Evaluate_Errors(t *testing.T) {
+	expr, err := expression.Parse("strength / vitality")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if _, err := expr.Evaluate(map[string]float64{"strength": 1, "vitality": 0}); err == nil {
+		t.Error("Evaluate() should return error for division by zero")
+	}
+
+	if _, err := expr.Evaluate(map[string]float64{"strength": 1}); err == nil {
+		t.Error("Evaluate() should return error for undefined variable")
+	}
+}
+
+func TestExpression_Variables(t *testing.T) {
+	expr, err := expression.Parse("vitality * 10 + strength * 2 + max(vitality, 1)")
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	want := []string{"strength", "vitality"}
+	if got := expr.Variables(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Variables() = %v, want %v", got, want)
+	}
+}
+
+// conditionEnv resolves flags and a has_buff function for condition tests
+type conditionEnv struct {
+	flags map[string]float64
+	buffs []string
+}
+
+func (e *conditionEnv) Lookup(name string) (float64, bool) {
+	value, exists := e.flags[name]
+	return value, exists
+}
+
+func (e *conditionEnv) Call(name string, args []interface{}) (float64, bool, error) {
+	if name != "has_buff" {
+		return 0, false, nil
+	}
+	if len(args) != 1 {
+		return 0, true, fmt.Errorf("expects 1 argument, got %d", len(args))
+	}
+	buff, ok := args[0].(string)
+	if !ok {
+		return 0, true, fmt.Errorf("expects a string argument")
+	}
+	for _, b := range e.buffs {
+		if b == buff {
+			return 1, true, nil
+		}
+	}
+	return 0, true, nil
+}
+
+func TestParseCondition_EvaluateCondition(t *testing.T) {
+	env := &conditionEnv{
+		flags: map[string]float64{"in_combat": 1, "zone_level": 12},
+		buffs: []string{"berserk"},
+	}
+
+	tests := []struct {
+		name   string
+		source string
+		want   bool
+	}{
+		{name: "Flag", source: "in_combat", want: true},
+		{name: "Call", source: `has_buff("berserk")`, want: true},
+		{name: "SingleQuotes", source: "has_buff('haste')", want: false},
+		{name: "And", source: `in_combat && has_buff("berserk")`, want: true},
+		{name: "Not", source: `in_combat && !has_buff("berserk")`, want: false},
+		{name: "Or", source: `has_buff("haste") || zone_level >= 10`, want: true},
+		// The right operand is not evaluated, so its undefined variable is not an error
+		{name: "ShortCircuit", source: "!in_combat && undefined", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := expression.ParseCondition(tt.source)
+			if err != nil {
+				t.Fatalf("ParseCondition() error = %v", err)
+			}
+
+			got, err := expr.EvaluateCondition(env)
+			if err != nil {
+				t.Fatalf("EvaluateCondition() error = %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("EvaluateCondition() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, source := range []string{`unknown("x")`, "has_buff(1)", "has_buff()", "undefined"} {
+		expr, err := expression.ParseCondition(source)
+		if err != nil {
+			t.Fatalf("ParseCondition(%q) error = %v", source, err)
+		}
+		if _, err := expr.EvaluateCondition(env); err == nil {
+			t.Errorf("EvaluateCondition(%q) should return error", source)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package registry
 
 import (
+	"chaos-actor-module/packages/actor-core/enums"
 	"chaos-actor-module/packages/actor-core/interfaces"
 	"chaos-actor-module/packages/actor-core/registry"
+	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -246,3 +249,109 @@ func TestCombinerRegistryImpl_Count(t *testing.T) {
 		t.Errorf("Count() = %v, want 1", count)
 	}
 }
+
+func TestCombinerRegistryImpl_SaveToFile_BucketOrder(t *testing.T) {
+	cr := registry.NewCombinerRegistry().(*registry.CombinerRegistryImpl)
+
+	rule := &interfaces.MergeRule{
+		UsePipeline:  true,
+		ClampDefault: interfaces.Caps{Min: 0, Max: 100},
+		BucketOrder:  []enums.Bucket{enums.BucketFlat, enums.BucketOverride, enums.BucketExponential},
+		LogBase:      10,
+	}
+	if err := cr.SetRule("strength", rule); err != nil {
+		t.Fatalf("SetRule() error = %v", err)
+	}
+
+	filePath := filepath.Join(t.TempDir(), "combiner.json")
+	if err := cr.SaveToFile(filePath); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+
+	loaded, err := registry.NewCombinerRegistryFromFile(filePath)
+	if err != nil {
+		t.Fatalf("NewCombinerRegistryFromFile() error = %v", err)
+	}
+
+	got, err := loaded.GetRule("strength")
+	if err != nil {
+		t.Fatalf("GetRule() error = %v", err)
+	}
+	if !reflect.DeepEqual(got.BucketOrder, rule.BucketOrder) || got.LogBase != 10 {
+		t.Errorf("GetRule() = %+v, want bucket order %v and log base 10", got, rule.BucketOrder)
+	}
+
+	invalid := []map[string]interface{}{
+		{"use_pipeline": true, "bucket_order": []interface{}{"FLAT", "SQUARE"}},
+		{"use_pipeline": true, "bucket_order": []interface{}{"FLAT", "FLAT"}},
+		{"use_pipeline": true, "bucket_order": "FLAT"},
+		{"use_pipeline": true, "log_base": 1.0},
+	}
+	for _, ruleConfig := range invalid {
+		config := map[string]interface{}{"rules": map[string]interface{}{"strength": ruleConfig}}
+		if err := registry.NewCombinerRegistry().LoadFromConfig(config); err == nil {
+			t.Errorf("LoadFromConfig(%v) should return error", ruleConfig)
+		}
+	}
+}
+
+func TestCombinerRegistryImpl_SaveToFile_SoftCap(t *testing.T) {
+	cr := registry.NewCombinerRegistry().(*registry.CombinerRegistryImpl)
+
+	rules := map[string]*interfaces.MergeRule{
+		"crit_rate": {UsePipeline: true, SoftCap: &interfaces.SoftCap{Curve: enums.SoftCapHyperbolic, K: 100, Scale: 0.75}},
+		"armor": {UsePipeline: true, SoftCap: &interfaces.SoftCap{Curve: enums.SoftCapPiecewiseLinear, Knees: []interfaces.SoftCapKnee{
+			{At: 1000, Slope: 0.5},
+			{At: 2000, Slope: 0.25},
+		}}},
+		"move_speed": {UsePipeline: false, Operator: "MAX", SoftCap: &interfaces.SoftCap{Curve: enums.SoftCapExponentialDecay, Threshold: 8, Scale: 4}},
+	}
+	for dimension, rule := range rules {
+		if err := cr.SetRule(dimension, rule); err != nil {
+			t.Fatalf("SetRule(%s) error = %v", dimension, err)
+		}
+	}
+
+	filePath := filepath.Join(t.TempDir(), "combiner.json")
+	if err := cr.SaveToFile(filePath); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+
+	loaded, err := registry.NewCombinerRegistryFromFile(filePath)
+	if err != nil {
+		t.Fatalf("NewCombinerRegistryFromFile() error = %v", err)
+	}
+
+	for dimension, rule := range rules {
+		got, err := loaded.GetRule(dimension)
+		if err != nil {
+			t.Fatalf("GetRule(%s) error = %v", dimension, err)
+		}
+		if !reflect.DeepEqual(got.SoftCap, rule.SoftCap) {
+			t.Errorf("GetRule(%s) SoftCap = %+v, want %+v", dimension, got.SoftCap, rule.SoftCap)
+		}
+	}
+
+	invalid := []map[string]interface{}{
+		{"curve": "SQUARE_ROOT"},
+		{"curve": "HYPERBOLIC"},
+		{"curve": "PIECEWISE_LINEAR"},
+		{"curve": "PIECEWISE_LINEAR", "knees": []interface{}{
+			map[string]interface{}{"at": 200.0, "slope": 0.5},
+			map[string]interface{}{"at": 100.0, "slope": 0.25},
+		}},
+		{"curve": "EXPONENTIAL_DECAY", "threshold": 100.0},
+	}
+	for _, softCap := range invalid {
+		config := map[string]interface{}{"rules": map[string]interface{}{
+			"armor": map[string]interface{}{"use_pipeline": true, "soft_cap": softCap},
+		}}
+		if err := registry.NewCombinerRegistry().LoadFromConfig(config); err == nil {
+			t.Errorf("LoadFromConfig(%v) should return error", softCap)
+		}
+	}
+
+	if err := cr.SetRule("armor", &interfaces.MergeRule{UsePipeline: true, SoftCap: &interfaces.SoftCap{Curve: enums.SoftCapHyperbolic}}); err == nil {
+		t.Error("SetRule() should reject an invalid soft cap")
+	}
+}
@@ -0,0 +1,155 @@
+package registry
+
+import (
+	"chaos-actor-module/packages/actor-core/registry"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDerivedFormulaRegistryImpl_SetFormula(t *testing.T) {
+	dfr := registry.NewDerivedFormulaRegistry()
+
+	err := dfr.SetFormula("hp_max", "vitality * 10 + strength * 2")
+	if err != nil {
+		t.Fatalf("SetFormula() error = %v", err)
+	}
+
+	formula, exists := dfr.GetFormula("hp_max")
+	if !exists {
+		t.Fatal("GetFormula() should return the registered formula")
+	}
+
+	want := []string{"strength", "vitality"}
+	if !reflect.DeepEqual(formula.DependsOn, want) {
+		t.Errorf("GetFormula() DependsOn = %v, want %v", formula.DependsOn, want)
+	}
+
+	if dfr.Count() != 1 {
+		t.Errorf("Count() = %v, want 1", dfr.Count())
+	}
+}
+
+func TestDerivedFormulaRegistryImpl_SetFormula_Invalid(t *testing.T) {
+	dfr := registry.NewDerivedFormulaRegistry()
+
+	// Test syntax error
+	if err := dfr.SetFormula("hp_max", "vitality *"); err == nil {
+		t.Error("SetFormula() should return error for invalid expression")
+	}
+
+	// Test empty dimension
+	if err := dfr.SetFormula("", "vitality"); err == nil {
+		t.Error("SetFormula() should return error for empty dimension")
+	}
+
+	// Test self reference
+	if err := dfr.SetFormula("hp_max", "hp_max * 2"); err == nil {
+		t.Error("SetFormula() should return error for self reference")
+	}
+
+	// Test cycle
+	if err := dfr.SetFormula("attack_power", "defense * 2"); err != nil {
+		t.Fatalf("SetFormula() error = %v", err)
+	}
+	if err := dfr.SetFormula("defense", "attack_power / 2"); err == nil {
+		t.Error("SetFormula() should return error for circular dependency")
+	}
+
+	// The rejected formula must not be kept
+	if dfr.HasFormula("defense") {
+		t.Error("HasFormula() should return false for rejected formula")
+	}
+}
+
+func TestDerivedFormulaRegistryImpl_GetEvaluationOrder(t *testing.T) {
+	dfr := registry.NewDerivedFormulaRegistry()
+
+	formulas := map[string]string{
+		"attack_power": "strength * 2 + hp_max * 0.01",
+		"hp_max":       "vitality * 10",
+		"mp_max":       "intelligence * 8",
+		"defense":      "attack_power * 0.5 + hp_max * 0.1",
+	}
+	for dimension, source := range formulas {
+		if err := dfr.SetFormula(dimension, source); err != nil {
+			t.Fatalf("SetFormula(%s) error = %v", dimension, err)
+		}
+	}
+
+	order, err := dfr.GetEvaluationOrder()
+	if err != nil {
+		t.Fatalf("GetEvaluationOrder() error = %v", err)
+	}
+
+	want := []string{"hp_max", "attack_power", "defense", "mp_max"}
+	if !reflect.DeepEqual(order, want) {
+		t.Errorf("GetEvaluationOrder() = %v, want %v", order, want)
+	}
+}
+
+func TestDerivedFormulaRegistryImpl_Evaluate(t *testing.T) {
+	dfr := registry.NewDerivedFormulaRegistry()
+
+	if err := dfr.SetFormula("hp_max", "100 + vitality * 10 + strength * 2"); err != nil {
+		t.Fatalf("SetFormula() error = %v", err)
+	}
+
+	// Missing dependencies evaluate as zero
+	value, err := dfr.Evaluate("hp_max", map[string]float64{"vitality": 5})
+	if err != nil {
+		t.Fatalf("Evaluate() error = %v", err)
+	}
+
+	if value != 150 {
+		t.Errorf("Evaluate() = %v, want 150", value)
+	}
+
+	if _, err := dfr.Evaluate("mp_max", nil); err == nil {
+		t.Error("Evaluate() should return error for unknown dimension")
+	}
+}
+
+func TestDerivedFormulaRegistryImpl_LoadFromConfig(t *testing.T) {
+	dfr := registry.NewDerivedFormulaRegistry()
+
+	config := map[string]interface{}{
+		"formulas": map[string]interface{}{
+			"hp_max": "vitality * 10",
+			"mp_max": map[string]interface{}{
+				"expression": "intelligence * 8 + spirit * 4",
+			},
+		},
+	}
+
+	if err := dfr.LoadFromConfig(config); err != nil {
+		t.Fatalf("LoadFromConfig() error = %v", err)
+	}
+
+	if dfr.Count() != 2 {
+		t.Errorf("Count() = %v, want 2", dfr.Count())
+	}
+
+	// Test invalid config
+	if err := dfr.LoadFromConfig(map[string]interface{}{}); err == nil {
+		t.Error("LoadFromConfig() should return error for missing formulas")
+	}
+}
+
+func TestDerivedFormulaRegistryImpl_LoadFromFile(t *testing.T) {
+	filePath := filepath.Join(t.TempDir(), "formulas.json")
+	data := []byte(`{"formulas": {"hp_max": "vitality * 10 + strength * 2"}}`)
+	if err := os.WriteFile(filePath, data, 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	dfr, err := registry.NewDerivedFormulaRegistryFromFile(filePath)
+	if err != nil {
+		t.Fatalf("NewDerivedFormulaRegistryFromFile() error = %v", err)
+	}
+
+	if !dfr.HasFormula("hp_max") {
+		t.Error("HasFormula() should return true for loaded formula")
+	}
+}
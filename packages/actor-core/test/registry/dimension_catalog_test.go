@@ -0,0 +1,161 @@
+package registry
+
+import (
+	"chaos-actor-module/packages/actor-core/enums"
+	"chaos-actor-module/packages/actor-core/interfaces"
+	"chaos-actor-module/packages/actor-core/registry"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNewDimensionCatalog(t *testing.T) {
+	catalog := registry.NewDimensionCatalog()
+
+	if err := catalog.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	if catalog.Count() != 30 {
+		t.Errorf("Count() = %v, want the 30 dimensions of the dimension catalog doc", catalog.Count())
+	}
+
+	strength, exists := catalog.GetDimension("strength")
+	if !exists || strength.Kind != enums.DimensionKindPrimary || strength.Unit != "points" || strength.DefaultCaps != (interfaces.Caps{Min: 0, Max: 999999}) {
+		t.Errorf("GetDimension(strength) = %+v, %v, want a primary dimension in points capped to [0, 999999]", strength, exists)
+	}
+
+	hpMax, exists := catalog.GetDimension("hp_max")
+	if !exists || hpMax.Kind != enums.DimensionKindDerived || hpMax.DefaultCaps != (interfaces.Caps{Min: 1, Max: 2000000}) {
+		t.Errorf("GetDimension(hp_max) = %+v, %v, want a derived dimension capped to [1, 2000000]", hpMax, exists)
+	}
+
+	names := catalog.GetDimensionNames()
+	dimensions := catalog.GetDimensions()
+	for i, dimension := range dimensions {
+		if names[i] != dimension.Name || (i > 0 && names[i-1] >= names[i]) {
+			t.Fatalf("GetDimensionNames() = %v, want the sorted names of GetDimensions()", names)
+		}
+	}
+}
+
+func TestDimensionCatalogImpl_RegisterDimension(t *testing.T) {
+	catalog := registry.NewDimensionCatalog()
+	generation := catalog.GetGeneration()
+
+	qiMax := interfaces.DimensionDefinition{
+		Name:        "qi_max",
+		Unit:        "qi",
+		Kind:        enums.DimensionKindDerived,
+		DefaultCaps: interfaces.Caps{Min: 0, Max: 50000},
+		Subsystem:   "jindan",
+	}
+	if err := catalog.RegisterDimension(qiMax); err != nil {
+		t.Fatalf("RegisterDimension() error = %v", err)
+	}
+	if catalog.GetGeneration() == generation {
+		t.Error("RegisterDimension() should bump the generation")
+	}
+
+	if got := catalog.GetDimensionsBySubsystem("jindan"); !reflect.DeepEqual(got, []interfaces.DimensionDefinition{qiMax}) {
+		t.Errorf("GetDimensionsBySubsystem(jindan) = %+v, want [%+v]", got, qiMax)
+	}
+
+	invalid := map[string]interfaces.DimensionDefinition{
+		"EmptyName":    {Kind: enums.DimensionKindPrimary},
+		"InvalidKind":  {Name: "qi_max", Kind: "meta"},
+		"InvertedCaps": {Name: "qi_max", Kind: enums.DimensionKindPrimary, DefaultCaps: interfaces.Caps{Min: 10, Max: 1}},
+	}
+	for name, dimension := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if err := catalog.RegisterDimension(dimension); err == nil {
+				t.Errorf("RegisterDimension(%+v) should return error", dimension)
+			}
+		})
+	}
+
+	catalog.RemoveDimension("strength")
+	if _, exists := catalog.GetDimension("strength"); exists {
+		t.Error("RemoveDimension() should remove strength")
+	}
+
+	catalog.Reset()
+	if _, exists := catalog.GetDimension("qi_max"); exists || catalog.Count() != 30 {
+		t.Errorf("Reset() left %d dimensions, want only the built-in ones", catalog.Count())
+	}
+}
+
+func TestNewDimensionCatalogFromFile(t *testing.T) {
+	yaml := `
+dimensions:
+  spirit: { display_name: Spirit, unit: points, kind: primary, default_caps: { min: 0, max: 999_999 } }
+  qi_max: { unit: qi, kind: derived, default_caps: { min: 0, max: 50_000 }, subsystem: jindan }
+`
+	filePath := filepath.Join(t.TempDir(), "dimensions.yml")
+	if err := os.WriteFile(filePath, []byte(yaml), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	catalog, err := registry.NewDimensionCatalogFromFile(filePath)
+	if err != nil {
+		t.Fatalf("NewDimensionCatalogFromFile() error = %v", err)
+	}
+
+	// The file replaces the built-in dimensions
+	if got := catalog.GetDimensionNames(); !reflect.DeepEqual(got, []string{"qi_max", "spirit"}) {
+		t.Errorf("GetDimensionNames() = %v, want [qi_max spirit]", got)
+	}
+
+	want := interfaces.DimensionDefinition{Name: "qi_max", Unit: "qi", Kind: enums.DimensionKindDerived, DefaultCaps: interfaces.Caps{Min: 0, Max: 50000}, Subsystem: "jindan"}
+	if got, _ := catalog.GetDimension("qi_max"); got != want {
+		t.Errorf("GetDimension(qi_max) = %+v, want %+v", got, want)
+	}
+
+	// Saved catalogs load back unchanged
+	savedPath := filepath.Join(t.TempDir(), "saved.json")
+	if err := catalog.(*registry.DimensionCatalogImpl).SaveToFile(savedPath); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+
+	loaded, err := registry.NewDimensionCatalogFromFile(savedPath)
+	if err != nil {
+		t.Fatalf("NewDimensionCatalogFromFile() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded.GetDimensions(), catalog.GetDimensions()) {
+		t.Errorf("GetDimensions() = %+v, want %+v", loaded.GetDimensions(), catalog.GetDimensions())
+	}
+}
+
+func TestDimensionCatalogImpl_LoadFromConfig_Invalid(t *testing.T) {
+	dimension := func(fields map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"dimensions": map[string]interface{}{"qi_max": fields}}
+	}
+	caps := map[string]interface{}{"min": 0.0, "max": 100.0}
+
+	invalid := map[string]map[string]interface{}{
+		"MissingDimensions": {},
+		"DimensionsArray":   {"dimensions": []interface{}{"qi_max"}},
+		"EntryNotObject":    dimension(nil),
+		"MissingCaps":       dimension(map[string]interface{}{"kind": "derived"}),
+		"MissingKind":       dimension(map[string]interface{}{"default_caps": caps}),
+		"InvalidKind":       dimension(map[string]interface{}{"kind": "meta", "default_caps": caps}),
+		"InvertedCaps":      dimension(map[string]interface{}{"kind": "derived", "default_caps": map[string]interface{}{"min": 10.0, "max": 1.0}}),
+		"MismatchedName":    dimension(map[string]interface{}{"name": "qi", "kind": "derived", "default_caps": caps}),
+		"UnknownField":      dimension(map[string]interface{}{"kind": "derived", "default_caps": caps, "units": "qi"}),
+	}
+
+	catalog := registry.NewDimensionCatalog()
+	for name, config := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if err := catalog.LoadFromConfig(config); err == nil {
+				t.Errorf("LoadFromConfig(%v) should return error", config)
+			}
+		})
+	}
+
+	// Failed loads leave the catalog unchanged
+	if catalog.Count() != 30 {
+		t.Errorf("Count() = %v, want the 30 built-in dimensions", catalog.Count())
+	}
+}
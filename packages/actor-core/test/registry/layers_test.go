@@ -1,7 +1,10 @@
 package registry
 
 import (
+	"chaos-actor-module/packages/actor-core/interfaces"
 	"chaos-actor-module/packages/actor-core/registry"
+	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -174,3 +177,345 @@ func TestCapLayerRegistryImpl_Reset(t *testing.T) {
 		t.Errorf("GetAcrossLayerPolicy() after reset = %v, want intersect", policy)
 	}
 }
+
+func TestCapLayerRegistryImpl_DimensionPolicies(t *testing.T) {
+	clr := registry.NewCapLayerRegistry()
+	generation := clr.GetGeneration()
+
+	if err := clr.SetDimensionPolicy("move_speed", "union"); err != nil {
+		t.Fatalf("SetDimensionPolicy() error = %v", err)
+	}
+	if clr.GetGeneration() == generation {
+		t.Error("SetDimensionPolicy() should bump the generation")
+	}
+
+	if policy := clr.GetDimensionPolicy("move_speed"); policy != "union" {
+		t.Errorf("GetDimensionPolicy(move_speed) = %v, want union", policy)
+	}
+	if policy := clr.GetDimensionPolicy("strength"); policy != "intersect" {
+		t.Errorf("GetDimensionPolicy(strength) = %v, want the across-layer policy", policy)
+	}
+
+	policies := clr.GetDimensionPolicies()
+	policies["strength"] = "union"
+	if policy := clr.GetDimensionPolicy("strength"); policy != "intersect" {
+		t.Error("GetDimensionPolicies() should return a copy")
+	}
+
+	if err := clr.SetDimensionPolicy("move_speed", "invalid_policy"); err == nil {
+		t.Error("SetDimensionPolicy() should return error for invalid policy")
+	}
+	if err := clr.SetDimensionPolicy("", "union"); err == nil {
+		t.Error("SetDimensionPolicy() should return error for empty dimension")
+	}
+
+	if err := clr.SetDimensionPolicy("move_speed", ""); err != nil {
+		t.Fatalf("SetDimensionPolicy() error = %v", err)
+	}
+	if policy := clr.GetDimensionPolicy("move_speed"); policy != "intersect" {
+		t.Errorf("GetDimensionPolicy(move_speed) after removal = %v, want intersect", policy)
+	}
+
+	if err := clr.SetDimensionPolicy("move_speed", "prioritized_override"); err != nil {
+		t.Fatalf("SetDimensionPolicy() error = %v", err)
+	}
+	clr.Reset()
+	if len(clr.GetDimensionPolicies()) != 0 {
+		t.Error("Reset() should remove the dimension policies")
+	}
+}
+
+// averagePolicy averages the layer caps
+type averagePolicy struct{}
+
+func (averagePolicy) Name() string {
+	return "average"
+}
+
+func (averagePolicy) Combine(dimension string, layers []interfaces.LayerCaps) (interfaces.Caps, error) {
+	var result interfaces.Caps
+	for _, layer := range layers {
+		result.Min += layer.Caps.Min / float64(len(layers))
+		result.Max += layer.Caps.Max / float64(len(layers))
+	}
+	return result, nil
+}
+
+type namedPolicy struct {
+	averagePolicy
+	name string
+}
+
+func (p namedPolicy) Name() string {
+	return p.name
+}
+
+func TestCapLayerRegistryImpl_RegisterPolicy(t *testing.T) {
+	clr := registry.NewCapLayerRegistry()
+
+	if err := clr.SetAcrossLayerPolicy("average"); err == nil {
+		t.Error("SetAcrossLayerPolicy() should return error for an unregistered policy")
+	}
+
+	if err := clr.RegisterPolicy(averagePolicy{}); err != nil {
+		t.Fatalf("RegisterPolicy() error = %v", err)
+	}
+
+	if err := clr.SetAcrossLayerPolicy("average"); err != nil {
+		t.Errorf("SetAcrossLayerPolicy() error = %v", err)
+	}
+
+	if policy, exists := clr.GetPolicy("average"); !exists || policy.Name() != "average" {
+		t.Errorf("GetPolicy(average) = %v, %v, want the registered policy", policy, exists)
+	}
+
+	for _, name := range []string{"intersect", "union", "prioritized_override"} {
+		if _, exists := clr.GetPolicy(name); !exists {
+			t.Errorf("GetPolicy(%s) should return the built-in policy", name)
+		}
+		if err := clr.RegisterPolicy(namedPolicy{name: name}); err == nil {
+			t.Errorf("RegisterPolicy() should not replace the built-in %s policy", name)
+		}
+	}
+
+	if err := clr.RegisterPolicy(nil); err == nil {
+		t.Error("RegisterPolicy() should return error for nil policy")
+	}
+	if err := clr.RegisterPolicy(namedPolicy{}); err == nil {
+		t.Error("RegisterPolicy() should return error for an unnamed policy")
+	}
+}
+
+func TestCapLayerRegistryImpl_SaveToFile_Policies(t *testing.T) {
+	clr := registry.NewCapLayerRegistry()
+
+	if err := clr.SetLayerOrder([]string{"EVENT", "REALM", "WORLD", "GUILD", "TOTAL"}); err != nil {
+		t.Fatalf("SetLayerOrder() error = %v", err)
+	}
+	if err := clr.SetAcrossLayerPolicy("prioritized_override"); err != nil {
+		t.Fatalf("SetAcrossLayerPolicy() error = %v", err)
+	}
+	if err := clr.SetDimensionPolicy("move_speed", "union"); err != nil {
+		t.Fatalf("SetDimensionPolicy() error = %v", err)
+	}
+
+	filePath := filepath.Join(t.TempDir(), "layers.json")
+	if err := clr.(*registry.CapLayerRegistryImpl).SaveToFile(filePath); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+
+	loaded, err := registry.NewCapLayerRegistryFromFile(filePath)
+	if err != nil {
+		t.Fatalf("NewCapLayerRegistryFromFile() error = %v", err)
+	}
+
+	if policy := loaded.GetAcrossLayerPolicy(); policy != "prioritized_override" {
+		t.Errorf("GetAcrossLayerPolicy() = %v, want prioritized_override", policy)
+	}
+	if policies := loaded.GetDimensionPolicies(); !reflect.DeepEqual(policies, map[string]string{"move_speed": "union"}) {
+		t.Errorf("GetDimensionPolicies() = %v, want move_speed: union", policies)
+	}
+	if order := loaded.GetLayerOrder(); order[0] != "EVENT" {
+		t.Errorf("GetLayerOrder() = %v, want EVENT first", order)
+	}
+
+	invalid := []map[string]interface{}{
+		{"dimension_policies": []interface{}{"union"}},
+		{"dimension_policies": map[string]interface{}{"move_speed": 1}},
+		{"dimension_policies": map[string]interface{}{"move_speed": "invalid_policy"}},
+	}
+	for _, config := range invalid {
+		if err := registry.NewCapLayerRegistry().LoadFromConfig(config); err == nil {
+			t.Errorf("LoadFromConfig(%v) should return error", config)
+		}
+	}
+}
+
+func TestCapLayerRegistryImpl_RegisterLayer(t *testing.T) {
+	clr := registry.NewCapLayerRegistry()
+
+	if err := clr.SetLayerOrder([]string{"PARTY", "REALM"}); err == nil {
+		t.Error("SetLayerOrder() should return error for an unregistered layer")
+	}
+
+	layers := []interfaces.LayerDefinition{
+		{Name: "PARTY", Description: "Caps shared by a party"},
+		{Name: "ZONE", Description: "Caps set by the current zone"},
+		{Name: "SEASON", Description: "Caps raised for the season", DefaultPolicy: "union"},
+		{Name: "INSTANCE", Description: "Caps set by a dungeon instance"},
+	}
+	for _, layer := range layers {
+		if err := clr.RegisterLayer(layer); err != nil {
+			t.Fatalf("RegisterLayer(%s) error = %v", layer.Name, err)
+		}
+	}
+
+	order := []string{"REALM", "PARTY", "ZONE", "SEASON", "INSTANCE", "TOTAL"}
+	if err := clr.SetLayerOrder(order); err != nil {
+		t.Fatalf("SetLayerOrder() error = %v", err)
+	}
+	if err := clr.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	if layer, exists := clr.GetLayer("SEASON"); !exists || layer.DefaultPolicy != "union" || layer.Description == "" {
+		t.Errorf("GetLayer(SEASON) = %+v, %v, want the registered definition", layer, exists)
+	}
+	if layer, exists := clr.GetLayer("REALM"); !exists || layer.Description == "" {
+		t.Errorf("GetLayer(REALM) = %+v, %v, want the built-in definition", layer, exists)
+	}
+
+	var names []string
+	for _, layer := range clr.GetLayers() {
+		names = append(names, layer.Name)
+	}
+	want := []string{"EVENT", "GUILD", "INSTANCE", "PARTY", "REALM", "SEASON", "TOTAL", "WORLD", "ZONE"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("GetLayers() = %v, want %v", names, want)
+	}
+
+	if err := clr.RegisterLayer(interfaces.LayerDefinition{}); err == nil {
+		t.Error("RegisterLayer() should return error for an unnamed layer")
+	}
+	if err := clr.RegisterLayer(interfaces.LayerDefinition{Name: "RAID", DefaultPolicy: "invalid_policy"}); err == nil {
+		t.Error("RegisterLayer() should return error for an invalid default policy")
+	}
+
+	clr.Reset()
+	if _, exists := clr.GetLayer("PARTY"); exists {
+		t.Error("Reset() should remove the registered layers")
+	}
+}
+
+func TestCapLayerRegistryImpl_ValidateScopes(t *testing.T) {
+	clr := registry.NewCapLayerRegistry()
+	if err := clr.RegisterLayer(interfaces.LayerDefinition{Name: "PARTY"}); err != nil {
+		t.Fatalf("RegisterLayer() error = %v", err)
+	}
+
+	caps := []interfaces.CapContribution{
+		{System: "race", Dimension: "strength", Mode: "BASELINE", Kind: "max", Value: 100, Scope: "REALM"},
+		{System: "party", Dimension: "strength", Mode: "ADDITIVE", Kind: "max", Value: 10, Scope: "PARTY"},
+	}
+	if err := clr.ValidateScopes(caps); err != nil {
+		t.Errorf("ValidateScopes() error = %v", err)
+	}
+
+	caps = append(caps,
+		interfaces.CapContribution{System: "raid", Dimension: "strength", Mode: "HARD_MAX", Kind: "max", Value: 50, Scope: "RAID"},
+		interfaces.CapContribution{System: "items", Dimension: "strength", Mode: "HARD_MAX", Kind: "max", Value: 50, Scope: "RAID"},
+		interfaces.CapContribution{System: "items", Dimension: "vitality", Mode: "HARD_MAX", Kind: "max", Value: 50},
+	)
+	err := clr.ValidateScopes(caps)
+	if err == nil {
+		t.Fatal("ValidateScopes() should return error for unknown scopes")
+	}
+	if got, want := err.Error(), `caps reference unknown layers: "" (from items); "RAID" (from items, raid)`; got != want {
+		t.Errorf("ValidateScopes() error = %q, want %q", got, want)
+	}
+}
+
+func TestCapLayerRegistryImpl_SaveToFile_Layers(t *testing.T) {
+	config := map[string]interface{}{
+		"layers": []interface{}{
+			map[string]interface{}{"name": "PARTY", "description": "Caps shared by a party"},
+			map[string]interface{}{"name": "SEASON", "default_policy": "union"},
+		},
+		"order": []interface{}{"REALM", "PARTY", "SEASON", "TOTAL"},
+	}
+
+	clr := registry.NewCapLayerRegistry()
+	if err := clr.LoadFromConfig(config); err != nil {
+		t.Fatalf("LoadFromConfig() error = %v", err)
+	}
+
+	filePath := filepath.Join(t.TempDir(), "layers.json")
+	if err := clr.(*registry.CapLayerRegistryImpl).SaveToFile(filePath); err != nil {
+		t.Fatalf("SaveToFile() error = %v", err)
+	}
+
+	loaded, err := registry.NewCapLayerRegistryFromFile(filePath)
+	if err != nil {
+		t.Fatalf("NewCapLayerRegistryFromFile() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(loaded.GetLayers(), clr.GetLayers()) {
+		t.Errorf("GetLayers() = %+v, want %+v", loaded.GetLayers(), clr.GetLayers())
+	}
+	if order := loaded.GetLayerOrder(); !reflect.DeepEqual(order, []string{"REALM", "PARTY", "SEASON", "TOTAL"}) {
+		t.Errorf("GetLayerOrder() = %v, want the configured order", order)
+	}
+
+	// Loaded layers replace the previously loaded ones
+	if err := loaded.LoadFromConfig(map[string]interface{}{
+		"layers": []interface{}{map[string]interface{}{"name": "ZONE"}},
+		"order":  []interface{}{"ZONE", "REALM"},
+	}); err != nil {
+		t.Fatalf("LoadFromConfig() error = %v", err)
+	}
+	if _, exists := loaded.GetLayer("PARTY"); exists {
+		t.Error("LoadFromConfig() should replace the loaded layers")
+	}
+
+	invalid := []map[string]interface{}{
+		{"layers": map[string]interface{}{"name": "PARTY"}},
+		{"layers": []interface{}{map[string]interface{}{"description": "unnamed"}}},
+		{"layers": []interface{}{map[string]interface{}{"name": "PARTY", "default_policy": "invalid_policy"}}},
+		{"layers": []interface{}{"PARTY"}},
+	}
+	for _, config := range invalid {
+		if err := registry.NewCapLayerRegistry().LoadFromConfig(config); err == nil {
+			t.Errorf("LoadFromConfig(%v) should return error", config)
+		}
+	}
+}
+
+func TestCapLayerRegistryImpl_LoadFromConfig_Atomic(t *testing.T) {
+	clr := registry.NewCapLayerRegistry()
+	if err := clr.LoadFromConfig(map[string]interface{}{
+		"layers": []interface{}{map[string]interface{}{"name": "PARTY"}},
+		"order":  []interface{}{"REALM", "PARTY", "TOTAL"},
+	}); err != nil {
+		t.Fatalf("LoadFromConfig() error = %v", err)
+	}
+	generation := clr.GetGeneration()
+
+	invalid := []map[string]interface{}{
+		// Layers without an order must still cover the current order
+		{"layers": []interface{}{map[string]interface{}{"name": "ZONE"}}},
+		{"layers": []interface{}{map[string]interface{}{"name": "ZONE"}}, "order": []interface{}{"ZONE", "PARTY"}},
+		{"layers": []interface{}{map[string]interface{}{"name": "ZONE"}}, "order": []interface{}{"ZONE"}, "across_policy": "invalid_policy"},
+		{"order": []interface{}{"REALM"}, "dimension_policies": map[string]interface{}{"strength": "invalid_policy"}},
+	}
+	for _, config := range invalid {
+		if err := clr.LoadFromConfig(config); err == nil {
+			t.Errorf("LoadFromConfig(%v) should return error", config)
+		}
+	}
+
+	if _, exists := clr.GetLayer("PARTY"); !exists {
+		t.Error("LoadFromConfig() should keep the layers after a failed load")
+	}
+	if _, exists := clr.GetLayer("ZONE"); exists {
+		t.Error("LoadFromConfig() should not apply the layers of a failed load")
+	}
+	if order := clr.GetLayerOrder(); !reflect.DeepEqual(order, []string{"REALM", "PARTY", "TOTAL"}) {
+		t.Errorf("GetLayerOrder() = %v, want the order before the failed loads", order)
+	}
+	if clr.GetGeneration() != generation {
+		t.Error("LoadFromConfig() should not bump the generation for a failed load")
+	}
+	if err := clr.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	// Layers without an order are accepted if they cover the current order
+	if err := clr.LoadFromConfig(map[string]interface{}{
+		"layers": []interface{}{map[string]interface{}{"name": "PARTY"}, map[string]interface{}{"name": "ZONE"}},
+	}); err != nil {
+		t.Fatalf("LoadFromConfig() error = %v", err)
+	}
+	if _, exists := clr.GetLayer("ZONE"); !exists || clr.GetGeneration() == generation {
+		t.Error("LoadFromConfig() should apply layers covering the current order")
+	}
+}
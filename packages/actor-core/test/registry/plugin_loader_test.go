@@ -0,0 +1,198 @@
+package registry
+
+import (
+	"chaos-actor-module/packages/actor-core/interfaces"
+	"chaos-actor-module/packages/actor-core/registry"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPluginManifest = `
+max_api_level: 4
+subsystems:
+  - id: race
+    priority: 100
+    config:
+      bonus: 5
+  - id: items
+    factory: equipment
+    priority: 50
+  - id: guild
+    factory: equipment
+    enabled: false
+`
+
+// newTestPluginLoader creates a loader with factories that honour the manifest
+// priority and record the config they were given
+func newTestPluginLoader(t *testing.T, configs map[string]map[string]interface{}) *registry.PluginLoader {
+	t.Helper()
+
+	loader := registry.NewPluginLoader()
+	factory := func(entry registry.PluginManifestEntry) (interfaces.Subsystem, error) {
+		configs[entry.SystemID] = entry.Config
+		return &MockSubsystem{systemID: entry.SystemID, priority: entry.Priority}, nil
+	}
+
+	for _, name := range []string{"race", "equipment"} {
+		if err := loader.RegisterFactory(name, factory); err != nil {
+			t.Fatalf("RegisterFactory() error = %v", err)
+		}
+	}
+
+	return loader
+}
+
+func TestPluginLoader_LoadFromFile(t *testing.T) {
+	configs := make(map[string]map[string]interface{})
+	loader := newTestPluginLoader(t, configs)
+
+	if names := loader.GetFactoryNames(); strings.Join(names, ",") != "equipment,race" {
+		t.Errorf("GetFactoryNames() = %v, want [equipment race]", names)
+	}
+	if err := loader.RegisterFactory("race", func(registry.PluginManifestEntry) (interfaces.Subsystem, error) { return nil, nil }); err == nil {
+		t.Error("RegisterFactory() should reject a duplicate name")
+	}
+
+	for _, name := range []string{"plugins.yaml", "plugins.json"} {
+		t.Run(name, func(t *testing.T) {
+			filePath := filepath.Join(t.TempDir(), name)
+			data := []byte(testPluginManifest)
+			if strings.HasSuffix(name, ".json") {
+				data = []byte(`{"max_api_level": 4, "subsystems": [
+					{"id": "race", "priority": 100, "config": {"bonus": 5}},
+					{"id": "items", "factory": "equipment", "priority": 50},
+					{"id": "guild", "factory": "equipment", "enabled": false}]}`)
+			}
+			if err := os.WriteFile(filePath, data, 0644); err != nil {
+				t.Fatalf("WriteFile() error = %v", err)
+			}
+
+			pluginRegistry, err := loader.LoadFromFile(filePath)
+			if err != nil {
+				t.Fatalf("LoadFromFile() error = %v", err)
+			}
+
+			if got := systemIDs(pluginRegistry.GetByPriority()); got != "race,items" {
+				t.Errorf("GetByPriority() = %v, want race,items", got)
+			}
+			if configs["race"]["bonus"] != float64(5) {
+				t.Errorf("race config = %v, want bonus 5", configs["race"])
+			}
+		})
+	}
+}
+
+func TestPluginLoader_Build_Invalid(t *testing.T) {
+	enabled := true
+
+	tests := []struct {
+		name    string
+		entries []registry.PluginManifestEntry
+		want    string
+	}{
+		{
+			name:    "UnknownFactory",
+			entries: []registry.PluginManifestEntry{{SystemID: "weather"}},
+			want:    "factory weather not registered",
+		},
+		{
+			name:    "IgnoredPriority",
+			entries: []registry.PluginManifestEntry{{SystemID: "broken", Factory: "fixed", Priority: 10}},
+			want:    "ignored priority",
+		},
+		{
+			name:    "WrongSystemID",
+			entries: []registry.PluginManifestEntry{{SystemID: "other", Factory: "fixed", Enabled: &enabled}},
+			want:    "created subsystem broken",
+		},
+		{
+			name: "InitializeFailed",
+			entries: []registry.PluginManifestEntry{
+				{SystemID: "race", Factory: "lifecycle"},
+				{SystemID: "broken", Factory: "lifecycle"},
+			},
+			want: "backend unavailable",
+		},
+		{
+			name: "RegisterFailed",
+			entries: []registry.PluginManifestEntry{
+				{SystemID: "race", Factory: "lifecycle"},
+				{SystemID: "guild", Factory: "lifecycle"},
+				{SystemID: "guild", Factory: "lifecycle"},
+			},
+			want: "subsystem guild already registered",
+		},
+		{
+			name: "CreatedWithWrongPriority",
+			entries: []registry.PluginManifestEntry{
+				{SystemID: "race", Factory: "lifecycle"},
+				{SystemID: "items", Factory: "lifecycle", Priority: 10},
+			},
+			want: "ignored priority",
+		},
+		{
+			name: "MissingDependency",
+			entries: []registry.PluginManifestEntry{
+				{SystemID: "items", Factory: "dependent"},
+				{SystemID: "cultivation", Factory: "lifecycle", Enabled: new(bool)},
+			},
+			want: "depends on unregistered subsystem cultivation",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []string
+			loader := registry.NewPluginLoader()
+			_ = loader.RegisterFactory("fixed", func(entry registry.PluginManifestEntry) (interfaces.Subsystem, error) {
+				return &MockSubsystem{systemID: "broken", priority: 1}, nil
+			})
+			_ = loader.RegisterFactory("lifecycle", func(entry registry.PluginManifestEntry) (interfaces.Subsystem, error) {
+				subsystem := &LifecycleMockSubsystem{MockSubsystem: MockSubsystem{systemID: entry.SystemID}, events: &events}
+				if entry.SystemID == "broken" {
+					subsystem.initErr = errors.New("backend unavailable")
+				}
+				return subsystem, nil
+			})
+			_ = loader.RegisterFactory("dependent", func(entry registry.PluginManifestEntry) (interfaces.Subsystem, error) {
+				return &DependentMockSubsystem{MockSubsystem: MockSubsystem{systemID: entry.SystemID}, dependsOn: []string{"cultivation"}}, nil
+			})
+
+			_, err := loader.Build(&registry.PluginManifest{Subsystems: tt.entries})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Build() error = %v, want %q", err, tt.want)
+			}
+
+			// The failed subsystem is shut down, then those registered before
+			// it are unregistered, most recent first
+			wantEvents := map[string]string{
+				"InitializeFailed":         "init:race,init:broken,shutdown:broken,shutdown:race",
+				"RegisterFailed":           "init:race,init:guild,shutdown:guild,shutdown:guild,shutdown:race",
+				"CreatedWithWrongPriority": "init:race,shutdown:items,shutdown:race",
+			}
+			if want, exists := wantEvents[tt.name]; exists && strings.Join(events, ",") != want {
+				t.Errorf("lifecycle events = %v, want %v", events, want)
+			}
+		})
+	}
+}
+
+func TestParsePluginManifest_Invalid(t *testing.T) {
+	tests := []map[string]interface{}{
+		{"subsystems": []interface{}{map[string]interface{}{"factory": "race"}}},
+		{"subsystems": []interface{}{
+			map[string]interface{}{"id": "race"},
+			map[string]interface{}{"id": "race"},
+		}},
+		{"subsystems": "race"},
+	}
+
+	for _, config := range tests {
+		if _, err := registry.ParsePluginManifest(config); err == nil {
+			t.Errorf("ParsePluginManifest(%v) should return error", config)
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package registry
 
 import (
+	"chaos-actor-module/packages/actor-core/constants"
+	"chaos-actor-module/packages/actor-core/enums"
 	"chaos-actor-module/packages/actor-core/interfaces"
 	"chaos-actor-module/packages/actor-core/registry"
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 )
 
 // MockSubsystem for testing
@@ -373,3 +379,404 @@ func TestPluginRegistryImpl_IsEmpty(t *testing.T) {
 		t.Error("IsEmpty() should return false for non-empty registry")
 	}
 }
+
+func TestPluginRegistryImpl_GetGeneration(t *testing.T) {
+	pr := registry.NewPluginRegistry()
+	generation := pr.GetGeneration()
+
+	if err := pr.Register(&MockSubsystem{systemID: "test_system", priority: 100}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if pr.GetGeneration() <= generation {
+		t.Error("Register() should bump the generation")
+	}
+	generation = pr.GetGeneration()
+
+	if err := pr.Register(&MockSubsystem{systemID: "test_system", priority: 100}); err == nil {
+		t.Fatal("Register() should return error for a duplicate subsystem")
+	}
+	if pr.GetGeneration() != generation {
+		t.Error("a failed Register() should not bump the generation")
+	}
+
+	if err := pr.Unregister("test_system"); err != nil {
+		t.Fatalf("Unregister() error = %v", err)
+	}
+	if pr.GetGeneration() <= generation {
+		t.Error("Unregister() should bump the generation")
+	}
+}
+
+// LifecycleMockSubsystem records Initialize and Shutdown calls
+type LifecycleMockSubsystem struct {
+	MockSubsystem
+	initErr     error
+	shutdownErr error
+	events      *[]string
+}
+
+func (l *LifecycleMockSubsystem) Initialize() error {
+	*l.events = append(*l.events, "init:"+l.systemID)
+	return l.initErr
+}
+
+func (l *LifecycleMockSubsystem) Shutdown() error {
+	*l.events = append(*l.events, "shutdown:"+l.systemID)
+	return l.shutdownErr
+}
+
+func TestPluginRegistryImpl_Lifecycle(t *testing.T) {
+	var events []string
+	pr := registry.NewPluginRegistry()
+
+	race := &LifecycleMockSubsystem{MockSubsystem: MockSubsystem{systemID: "race", priority: 100}, events: &events}
+	items := &LifecycleMockSubsystem{MockSubsystem: MockSubsystem{systemID: "items", priority: 50}, events: &events}
+	guild := &LifecycleMockSubsystem{
+		MockSubsystem: MockSubsystem{systemID: "guild", priority: 10},
+		shutdownErr:   errors.New("connection reset"),
+		events:        &events,
+	}
+	broken := &LifecycleMockSubsystem{
+		MockSubsystem: MockSubsystem{systemID: "broken", priority: 10},
+		initErr:       errors.New("backend unavailable"),
+		events:        &events,
+	}
+
+	for _, subsystem := range []*LifecycleMockSubsystem{race, items, guild} {
+		if err := pr.Register(subsystem); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+
+	if err := pr.Register(broken); err == nil {
+		t.Error("Register() should return error when Initialize fails")
+	}
+	if pr.HasSubsystem("broken") {
+		t.Error("Register() should not register a subsystem that failed to initialize")
+	}
+
+	if err := pr.Unregister("items"); err != nil {
+		t.Errorf("Unregister() error = %v", err)
+	}
+
+	err := pr.Close()
+	if err == nil || !strings.Contains(err.Error(), "guild") {
+		t.Errorf("Close() error = %v, want the guild shutdown error", err)
+	}
+	if !pr.IsEmpty() {
+		t.Error("Close() should unregister all subsystems")
+	}
+
+	// Close shuts down in reverse priority order
+	want := []string{"init:race", "init:items", "init:guild", "init:broken", "shutdown:items", "shutdown:guild", "shutdown:race"}
+	if strings.Join(events, ",") != strings.Join(want, ",") {
+		t.Errorf("lifecycle events = %v, want %v", events, want)
+	}
+}
+
+// ReentrantMockSubsystem uses the registry from its Initialize and Shutdown hooks
+type ReentrantMockSubsystem struct {
+	MockSubsystem
+	registry interfaces.PluginRegistry
+	seen     []string
+}
+
+func (r *ReentrantMockSubsystem) Initialize() error {
+	if _, exists := r.registry.Get("race"); exists {
+		r.seen = append(r.seen, "race")
+	}
+	r.seen = append(r.seen, fmt.Sprintf("init:%d", len(r.registry.GetByPriority())))
+	return nil
+}
+
+func (r *ReentrantMockSubsystem) Shutdown() error {
+	r.seen = append(r.seen, fmt.Sprintf("shutdown:%d", len(r.registry.GetAll())))
+	return nil
+}
+
+func TestPluginRegistryImpl_Lifecycle_Reentrant(t *testing.T) {
+	pr := registry.NewPluginRegistry()
+	if err := pr.Register(&MockSubsystem{systemID: "race", priority: 100}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	guild := &ReentrantMockSubsystem{MockSubsystem: MockSubsystem{systemID: "guild", priority: 10}, registry: pr}
+	items := &ReentrantMockSubsystem{MockSubsystem: MockSubsystem{systemID: "items", priority: 50}, registry: pr}
+
+	done := make(chan error, 1)
+	go func() {
+		if err := pr.Register(guild); err != nil {
+			done <- err
+			return
+		}
+		if err := pr.Register(items); err != nil {
+			done <- err
+			return
+		}
+		if err := pr.Unregister("items"); err != nil {
+			done <- err
+			return
+		}
+		done <- pr.Close()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("lifecycle error = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("hooks calling back into the registry deadlocked")
+	}
+
+	if want := []string{"race", "init:1", "shutdown:0"}; strings.Join(guild.seen, ",") != strings.Join(want, ",") {
+		t.Errorf("guild saw %v, want %v", guild.seen, want)
+	}
+	if want := []string{"race", "init:2", "shutdown:2"}; strings.Join(items.seen, ",") != strings.Join(want, ",") {
+		t.Errorf("items saw %v, want %v", items.seen, want)
+	}
+}
+
+// DependentMockSubsystem declares ordering constraints and written dimensions
+type DependentMockSubsystem struct {
+	MockSubsystem
+	dependsOn []string
+	before    []string
+	after     []string
+	writes    []interfaces.DimensionWrite
+}
+
+func (d *DependentMockSubsystem) DependsOn() []string {
+	return d.dependsOn
+}
+
+func (d *DependentMockSubsystem) Before() []string {
+	return d.before
+}
+
+func (d *DependentMockSubsystem) After() []string {
+	return d.after
+}
+
+func (d *DependentMockSubsystem) WritesDimensions() []interfaces.DimensionWrite {
+	return d.writes
+}
+
+func systemIDs(subsystems []interfaces.Subsystem) string {
+	ids := make([]string, len(subsystems))
+	for i, subsystem := range subsystems {
+		ids[i] = subsystem.SystemID()
+	}
+	return strings.Join(ids, ",")
+}
+
+func TestPluginRegistryImpl_GetByPriority_Dependencies(t *testing.T) {
+	pr := registry.NewPluginRegistry()
+
+	subsystems := []interfaces.Subsystem{
+		// items needs the cultivation realm, although it has the higher priority
+		&DependentMockSubsystem{MockSubsystem: MockSubsystem{systemID: "items", priority: 100}, dependsOn: []string{"cultivation"}},
+		&MockSubsystem{systemID: "cultivation", priority: 50},
+		&MockSubsystem{systemID: "race", priority: 50},
+		&DependentMockSubsystem{MockSubsystem: MockSubsystem{systemID: "buffs", priority: 10}, before: []string{"race"}},
+		&DependentMockSubsystem{MockSubsystem: MockSubsystem{systemID: "guild", priority: 200}, after: []string{"items", "missing"}},
+	}
+	for _, subsystem := range subsystems {
+		if err := pr.Register(subsystem); err != nil {
+			t.Fatalf("Register(%s) error = %v", subsystem.SystemID(), err)
+		}
+	}
+
+	want := "cultivation,items,guild,buffs,race"
+	for i := 0; i < 10; i++ {
+		if got := systemIDs(pr.GetByPriority()); got != want {
+			t.Fatalf("GetByPriority() = %v, want %v", got, want)
+		}
+	}
+
+	if err := pr.Unregister("items"); err != nil {
+		t.Fatalf("Unregister() error = %v", err)
+	}
+	if got := systemIDs(pr.GetByPriority()); got != "guild,cultivation,buffs,race" {
+		t.Errorf("GetByPriority() after Unregister = %v, want guild,cultivation,buffs,race", got)
+	}
+}
+
+func TestPluginRegistryImpl_Register_Cycle(t *testing.T) {
+	pr := registry.NewPluginRegistry()
+
+	for _, subsystem := range []interfaces.Subsystem{
+		&DependentMockSubsystem{MockSubsystem: MockSubsystem{systemID: "a", priority: 10}, after: []string{"c"}},
+		&DependentMockSubsystem{MockSubsystem: MockSubsystem{systemID: "b", priority: 10}, dependsOn: []string{"a"}},
+	} {
+		if err := pr.Register(subsystem); err != nil {
+			t.Fatalf("Register(%s) error = %v", subsystem.SystemID(), err)
+		}
+	}
+
+	generation := pr.GetGeneration()
+
+	cyclic := &DependentMockSubsystem{MockSubsystem: MockSubsystem{systemID: "c", priority: 10}, after: []string{"b"}}
+	err := pr.Register(cyclic)
+	if err == nil || !strings.Contains(err.Error(), "a -> b -> c -> a") {
+		t.Errorf("Register() error = %v, want the a -> b -> c -> a cycle", err)
+	}
+	if pr.HasSubsystem("c") || pr.GetGeneration() != generation {
+		t.Error("Register() should not register a subsystem that forms a cycle")
+	}
+
+	self := &DependentMockSubsystem{MockSubsystem: MockSubsystem{systemID: "self", priority: 10}, before: []string{"self"}}
+	if err := pr.Register(self); err == nil {
+		t.Error("Register() should reject a subsystem ordered before itself")
+	}
+}
+
+func TestPluginRegistryImpl_Validate_Dependencies(t *testing.T) {
+	pr := registry.NewPluginRegistry()
+
+	if err := pr.Register(&DependentMockSubsystem{MockSubsystem: MockSubsystem{systemID: "items", priority: 10}, dependsOn: []string{"cultivation"}}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	validator := pr.(*registry.PluginRegistryImpl)
+	if err := validator.Validate(); err == nil || !strings.Contains(err.Error(), "cultivation") {
+		t.Errorf("Validate() error = %v, want the missing cultivation dependency", err)
+	}
+
+	if err := pr.Register(&MockSubsystem{systemID: "cultivation", priority: 10}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err := validator.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+// RecordingLogger records warnings
+type RecordingLogger struct {
+	interfaces.Logger
+	warnings []string
+}
+
+func (r *RecordingLogger) Warn(msg string, fields ...interfaces.Field) {
+	for _, field := range fields {
+		msg += " " + field.Key + "=" + fmt.Sprint(field.Value)
+	}
+	r.warnings = append(r.warnings, msg)
+}
+
+func TestPluginRegistryImpl_GetConflicts(t *testing.T) {
+	pr := registry.NewPluginRegistry()
+	logger := &RecordingLogger{}
+	pr.(*registry.PluginRegistryImpl).SetLogger(logger)
+
+	overrides := func(dimensions ...string) []interfaces.DimensionWrite {
+		writes := make([]interfaces.DimensionWrite, len(dimensions))
+		for i, dimension := range dimensions {
+			writes[i] = interfaces.DimensionWrite{Dimension: dimension, Bucket: enums.BucketOverride}
+		}
+		return writes
+	}
+
+	for _, subsystem := range []interfaces.Subsystem{
+		&DependentMockSubsystem{MockSubsystem: MockSubsystem{systemID: "race", priority: 100}, writes: overrides("strength", "agility")},
+		&DependentMockSubsystem{MockSubsystem: MockSubsystem{systemID: "items", priority: 50}, writes: overrides("strength")},
+		&DependentMockSubsystem{MockSubsystem: MockSubsystem{systemID: "buffs", priority: 100}, writes: []interfaces.DimensionWrite{
+			{Dimension: "strength", Bucket: enums.BucketOverride},
+			{Dimension: "agility", Bucket: enums.BucketFlat},
+		}},
+	} {
+		if err := pr.Register(subsystem); err != nil {
+			t.Fatalf("Register(%s) error = %v", subsystem.SystemID(), err)
+		}
+	}
+
+	conflicts := pr.GetConflicts()
+	if len(conflicts) != 1 {
+		t.Fatalf("GetConflicts() = %+v, want 1 conflict", conflicts)
+	}
+	if conflicts[0].Dimension != "strength" || conflicts[0].Priority != 100 || strings.Join(conflicts[0].Systems, ",") != "buffs,race" {
+		t.Errorf("GetConflicts() = %+v, want strength at 100 between buffs and race", conflicts[0])
+	}
+
+	if len(logger.warnings) != 1 || !strings.Contains(logger.warnings[0], "systems=buffs,race") {
+		t.Errorf("warnings = %v, want one warning for buffs and race", logger.warnings)
+	}
+
+	if err := pr.Unregister("buffs"); err != nil {
+		t.Fatalf("Unregister() error = %v", err)
+	}
+	if conflicts := pr.GetConflicts(); len(conflicts) != 0 {
+		t.Errorf("GetConflicts() after Unregister = %+v, want none", conflicts)
+	}
+}
+
+// VersionedMockSubsystem declares the API level it was built against
+type VersionedMockSubsystem struct {
+	MockSubsystem
+	apiLevel int64
+}
+
+func (v *VersionedMockSubsystem) APILevel() int64 {
+	return v.apiLevel
+}
+
+func TestPluginRegistryImpl_Register_APILevel(t *testing.T) {
+	pr := registry.NewPluginRegistry()
+
+	if minLevel, maxLevel := pr.GetAPILevelRange(); minLevel != constants.APIMinimum || maxLevel != constants.APICurrent {
+		t.Errorf("GetAPILevelRange() = %d-%d, want %d-%d", minLevel, maxLevel, constants.APIMinimum, constants.APICurrent)
+	}
+
+	// Unversioned subsystems are assumed to be current
+	if err := pr.Register(&MockSubsystem{systemID: "race", priority: 100}); err != nil {
+		t.Errorf("Register() error = %v", err)
+	}
+	if err := pr.Register(&VersionedMockSubsystem{MockSubsystem: MockSubsystem{systemID: "items", priority: 50}, apiLevel: constants.APIV1}); err != nil {
+		t.Errorf("Register() error = %v", err)
+	}
+
+	legacy := &VersionedMockSubsystem{MockSubsystem: MockSubsystem{systemID: "guild", priority: 10}, apiLevel: constants.APILegacy}
+	err := pr.Register(legacy)
+	var coreErr *interfaces.ActorCoreError
+	if !errors.As(err, &coreErr) || coreErr.GetCode() != constants.ErrorCodeAPILevelUnsupported || coreErr.GetSystem() != "guild" {
+		t.Fatalf("Register() error = %v, want an S007 error for guild", err)
+	}
+	if pr.HasSubsystem("guild") {
+		t.Error("Register() should not register an unsupported subsystem")
+	}
+
+	// A shim adapts legacy subsystems
+	if err := pr.RegisterShim(constants.APILegacy, func(subsystem interfaces.Subsystem) (interfaces.Subsystem, error) {
+		return &VersionedMockSubsystem{MockSubsystem: MockSubsystem{systemID: subsystem.SystemID(), priority: subsystem.Priority()}, apiLevel: constants.APIV1}, nil
+	}); err != nil {
+		t.Fatalf("RegisterShim() error = %v", err)
+	}
+	if err := pr.Register(legacy); err != nil {
+		t.Fatalf("Register() with shim error = %v", err)
+	}
+	if registered, _ := pr.Get("guild"); registered == interfaces.Subsystem(legacy) {
+		t.Error("Register() should register the shimmed subsystem")
+	}
+
+	// A shim must keep the system ID
+	if err := pr.RegisterShim(0, func(subsystem interfaces.Subsystem) (interfaces.Subsystem, error) {
+		return &MockSubsystem{systemID: "other"}, nil
+	}); err != nil {
+		t.Fatalf("RegisterShim() error = %v", err)
+	}
+	if err := pr.Register(&VersionedMockSubsystem{MockSubsystem: MockSubsystem{systemID: "buffs"}}); err == nil {
+		t.Error("Register() should reject a shim that changes the system ID")
+	}
+
+	// Narrowing the range leaves registered subsystems to Validate
+	if err := pr.SetAPILevelRange(constants.APIV2, constants.APICurrent); err != nil {
+		t.Fatalf("SetAPILevelRange() error = %v", err)
+	}
+	if err := pr.(*registry.PluginRegistryImpl).Validate(); err == nil {
+		t.Error("Validate() should report subsystems outside the API-level range")
+	}
+
+	if err := pr.SetAPILevelRange(3, 2); err == nil {
+		t.Error("SetAPILevelRange() should reject an empty range")
+	}
+}
@@ -0,0 +1,136 @@
+package registry
+
+import (
+	"chaos-actor-module/packages/actor-core/constants"
+	"chaos-actor-module/packages/actor-core/interfaces"
+	"chaos-actor-module/packages/actor-core/registry"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewRealmCapTableFromFile(t *testing.T) {
+	yaml := `
+realms:
+  - id: foundation
+    name: Foundation Establishment
+    caps:
+      qi_max: [0, 5_000]
+    stages:
+      so:   { dantian_capacity: [12_000, 20_000] }
+  - id: golden_core
+    per_tier:
+      t1: { dantian_capacity: [50_000, 80_000] }
+    pill_grade:
+      cuc: { dantian_stability: [240, 400] }
+`
+	filePath := filepath.Join(t.TempDir(), "realms.yml")
+	if err := os.WriteFile(filePath, []byte(yaml), 0644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+
+	table, err := registry.NewRealmCapTableFromFile(filePath)
+	if err != nil {
+		t.Fatalf("NewRealmCapTableFromFile() error = %v", err)
+	}
+
+	if table.GetLayer() != "REALM" {
+		t.Errorf("GetLayer() = %v, want REALM", table.GetLayer())
+	}
+
+	realm, exists := table.GetRealm("foundation")
+	if !exists || realm.Name != "Foundation Establishment" || realm.Caps["qi_max"] != (interfaces.Caps{Min: 0, Max: 5000}) {
+		t.Errorf("GetRealm(foundation) = %+v, %v, want the parsed realm", realm, exists)
+	}
+	if got := len(table.GetRealms()); got != 2 {
+		t.Errorf("GetRealms() = %d realms, want 2", got)
+	}
+
+	// Each range yields a min and a max BASELINE, prioritized by path depth
+	want := []struct {
+		realm     string
+		dimension string
+		kind      string
+		value     float64
+		priority  int64
+	}{
+		{"foundation", "qi_max", "max", 5000, 1},
+		{"foundation", "qi_max", "min", 0, 1},
+		{"foundation/so", "dantian_capacity", "max", 20000, 2},
+		{"foundation/so", "dantian_capacity", "min", 12000, 2},
+		{"golden_core/pill_grade/cuc", "dantian_stability", "max", 400, 3},
+		{"golden_core/pill_grade/cuc", "dantian_stability", "min", 240, 3},
+		{"golden_core/t1", "dantian_capacity", "max", 80000, 2},
+		{"golden_core/t1", "dantian_capacity", "min", 50000, 2},
+	}
+
+	contributions := table.GetContributions()
+	if len(contributions) != len(want) {
+		t.Fatalf("GetContributions() = %+v, want %d contributions", contributions, len(want))
+	}
+	for i, w := range want {
+		c := contributions[i]
+		if c.Realm != w.realm || c.Dimension != w.dimension || c.Kind != w.kind || c.Value != w.value || c.Priority != w.priority {
+			t.Errorf("GetContributions()[%d] = %+v, want %+v", i, c, w)
+		}
+		if c.System != constants.SystemIDRealmCaps || c.Mode != "BASELINE" || c.Scope != "REALM" {
+			t.Errorf("GetContributions()[%d] = %+v, want a realm_caps BASELINE in REALM", i, c)
+		}
+	}
+}
+
+func TestNewRealmCapTableFromFile_JindanAppendix(t *testing.T) {
+	filePath := filepath.Join("..", "..", "..", "leveling-systems", "jindan-system", "docs", "appendix", "realms.example.yml")
+
+	table, err := registry.NewRealmCapTableFromFile(filePath)
+	if err != nil {
+		t.Fatalf("NewRealmCapTableFromFile() error = %v", err)
+	}
+
+	if got := len(table.GetRealms()); got != 5 {
+		t.Errorf("GetRealms() = %d realms, want 5", got)
+	}
+
+	realm, exists := table.GetRealm("golden_core")
+	if !exists || realm.SubRealms["trung"]["dantian_capacity"] != (interfaces.Caps{Min: 70000, Max: 100000}) {
+		t.Errorf("GetRealm(golden_core) = %+v, %v, want the trung stage", realm, exists)
+	}
+	if realm.SubRealms["pill_grade/thuong"]["eff_qi_to_shen"] != (interfaces.Caps{Min: 0.16, Max: 0.40}) {
+		t.Errorf("GetRealm(golden_core) = %+v, want the thuong pill grade", realm)
+	}
+}
+
+func TestParseRealmCapTable_Invalid(t *testing.T) {
+	realm := func(fields map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"realms": []interface{}{fields}}
+	}
+
+	invalid := map[string]map[string]interface{}{
+		"MissingRealms":   {},
+		"LayerNotString":  {"layer": 1.0, "realms": []interface{}{}},
+		"RealmNotObject":  {"realms": []interface{}{"mortal"}},
+		"MissingID":       realm(map[string]interface{}{"name": "Phàm"}),
+		"IDWithSlash":     realm(map[string]interface{}{"id": "qi/1"}),
+		"NameNotString":   realm(map[string]interface{}{"id": "mortal", "name": 1.0}),
+		"RangeNotPair":    realm(map[string]interface{}{"id": "mortal", "caps": map[string]interface{}{"qi_max": []interface{}{1.0}}}),
+		"RangeNotNumeric": realm(map[string]interface{}{"id": "mortal", "caps": map[string]interface{}{"qi_max": []interface{}{"0", "1"}}}),
+		"RangeInverted":   realm(map[string]interface{}{"id": "mortal", "caps": map[string]interface{}{"qi_max": []interface{}{10.0, 1.0}}}),
+		"GroupNotObject":  realm(map[string]interface{}{"id": "mortal", "stages": []interface{}{"so"}}),
+		"DuplicateSubRealm": realm(map[string]interface{}{"id": "foundation",
+			"stages":   map[string]interface{}{"so": map[string]interface{}{}},
+			"per_tier": map[string]interface{}{"so": map[string]interface{}{}},
+		}),
+		"DuplicateRealm": {"realms": []interface{}{
+			map[string]interface{}{"id": "mortal"},
+			map[string]interface{}{"id": "mortal"},
+		}},
+	}
+
+	for name, config := range invalid {
+		t.Run(name, func(t *testing.T) {
+			if _, err := registry.ParseRealmCapTable(config); err == nil {
+				t.Errorf("ParseRealmCapTable(%v) should return error", config)
+			}
+		})
+	}
+}
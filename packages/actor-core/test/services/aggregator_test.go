@@ -0,0 +1,2345 @@
+package services
+
+import (
+	"chaos-actor-module/packages/actor-core/constants"
+	"chaos-actor-module/packages/actor-core/enums"
+	"chaos-actor-module/packages/actor-core/interfaces"
+	"chaos-actor-module/packages/actor-core/registry"
+	"chaos-actor-module/packages/actor-core/services"
+	"chaos-actor-module/packages/actor-core/types"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// MockSubsystem for testing
+type MockSubsystem struct {
+	systemID string
+	priority int64
+	output   *interfaces.SubsystemOutput
+	err      error
+}
+
+func (m *MockSubsystem) SystemID() string {
+	return m.systemID
+}
+
+func (m *MockSubsystem) Priority() int64 {
+	return m.priority
+}
+
+func (m *MockSubsystem) Contribute(ctx context.Context, actor *interfaces.Actor) (*interfaces.SubsystemOutput, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return m.output, nil
+}
+
+// newTestAggregator creates an aggregator without a cache for the given subsystems
+func newTestAggregator(t *testing.T, combinerRegistry interfaces.CombinerRegistry, subsystems ...interfaces.Subsystem) interfaces.Aggregator {
+	t.Helper()
+
+	pluginRegistry := registry.NewPluginRegistry()
+	for _, subsystem := range subsystems {
+		if err := pluginRegistry.Register(subsystem); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+	}
+
+	capsProvider := services.NewCapsProvider(registry.NewCapLayerRegistry())
+
+	return services.NewAggregator(combinerRegistry, capsProvider, pluginRegistry, nil)
+}
+
+// primaryOutput creates a subsystem output with the given primary contributions
+func primaryOutput(contributions ...interfaces.Contribution) *interfaces.SubsystemOutput {
+	return &interfaces.SubsystemOutput{
+		Primary: contributions,
+		Context: make(map[string]interfaces.ModifierPack),
+	}
+}
+
+func TestAggregatorImpl_Resolve_Pipeline(t *testing.T) {
+	subsystem := &MockSubsystem{
+		systemID: "race",
+		priority: 100,
+		output: primaryOutput(
+			interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "race"},
+			interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 5, System: "race"},
+			interfaces.Contribution{Dimension: "strength", Bucket: "POST_ADD", Value: 3, System: "race"},
+		),
+	}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), subsystem)
+
+	snapshot, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "actor", Version: 1})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	if got := snapshot.Primary["strength"]; got != 18 {
+		t.Errorf("Resolve() strength = %v, want 18", got)
+	}
+}
+
+func TestAggregatorImpl_Resolve_Operator(t *testing.T) {
+	contributions := []interfaces.Contribution{
+		{Dimension: "fire_resistance", Bucket: "FLAT", Value: 0.2, System: "items"},
+		{Dimension: "fire_resistance", Bucket: "FLAT", Value: 0.5, System: "talent"},
+		{Dimension: "fire_resistance", Bucket: "MULT", Value: 0.8, System: "race"},
+	}
+
+	tests := []struct {
+		name     string
+		operator string
+		want     float64
+	}{
+		{name: "Sum", operator: "SUM", want: 1.5},
+		{name: "Max", operator: "MAX", want: 0.8},
+		{name: "Min", operator: "MIN", want: 0.2},
+		{name: "Average", operator: "AVERAGE", want: 0.5},
+		{name: "Multiply", operator: "MULTIPLY", want: 0.08},
+		{name: "Intersect", operator: "INTERSECT", want: 0.2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			combinerRegistry := registry.NewCombinerRegistry()
+			err := combinerRegistry.SetRule("fire_resistance", &interfaces.MergeRule{
+				UsePipeline:  false,
+				Operator:     tt.operator,
+				ClampDefault: interfaces.Caps{Min: 0.0, Max: 10.0},
+			})
+			if err != nil {
+				t.Fatalf("SetRule() error = %v", err)
+			}
+
+			subsystem := &MockSubsystem{
+				systemID: "items",
+				priority: 100,
+				output:   primaryOutput(contributions...),
+			}
+
+			aggregator := newTestAggregator(t, combinerRegistry, subsystem)
+
+			snapshot, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "actor", Version: 1})
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+
+			got := snapshot.Primary["fire_resistance"]
+			if diff := got - tt.want; diff > 1e-9 || diff < -1e-9 {
+				t.Errorf("Resolve() fire_resistance = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregatorImpl_Resolve_InvalidOperator(t *testing.T) {
+	combinerRegistry := registry.NewCombinerRegistry()
+	err := combinerRegistry.SetRule("fire_resistance", &interfaces.MergeRule{
+		UsePipeline:  false,
+		Operator:     "MEDIAN",
+		ClampDefault: interfaces.Caps{Min: 0.0, Max: 10.0},
+	})
+	if err != nil {
+		t.Fatalf("SetRule() error = %v", err)
+	}
+
+	subsystem := &MockSubsystem{
+		systemID: "items",
+		priority: 100,
+		output: primaryOutput(
+			interfaces.Contribution{Dimension: "fire_resistance", Bucket: "FLAT", Value: 0.2, System: "items"},
+		),
+	}
+
+	aggregator := newTestAggregator(t, combinerRegistry, subsystem)
+
+	_, err = aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "actor", Version: 1})
+	if err == nil {
+		t.Error("Resolve() should return error for invalid operator")
+	}
+}
+
+func TestAggregatorImpl_Resolve_ClampDefault(t *testing.T) {
+	combinerRegistry := registry.NewCombinerRegistry()
+	err := combinerRegistry.SetRule("strength", &interfaces.MergeRule{
+		UsePipeline:  true,
+		ClampDefault: interfaces.Caps{Min: 0.0, Max: 50.0},
+	})
+	if err != nil {
+		t.Fatalf("SetRule() error = %v", err)
+	}
+
+	subsystem := &MockSubsystem{
+		systemID: "race",
+		priority: 100,
+		output: &interfaces.SubsystemOutput{
+			Primary: []interfaces.Contribution{
+				{Dimension: "strength", Bucket: "FLAT", Value: 80, System: "race"},
+				{Dimension: "vitality", Bucket: "FLAT", Value: 80, System: "race"},
+			},
+			Caps: []interfaces.CapContribution{
+				{System: "race", Dimension: "vitality", Mode: "BASELINE", Kind: "max", Value: 70, Scope: "TOTAL"},
+			},
+		},
+	}
+
+	aggregator := newTestAggregator(t, combinerRegistry, subsystem)
+
+	snapshot, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "actor", Version: 1})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	// No effective cap for strength, so the rule's clamp default applies
+	if got := snapshot.Primary["strength"]; got != 50 {
+		t.Errorf("Resolve() strength = %v, want 50", got)
+	}
+
+	// Effective caps take precedence over the clamp default
+	if got := snapshot.Primary["vitality"]; got != 70 {
+		t.Errorf("Resolve() vitality = %v, want 70", got)
+	}
+}
+
+func TestAggregatorImpl_Resolve_DerivedFormulas(t *testing.T) {
+	formulaRegistry := registry.NewDerivedFormulaRegistry()
+	if err := formulaRegistry.SetFormula("hp_max", "vitality * 10 + strength * 2"); err != nil {
+		t.Fatalf("SetFormula() error = %v", err)
+	}
+	if err := formulaRegistry.SetFormula("attack_power", "strength * 2 + hp_max * 0.01"); err != nil {
+		t.Fatalf("SetFormula() error = %v", err)
+	}
+
+	subsystem := &MockSubsystem{
+		systemID: "race",
+		priority: 100,
+		output: &interfaces.SubsystemOutput{
+			Primary: []interfaces.Contribution{
+				{Dimension: "vitality", Bucket: "FLAT", Value: 20, System: "race"},
+				{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "race"},
+			},
+			Derived: []interfaces.Contribution{
+				{Dimension: "hp_max", Bucket: "POST_ADD", Value: 30, System: "race"},
+			},
+		},
+	}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), subsystem)
+	aggregator.(*services.AggregatorImpl).SetDerivedFormulaRegistry(formulaRegistry)
+
+	snapshot, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "actor", Version: 1})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	// Formula base 220 plus the POST_ADD contribution
+	if got := snapshot.Derived["hp_max"]; got != 250 {
+		t.Errorf("Resolve() hp_max = %v, want 250", got)
+	}
+
+	// attack_power sees the final hp_max value
+	if got := snapshot.Derived["attack_power"]; got != 22.5 {
+		t.Errorf("Resolve() attack_power = %v, want 22.5", got)
+	}
+}
+
+func TestAggregatorImpl_Resolve_ContextModifiers(t *testing.T) {
+	items := &MockSubsystem{
+		systemID: "items",
+		priority: 100,
+		output: &interfaces.SubsystemOutput{
+			Context: map[string]interfaces.ModifierPack{
+				"damage_out": {AdditivePercent: 0.2, Multipliers: []float64{1.5}, PostAdd: 10},
+			},
+		},
+	}
+	buffs := &MockSubsystem{
+		systemID: "buffs",
+		priority: 50,
+		output: &interfaces.SubsystemOutput{
+			Context: map[string]interfaces.ModifierPack{
+				"damage_out": {AdditivePercent: 0.3, Multipliers: []float64{2.0}, PostAdd: 5},
+				"healing":    {AdditivePercent: 0.5},
+			},
+		},
+	}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), items, buffs)
+
+	snapshot, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "actor", Version: 1})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	damage, exists := snapshot.GetContextModifier("damage_out")
+	if !exists {
+		t.Fatal("GetContextModifier() should return merged damage_out pack")
+	}
+
+	if damage.AdditivePercent != 0.5 || damage.PostAdd != 15 || len(damage.Multipliers) != 2 {
+		t.Errorf("GetContextModifier() damage_out = %+v, want summed percents, post-adds and both multipliers", damage)
+	}
+
+	// (100 * 1.5) * 1.5 * 2.0 + 15 = 465
+	if got := snapshot.ApplyContext("damage_out", 100); got != 465 {
+		t.Errorf("ApplyContext() damage_out = %v, want 465", got)
+	}
+
+	if got := snapshot.ApplyContext("healing", 100); got != 150 {
+		t.Errorf("ApplyContext() healing = %v, want 150", got)
+	}
+
+	// Unknown contexts leave the base value unchanged
+	if got := snapshot.ApplyContext("drop_rate", 100); got != 100 {
+		t.Errorf("ApplyContext() drop_rate = %v, want 100", got)
+	}
+}
+
+func TestAggregatorImpl_Explain(t *testing.T) {
+	subsystem := &MockSubsystem{
+		systemID: "race",
+		priority: 100,
+		output: &interfaces.SubsystemOutput{
+			Primary: []interfaces.Contribution{
+				{Dimension: "strength", Bucket: "FLAT", Value: 40, System: "race", Priority: 10},
+				{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "items", Priority: 20},
+				{Dimension: "strength", Bucket: "MULT", Value: 2, System: "buffs", Priority: 5},
+				{Dimension: "strength", Bucket: "POST_ADD", Value: 5, System: "items", Priority: 20},
+				{Dimension: "agility", Bucket: "FLAT", Value: 1, System: "race"},
+			},
+			Caps: []interfaces.CapContribution{
+				{System: "race", Dimension: "strength", Mode: "BASELINE", Kind: "max", Value: 90, Scope: "TOTAL"},
+			},
+		},
+	}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), subsystem)
+
+	trace, err := aggregator.Explain(context.Background(), &interfaces.Actor{ID: "actor", Version: 1}, "strength")
+	if err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+
+	if trace.Kind != "primary" {
+		t.Errorf("Explain() kind = %v, want primary", trace.Kind)
+	}
+
+	// Only the traced dimension is recorded, highest priority first
+	if len(trace.Contributions) != 4 {
+		t.Fatalf("Explain() contributions = %d, want 4", len(trace.Contributions))
+	}
+	if trace.Contributions[0].Priority != 20 || trace.Contributions[3].System != "buffs" {
+		t.Errorf("Explain() contributions not in priority order: %+v", trace.Contributions)
+	}
+
+	wantSteps := []interfaces.AggregationStep{
+		{Stage: "FLAT", Value: 50},
+		{Stage: "MULT", Value: 100},
+		{Stage: "POST_ADD", Value: 105},
+	}
+	if len(trace.Steps) != len(wantSteps) {
+		t.Fatalf("Explain() steps = %+v, want %+v", trace.Steps, wantSteps)
+	}
+	for i, step := range wantSteps {
+		if trace.Steps[i] != step {
+			t.Errorf("Explain() step %d = %+v, want %+v", i, trace.Steps[i], step)
+		}
+	}
+
+	if len(trace.LayerCaps) != 1 || trace.LayerCaps[0].Layer != "TOTAL" {
+		t.Errorf("Explain() layer caps = %+v, want a single TOTAL entry", trace.LayerCaps)
+	}
+	if trace.EffectiveCaps == nil || trace.ClampSource != "effective_caps" {
+		t.Errorf("Explain() should clamp to effective caps, got source %v", trace.ClampSource)
+	}
+
+	if trace.Candidate != 105 || trace.Final != 90 || !trace.IsClamped() {
+		t.Errorf("Explain() candidate = %v, final = %v, want 105 clamped to 90", trace.Candidate, trace.Final)
+	}
+
+	// The trace matches what Resolve writes to the snapshot
+	snapshot, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "actor", Version: 1})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if snapshot.Primary["strength"] != trace.Final {
+		t.Errorf("Explain() final = %v, Resolve() = %v", trace.Final, snapshot.Primary["strength"])
+	}
+
+	data, err := json.Marshal(trace)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	var decoded interfaces.AggregationTrace
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if decoded.Final != trace.Final || len(decoded.Steps) != len(trace.Steps) {
+		t.Errorf("Explain() trace did not round-trip through JSON")
+	}
+}
+
+func TestAggregatorImpl_Explain_Operator(t *testing.T) {
+	combinerRegistry := registry.NewCombinerRegistry()
+	err := combinerRegistry.SetRule("fire_resistance", &interfaces.MergeRule{
+		UsePipeline:  false,
+		Operator:     "MAX",
+		ClampDefault: interfaces.Caps{Min: 0.0, Max: 0.75},
+	})
+	if err != nil {
+		t.Fatalf("SetRule() error = %v", err)
+	}
+
+	subsystem := &MockSubsystem{
+		systemID: "items",
+		priority: 100,
+		output: primaryOutput(
+			interfaces.Contribution{Dimension: "fire_resistance", Bucket: "FLAT", Value: 0.5, System: "items"},
+			interfaces.Contribution{Dimension: "fire_resistance", Bucket: "FLAT", Value: 0.9, System: "talent"},
+		),
+	}
+
+	aggregator := newTestAggregator(t, combinerRegistry, subsystem)
+
+	trace, err := aggregator.Explain(context.Background(), &interfaces.Actor{ID: "actor", Version: 1}, "fire_resistance")
+	if err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+
+	if len(trace.Steps) != 1 || trace.Steps[0].Stage != "MAX" || trace.Steps[0].Value != 0.9 {
+		t.Errorf("Explain() steps = %+v, want a single MAX step of 0.9", trace.Steps)
+	}
+
+	if trace.ClampSource != "clamp_default" || trace.Final != 0.75 {
+		t.Errorf("Explain() clamp source = %v, final = %v, want clamp_default and 0.75", trace.ClampSource, trace.Final)
+	}
+
+	if _, err := aggregator.Explain(context.Background(), &interfaces.Actor{ID: "actor", Version: 1}, "unknown"); err == nil {
+		t.Error("Explain() should return error for an unresolved dimension")
+	}
+}
+
+// FlakySubsystem fails once it has produced its first output
+type FlakySubsystem struct {
+	MockSubsystem
+	calls int
+}
+
+func (f *FlakySubsystem) Contribute(ctx context.Context, actor *interfaces.Actor) (*interfaces.SubsystemOutput, error) {
+	f.calls++
+	if f.calls > 1 {
+		return nil, errors.New("backend unavailable")
+	}
+	return f.output, nil
+}
+
+func TestAggregatorImpl_Resolve_ErrorPolicies(t *testing.T) {
+	race := &MockSubsystem{
+		systemID: "race",
+		priority: 100,
+		output: primaryOutput(
+			interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "race"},
+		),
+	}
+	newItems := func() *FlakySubsystem {
+		return &FlakySubsystem{MockSubsystem: MockSubsystem{
+			systemID: "items",
+			priority: 50,
+			output: primaryOutput(
+				interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 5, System: "items"},
+			),
+		}}
+	}
+	actor := &interfaces.Actor{ID: "actor", Version: 1}
+
+	t.Run("SkipAndRecord", func(t *testing.T) {
+		aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), race, newItems())
+
+		if _, err := aggregator.Resolve(context.Background(), actor); err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+		aggregator.MarkDirty(actor.ID, "items")
+
+		snapshot, err := aggregator.Resolve(context.Background(), actor)
+		if err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+
+		if got := snapshot.Primary["strength"]; got != 10 {
+			t.Errorf("Resolve() strength = %v, want 10", got)
+		}
+
+		failure, exists := snapshot.GetFailure("items")
+		if !exists {
+			t.Fatal("Resolve() should record the failed subsystem")
+		}
+		if failure.UsedLastGood || failure.Error.GetCode() != constants.ErrorCodeSubsystemContributionFailed {
+			t.Errorf("Resolve() failure = %+v, want skipped S001 failure", failure)
+		}
+	})
+
+	t.Run("FailFast", func(t *testing.T) {
+		aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), race, newItems())
+		if err := aggregator.(*services.AggregatorImpl).SetErrorPolicy("items", enums.ErrorPolicyFailFast); err != nil {
+			t.Fatalf("SetErrorPolicy() error = %v", err)
+		}
+
+		if _, err := aggregator.Resolve(context.Background(), actor); err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+		aggregator.MarkDirty(actor.ID, "items")
+
+		_, err := aggregator.Resolve(context.Background(), actor)
+		var coreErr *interfaces.ActorCoreError
+		if !errors.As(err, &coreErr) || coreErr.GetSystem() != "items" {
+			t.Errorf("Resolve() error = %v, want ActorCoreError for items", err)
+		}
+	})
+
+	t.Run("UseLastGood", func(t *testing.T) {
+		aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), race, newItems())
+		if err := aggregator.(*services.AggregatorImpl).SetErrorPolicy("items", enums.ErrorPolicyUseLastGood); err != nil {
+			t.Fatalf("SetErrorPolicy() error = %v", err)
+		}
+
+		if _, err := aggregator.Resolve(context.Background(), actor); err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+		aggregator.MarkDirty(actor.ID, "items")
+
+		snapshot, err := aggregator.Resolve(context.Background(), actor)
+		if err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+
+		if got := snapshot.Primary["strength"]; got != 15 {
+			t.Errorf("Resolve() strength = %v, want 15", got)
+		}
+
+		failure, exists := snapshot.GetFailure("items")
+		if !exists || !failure.UsedLastGood {
+			t.Errorf("Resolve() failure = %+v, want failure served from last good output", failure)
+		}
+	})
+
+	t.Run("InvalidPolicy", func(t *testing.T) {
+		aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), race)
+		if err := aggregator.(*services.AggregatorImpl).SetErrorPolicy("race", enums.ErrorPolicy("RETRY")); err == nil {
+			t.Error("SetErrorPolicy() should return error for invalid policy")
+		}
+	})
+}
+
+func TestAggregatorImpl_Resolve_BucketErrorPolicies(t *testing.T) {
+	rule := &interfaces.MergeRule{UsePipeline: true}
+	race := &MockSubsystem{systemID: "race", priority: 100, output: primaryOutput(
+		interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 100, System: "race"},
+	)}
+	curse := func(value float64) *interfaces.SubsystemOutput {
+		return primaryOutput(interfaces.Contribution{Dimension: "strength", Bucket: "LOGARITHMIC", Value: value, System: "curse"})
+	}
+	actor := &interfaces.Actor{ID: "actor", Version: 1}
+
+	newAggregator := func(t *testing.T, subsystem *MockSubsystem, policy enums.ErrorPolicy) interfaces.Aggregator {
+		combinerRegistry := registry.NewCombinerRegistry()
+		if err := combinerRegistry.SetRule("strength", rule); err != nil {
+			t.Fatalf("SetRule() error = %v", err)
+		}
+
+		aggregator := newTestAggregator(t, combinerRegistry, race, subsystem)
+		if err := aggregator.(*services.AggregatorImpl).SetErrorPolicy("curse", policy); err != nil {
+			t.Fatalf("SetErrorPolicy() error = %v", err)
+		}
+		return aggregator
+	}
+
+	t.Run("FailFast", func(t *testing.T) {
+		aggregator := newAggregator(t, &MockSubsystem{systemID: "curse", priority: 50, output: curse(-1)}, enums.ErrorPolicyFailFast)
+
+		_, err := aggregator.Resolve(context.Background(), actor)
+		var coreErr *interfaces.ActorCoreError
+		if !errors.As(err, &coreErr) || coreErr.GetSystem() != "curse" || coreErr.GetCode() != constants.ErrorCodeValueOutOfRange {
+			t.Fatalf("Resolve() error = %v, want V005 ActorCoreError for curse", err)
+		}
+		if !strings.Contains(err.Error(), "from curse") {
+			t.Errorf("Resolve() error = %v, want contributing system named", err)
+		}
+	})
+
+	t.Run("UseLastGood", func(t *testing.T) {
+		subsystem := &MockSubsystem{systemID: "curse", priority: 50, output: curse(0.5)}
+		aggregator := newAggregator(t, subsystem, enums.ErrorPolicyUseLastGood)
+
+		good, err := aggregator.Resolve(context.Background(), actor)
+		if err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+
+		subsystem.output = curse(-1.5)
+		for i := 0; i < 2; i++ {
+			snapshot, err := aggregator.Resolve(context.Background(), actor)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+
+			if got, want := snapshot.Primary["strength"], good.Primary["strength"]; got != want {
+				t.Errorf("Resolve() strength = %v, want last good %v", got, want)
+			}
+
+			failure, exists := snapshot.GetFailure("curse")
+			if !exists || !failure.UsedLastGood {
+				t.Errorf("Resolve() failure = %+v, want failure served from last good output", failure)
+			}
+		}
+	})
+}
+
+func TestAggregatorImpl_ResolveBatch_AlignedErrors(t *testing.T) {
+	subsystem := &MockSubsystem{
+		systemID: "race",
+		priority: 100,
+		output: primaryOutput(
+			interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "race"},
+		),
+	}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), subsystem)
+
+	actors := []*interfaces.Actor{
+		{ID: "first", Version: 1},
+		nil,
+		{ID: "third", Version: 1},
+	}
+
+	snapshots, err := aggregator.ResolveBatch(context.Background(), actors)
+
+	var batchErr *interfaces.BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("ResolveBatch() error = %v, want *BatchError", err)
+	}
+
+	if len(snapshots) != len(actors) || len(batchErr.Errors) != len(actors) {
+		t.Fatalf("ResolveBatch() results not aligned with input: %d snapshots, %d errors", len(snapshots), len(batchErr.Errors))
+	}
+
+	if snapshots[0] == nil || snapshots[0].ActorID != "first" || snapshots[2] == nil || snapshots[2].ActorID != "third" {
+		t.Errorf("ResolveBatch() snapshots = %v, want first and third resolved in place", snapshots)
+	}
+
+	if snapshots[1] != nil || batchErr.Errors[1] == nil || batchErr.Failed() != 1 {
+		t.Errorf("ResolveBatch() should report only the nil actor as failed, got %v", batchErr.Errors)
+	}
+}
+
+// BarrierSubsystem only succeeds if all subsystems sharing its barrier run concurrently
+type BarrierSubsystem struct {
+	MockSubsystem
+	barrier *sync.WaitGroup
+}
+
+func (b *BarrierSubsystem) Contribute(ctx context.Context, actor *interfaces.Actor) (*interfaces.SubsystemOutput, error) {
+	b.barrier.Done()
+
+	released := make(chan struct{})
+	go func() {
+		b.barrier.Wait()
+		close(released)
+	}()
+
+	select {
+	case <-released:
+		return b.output, nil
+	case <-time.After(time.Second):
+		return nil, errors.New("subsystems did not run concurrently")
+	}
+}
+
+// SlowSubsystem ignores its context and declares its own timeout
+type SlowSubsystem struct {
+	MockSubsystem
+	delay   time.Duration
+	timeout time.Duration
+}
+
+func (s *SlowSubsystem) Contribute(ctx context.Context, actor *interfaces.Actor) (*interfaces.SubsystemOutput, error) {
+	time.Sleep(s.delay)
+	return s.output, nil
+}
+
+func (s *SlowSubsystem) Timeout() time.Duration {
+	return s.timeout
+}
+
+// CachingSlowSubsystem is a SlowSubsystem that opts in to output reuse
+type CachingSlowSubsystem struct {
+	SlowSubsystem
+}
+
+func (c *CachingSlowSubsystem) GetCacheKey(actor *interfaces.Actor) string {
+	return ""
+}
+
+func (c *CachingSlowSubsystem) ShouldCache() bool {
+	return true
+}
+
+func TestAggregatorImpl_Resolve_ParallelSubsystems(t *testing.T) {
+	barrier := &sync.WaitGroup{}
+	barrier.Add(3)
+
+	subsystems := make([]interfaces.Subsystem, 0, 3)
+	for i, systemID := range []string{"race", "items", "buffs"} {
+		subsystems = append(subsystems, &BarrierSubsystem{
+			MockSubsystem: MockSubsystem{
+				systemID: systemID,
+				priority: int64(100 - i*10),
+				output: primaryOutput(
+					interfaces.Contribution{Dimension: "strength", Bucket: "OVERRIDE", Value: float64(i + 1), System: systemID},
+				),
+			},
+			barrier: barrier,
+		})
+	}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), subsystems...)
+	aggregator.(*services.AggregatorImpl).SetSubsystemWorkers(3)
+
+	snapshot, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "actor", Version: 1})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	if snapshot.HasFailures() {
+		t.Fatalf("Resolve() failures = %+v, want none", snapshot.Failures)
+	}
+
+	// Outputs merge in subsystem priority order, so the race override wins
+	if got := snapshot.Primary["strength"]; got != 1 {
+		t.Errorf("Resolve() strength = %v, want 1", got)
+	}
+}
+
+func TestAggregatorImpl_Resolve_SubsystemTimeout(t *testing.T) {
+	guild := &SlowSubsystem{
+		MockSubsystem: MockSubsystem{
+			systemID: "guild",
+			priority: 100,
+			output: primaryOutput(
+				interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 5, System: "guild"},
+			),
+		},
+		delay:   200 * time.Millisecond,
+		timeout: 10 * time.Millisecond,
+	}
+	race := &MockSubsystem{
+		systemID: "race",
+		priority: 50,
+		output: primaryOutput(
+			interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "race"},
+		),
+	}
+
+	for _, workers := range []int{1, 2} {
+		aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), guild, race)
+		aggregator.(*services.AggregatorImpl).SetSubsystemWorkers(workers)
+
+		snapshot, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "actor", Version: 1})
+		if err != nil {
+			t.Fatalf("Resolve() workers = %d error = %v", workers, err)
+		}
+
+		if got := snapshot.Primary["strength"]; got != 10 {
+			t.Errorf("Resolve() workers = %d strength = %v, want 10", workers, got)
+		}
+
+		failure, exists := snapshot.GetFailure("guild")
+		if !exists || failure.Error.GetCode() != constants.ErrorCodeOperationTimeout {
+			t.Errorf("Resolve() workers = %d failure = %+v, want P001 timeout for guild", workers, failure)
+		}
+	}
+}
+
+// BatchMockSubsystem counts single and batch contributions and opts in to
+// output reuse
+type BatchMockSubsystem struct {
+	MockSubsystem
+	contributeCalls int64
+	batchCalls      int64
+	batchSize       int64
+}
+
+func (b *BatchMockSubsystem) Contribute(ctx context.Context, actor *interfaces.Actor) (*interfaces.SubsystemOutput, error) {
+	atomic.AddInt64(&b.contributeCalls, 1)
+	return b.output, nil
+}
+
+func (b *BatchMockSubsystem) GetCacheKey(actor *interfaces.Actor) string {
+	return ""
+}
+
+func (b *BatchMockSubsystem) ShouldCache() bool {
+	return true
+}
+
+func (b *BatchMockSubsystem) ContributeBatch(ctx context.Context, actors []*interfaces.Actor) ([]*interfaces.SubsystemOutput, error) {
+	atomic.AddInt64(&b.batchCalls, 1)
+	atomic.StoreInt64(&b.batchSize, int64(len(actors)))
+
+	outputs := make([]*interfaces.SubsystemOutput, len(actors))
+	for i, actor := range actors {
+		outputs[i] = primaryOutput(
+			interfaces.Contribution{Dimension: "level", Bucket: "FLAT", Value: float64(actor.Version), System: b.systemID},
+		)
+	}
+	return outputs, nil
+}
+
+func TestAggregatorImpl_ResolveBatch_Deduplicated(t *testing.T) {
+	guild := &BatchMockSubsystem{MockSubsystem: MockSubsystem{systemID: "guild", priority: 100}}
+	race := &MockSubsystem{
+		systemID: "race",
+		priority: 50,
+		output: primaryOutput(
+			interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "race"},
+		),
+	}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), guild, race)
+	aggregator.(*services.AggregatorImpl).SetBatchWorkers(4)
+
+	actors := make([]*interfaces.Actor, 0, 102)
+	for i := 0; i < 100; i++ {
+		actors = append(actors, &interfaces.Actor{ID: fmt.Sprintf("npc-%d", i), Version: 1})
+	}
+	actors = append(actors, &interfaces.Actor{ID: "npc-0", Version: 3}, &interfaces.Actor{ID: "npc-1", Version: 1})
+
+	snapshots, err := aggregator.ResolveBatch(context.Background(), actors)
+	if err != nil {
+		t.Fatalf("ResolveBatch() error = %v", err)
+	}
+
+	if len(snapshots) != len(actors) {
+		t.Fatalf("ResolveBatch() returned %d snapshots, want %d", len(snapshots), len(actors))
+	}
+
+	// Duplicates share one resolve using the highest version
+	if snapshots[0] != snapshots[100] || snapshots[1] != snapshots[101] {
+		t.Error("ResolveBatch() should share one snapshot between duplicate actor IDs")
+	}
+	if snapshots[0].Version != 3 || snapshots[0].Primary["level"] != 3 {
+		t.Errorf("ResolveBatch() npc-0 version = %v level = %v, want 3", snapshots[0].Version, snapshots[0].Primary["level"])
+	}
+
+	for i, snapshot := range snapshots {
+		if snapshot == nil || snapshot.Primary["strength"] != 10 {
+			t.Fatalf("ResolveBatch() snapshot %d = %+v, want strength 10", i, snapshot)
+		}
+	}
+
+	// The batch subsystem is called once for all unique actors
+	if guild.batchCalls != 1 || guild.batchSize != 100 || guild.contributeCalls != 0 {
+		t.Errorf("ResolveBatch() guild batch calls = %d size = %d single calls = %d, want 1, 100, 0",
+			guild.batchCalls, guild.batchSize, guild.contributeCalls)
+	}
+
+	metrics := aggregator.GetMetrics()
+	if metrics.BatchesProcessed != 1 || metrics.BatchActorsProcessed != 100 || metrics.BatchThroughput <= 0 {
+		t.Errorf("GetMetrics() batches = %d actors = %d throughput = %v, want 1, 100 and a positive throughput",
+			metrics.BatchesProcessed, metrics.BatchActorsProcessed, metrics.BatchThroughput)
+	}
+}
+
+func TestAggregatorImpl_ResolveBatch_Cancelled(t *testing.T) {
+	subsystem := &MockSubsystem{
+		systemID: "race",
+		priority: 100,
+		output: primaryOutput(
+			interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "race"},
+		),
+	}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), subsystem)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	actors := []*interfaces.Actor{{ID: "first", Version: 1}, {ID: "second", Version: 1}}
+	snapshots, err := aggregator.ResolveBatch(ctx, actors)
+
+	var batchErr *interfaces.BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("ResolveBatch() error = %v, want *BatchError", err)
+	}
+
+	for i := range actors {
+		if snapshots[i] != nil || !errors.Is(batchErr.Errors[i], context.Canceled) {
+			t.Errorf("ResolveBatch() actor %d snapshot = %v error = %v, want context.Canceled", i, snapshots[i], batchErr.Errors[i])
+		}
+	}
+}
+
+func TestAggregatorImpl_GetMetrics(t *testing.T) {
+	guild := &CachingSlowSubsystem{SlowSubsystem{
+		MockSubsystem: MockSubsystem{
+			systemID: "guild",
+			priority: 100,
+			output: primaryOutput(
+				interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 5, System: "guild"},
+			),
+		},
+		delay: 2 * time.Millisecond,
+	}}
+	items := &MockSubsystem{systemID: "items", priority: 50, err: errors.New("backend unavailable")}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), guild, items)
+
+	for _, actorID := range []string{"first", "first", "second"} {
+		if _, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: actorID, Version: 1}); err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+	}
+	if _, err := aggregator.Resolve(context.Background(), nil); err == nil {
+		t.Fatal("Resolve() should return error for nil actor")
+	}
+
+	metrics := aggregator.GetMetrics()
+
+	if metrics.TotalRequests != 4 || metrics.TotalErrors != 1 {
+		t.Errorf("GetMetrics() requests = %d errors = %d, want 4 and 1", metrics.TotalRequests, metrics.TotalErrors)
+	}
+	if metrics.ActiveActors != 2 {
+		t.Errorf("GetMetrics() active actors = %d, want 2", metrics.ActiveActors)
+	}
+	if metrics.SubsystemsProcessed != 6 {
+		t.Errorf("GetMetrics() subsystems processed = %d, want 6", metrics.SubsystemsProcessed)
+	}
+	if metrics.AverageProcessingTime <= 0 || metrics.LastProcessed.IsZero() {
+		t.Errorf("GetMetrics() average = %v last = %v, want both set", metrics.AverageProcessingTime, metrics.LastProcessed)
+	}
+
+	guildMetrics, exists := metrics.Subsystems["guild"]
+	if !exists {
+		t.Fatal("GetMetrics() should include guild subsystem metrics")
+	}
+	// The second resolve of the same actor version reuses guild's retained output
+	if guildMetrics.Calls != 2 || guildMetrics.Errors != 0 || guildMetrics.Latency.Count != 2 {
+		t.Errorf("GetMetrics() guild calls = %d errors = %d observations = %d, want 2, 0, 2",
+			guildMetrics.Calls, guildMetrics.Errors, guildMetrics.Latency.Count)
+	}
+	if guildMetrics.CacheHits != 1 || guildMetrics.CacheMisses != 2 {
+		t.Errorf("GetMetrics() guild cache hits = %d misses = %d, want 1 and 2", guildMetrics.CacheHits, guildMetrics.CacheMisses)
+	}
+	if guildMetrics.Latency.Min < 2*time.Millisecond || guildMetrics.Latency.Percentile(100) < 2*time.Millisecond {
+		t.Errorf("GetMetrics() guild latency min = %v p100 = %v, want at least 2ms", guildMetrics.Latency.Min, guildMetrics.Latency.Percentile(100))
+	}
+
+	// items never reuses a retained output, so its error rate is per call
+	itemsMetrics, exists := aggregator.GetSubsystemMetrics("items")
+	if !exists || itemsMetrics.Errors != 3 || itemsMetrics.GetErrorRate() != 1 {
+		t.Errorf("GetSubsystemMetrics() items = %+v, want 3 errors at a rate of 1", itemsMetrics)
+	}
+}
+
+// ReportingSubsystem reports its own metrics as a PerformanceSubsystem
+type ReportingSubsystem struct {
+	MockSubsystem
+	reported *interfaces.SubsystemMetrics
+}
+
+func (r *ReportingSubsystem) GetMetrics() *interfaces.SubsystemMetrics {
+	return r.reported
+}
+
+func TestAggregatorImpl_GetSubsystemMetrics_PerformanceSubsystem(t *testing.T) {
+	talents := &ReportingSubsystem{
+		MockSubsystem: MockSubsystem{systemID: "talents", priority: 100, output: primaryOutput(
+			interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 5, System: "talents"},
+		)},
+		reported: &interfaces.SubsystemMetrics{CacheHits: 7, CacheMisses: 3, MemoryUsage: 4096},
+	}
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), talents)
+
+	// Reported metrics are available before the subsystem is first called
+	metrics, exists := aggregator.GetSubsystemMetrics("talents")
+	if !exists || metrics.MemoryUsage != 4096 || metrics.Calls != 0 {
+		t.Errorf("GetSubsystemMetrics() talents = %+v, want the reported metrics", metrics)
+	}
+
+	if _, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "actor", Version: 1}); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	metrics, exists = aggregator.GetSubsystemMetrics("talents")
+	if !exists || metrics.Calls != 1 || metrics.MemoryUsage != 4096 || metrics.CacheHits != 7 || metrics.CacheMisses != 3 {
+		t.Errorf("GetSubsystemMetrics() talents = %+v, want 1 call merged with the reported metrics", metrics)
+	}
+	if got := aggregator.GetMetrics().Subsystems["talents"]; got == nil || got.MemoryUsage != 4096 || got.Calls != 1 {
+		t.Errorf("GetMetrics() talents = %+v, want 1 call merged with the reported metrics", got)
+	}
+
+	// Repeated reads do not accumulate the reported counts
+	if metrics, _ := aggregator.GetSubsystemMetrics("talents"); metrics.CacheHits != 7 {
+		t.Errorf("GetSubsystemMetrics() talents cache hits = %d, want 7", metrics.CacheHits)
+	}
+
+	if _, exists := aggregator.GetSubsystemMetrics("unknown"); exists {
+		t.Error("GetSubsystemMetrics() should not report metrics for an unknown subsystem")
+	}
+}
+
+// CountingSubsystem counts Contribute calls
+type CountingSubsystem struct {
+	MockSubsystem
+	calls int64
+}
+
+func (c *CountingSubsystem) Contribute(ctx context.Context, actor *interfaces.Actor) (*interfaces.SubsystemOutput, error) {
+	atomic.AddInt64(&c.calls, 1)
+	return c.output, nil
+}
+
+// newCachingAggregator creates an aggregator with a cache for the given subsystem
+func newCachingAggregator(t *testing.T, subsystem interfaces.Subsystem) (*services.AggregatorImpl, interfaces.CombinerRegistry, interfaces.CapLayerRegistry, interfaces.PluginRegistry) {
+	t.Helper()
+
+	combinerRegistry := registry.NewCombinerRegistry()
+	layerRegistry := registry.NewCapLayerRegistry()
+	pluginRegistry := registry.NewPluginRegistry()
+	if err := pluginRegistry.Register(subsystem); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	aggregator := services.NewAggregator(combinerRegistry, services.NewCapsProvider(layerRegistry), pluginRegistry, registry.NewCache(100, "allkeys-lru"))
+
+	return aggregator.(*services.AggregatorImpl), combinerRegistry, layerRegistry, pluginRegistry
+}
+
+func TestAggregatorImpl_Resolve_CacheKeys(t *testing.T) {
+	actor := &interfaces.Actor{ID: "actor", Version: 1}
+
+	tests := []struct {
+		name   string
+		change func(t *testing.T, combiner interfaces.CombinerRegistry, layers interfaces.CapLayerRegistry, plugins interfaces.PluginRegistry) *interfaces.Actor
+	}{
+		{
+			name: "ActorVersion",
+			change: func(t *testing.T, combiner interfaces.CombinerRegistry, layers interfaces.CapLayerRegistry, plugins interfaces.PluginRegistry) *interfaces.Actor {
+				return &interfaces.Actor{ID: actor.ID, Version: 2}
+			},
+		},
+		{
+			name: "SetRule",
+			change: func(t *testing.T, combiner interfaces.CombinerRegistry, layers interfaces.CapLayerRegistry, plugins interfaces.PluginRegistry) *interfaces.Actor {
+				err := combiner.SetRule("strength", &interfaces.MergeRule{UsePipeline: true, ClampDefault: interfaces.Caps{Min: 0, Max: 5}})
+				if err != nil {
+					t.Fatalf("SetRule() error = %v", err)
+				}
+				return actor
+			},
+		},
+		{
+			name: "SetLayerOrder",
+			change: func(t *testing.T, combiner interfaces.CombinerRegistry, layers interfaces.CapLayerRegistry, plugins interfaces.PluginRegistry) *interfaces.Actor {
+				if err := layers.SetLayerOrder([]string{"WORLD", "REALM", "TOTAL"}); err != nil {
+					t.Fatalf("SetLayerOrder() error = %v", err)
+				}
+				return actor
+			},
+		},
+		{
+			name: "Register",
+			change: func(t *testing.T, combiner interfaces.CombinerRegistry, layers interfaces.CapLayerRegistry, plugins interfaces.PluginRegistry) *interfaces.Actor {
+				if err := plugins.Register(&MockSubsystem{systemID: "items", priority: 50, output: primaryOutput()}); err != nil {
+					t.Fatalf("Register() error = %v", err)
+				}
+				return actor
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			race := &MockSubsystem{
+				systemID: "race",
+				priority: 100,
+				output: primaryOutput(
+					interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "race"},
+				),
+			}
+			aggregator, combiner, layers, plugins := newCachingAggregator(t, race)
+
+			first, err := aggregator.Resolve(context.Background(), actor)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if cached, err := aggregator.Resolve(context.Background(), actor); err != nil || cached != first {
+				t.Fatalf("Resolve() = %p, %v, want the cached snapshot before the change", cached, err)
+			}
+
+			next := tt.change(t, combiner, layers, plugins)
+
+			if actor == next {
+				if _, exists := aggregator.GetCachedSnapshot(actor.ID); exists {
+					t.Error("GetCachedSnapshot() should not return a snapshot from an older generation")
+				}
+			}
+
+			second, err := aggregator.Resolve(context.Background(), next)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+			if second == first {
+				t.Error("Resolve() should not return the cached snapshot after the change")
+			}
+
+			if snapshot, exists := aggregator.GetCachedSnapshot(actor.ID); !exists || snapshot != second {
+				t.Errorf("GetCachedSnapshot() = %v, %v, want the re-resolved snapshot", snapshot, exists)
+			}
+		})
+	}
+}
+
+func TestAggregatorImpl_SetCacheTTL(t *testing.T) {
+	race := &MockSubsystem{systemID: "race", priority: 100, output: primaryOutput()}
+	aggregator, _, _, _ := newCachingAggregator(t, race)
+
+	if got, want := aggregator.GetCacheTTL().String(), "1h0m0s"; got != want {
+		t.Errorf("GetCacheTTL() = %s, want %s", got, want)
+	}
+	if err := aggregator.SetCacheTTL(0); err == nil {
+		t.Error("SetCacheTTL() should return error for a non-positive TTL")
+	}
+	if err := aggregator.SetCacheTTL(20 * time.Millisecond); err != nil {
+		t.Fatalf("SetCacheTTL() error = %v", err)
+	}
+
+	actor := &interfaces.Actor{ID: "actor", Version: 1}
+	first, err := aggregator.Resolve(context.Background(), actor)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if cached, err := aggregator.Resolve(context.Background(), actor); err != nil || cached != first {
+		t.Fatalf("Resolve() = %p, %v, want the cached snapshot", cached, err)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+
+	if _, exists := aggregator.GetCachedSnapshot(actor.ID); exists {
+		t.Error("GetCachedSnapshot() should not return an expired snapshot")
+	}
+	if second, err := aggregator.Resolve(context.Background(), actor); err != nil || second == first {
+		t.Errorf("Resolve() = %p, %v, want a new snapshot after the TTL expired", second, err)
+	}
+}
+
+// CachingMockSubsystem counts Contribute calls and implements CachingSubsystem
+type CachingMockSubsystem struct {
+	CountingSubsystem
+	cacheKey    string
+	shouldCache bool
+}
+
+func (c *CachingMockSubsystem) GetCacheKey(actor *interfaces.Actor) string {
+	return c.cacheKey
+}
+
+func (c *CachingMockSubsystem) ShouldCache() bool {
+	return c.shouldCache
+}
+
+func TestAggregatorImpl_MarkDirty(t *testing.T) {
+	race := &CachingMockSubsystem{shouldCache: true, CountingSubsystem: CountingSubsystem{MockSubsystem: MockSubsystem{
+		systemID: "race",
+		priority: 100,
+		output: primaryOutput(
+			interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "race"},
+		),
+	}}}
+	items := &CachingMockSubsystem{shouldCache: true, CountingSubsystem: CountingSubsystem{MockSubsystem: MockSubsystem{
+		systemID: "items",
+		priority: 50,
+		output: primaryOutput(
+			interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 5, System: "items"},
+		),
+	}}}
+
+	combinerRegistry := registry.NewCombinerRegistry()
+	aggregator := newTestAggregator(t, combinerRegistry, race, items)
+	actor := &interfaces.Actor{ID: "actor", Version: 1}
+
+	resolve := func(actor *interfaces.Actor, wantRace, wantItems int64) *interfaces.Snapshot {
+		t.Helper()
+
+		snapshot, err := aggregator.Resolve(context.Background(), actor)
+		if err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+		if race.calls != wantRace || items.calls != wantItems {
+			t.Errorf("Resolve() race calls = %d items calls = %d, want %d and %d", race.calls, items.calls, wantRace, wantItems)
+		}
+		return snapshot
+	}
+
+	resolve(actor, 1, 1)
+	resolve(actor, 1, 1)
+
+	// Only the dirty subsystem is invoked again
+	items.output = primaryOutput(
+		interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 7, System: "items"},
+	)
+	aggregator.MarkDirty(actor.ID, "items")
+	if snapshot := resolve(actor, 1, 2); snapshot.Primary["strength"] != 17 {
+		t.Errorf("Resolve() strength = %v, want 17 after items changed", snapshot.Primary["strength"])
+	}
+
+	aggregator.MarkDirty(actor.ID)
+	resolve(actor, 2, 3)
+
+	// A new actor version invalidates every retained output
+	resolve(&interfaces.Actor{ID: actor.ID, Version: 2}, 3, 4)
+
+	// Registry changes re-merge the retained outputs without invoking subsystems
+	err := combinerRegistry.SetRule("strength", &interfaces.MergeRule{UsePipeline: true, ClampDefault: interfaces.Caps{Min: 0, Max: 12}})
+	if err != nil {
+		t.Fatalf("SetRule() error = %v", err)
+	}
+	if snapshot := resolve(&interfaces.Actor{ID: actor.ID, Version: 2}, 3, 4); snapshot.Primary["strength"] != 12 {
+		t.Errorf("Resolve() strength = %v, want 12 after SetRule", snapshot.Primary["strength"])
+	}
+
+	// InvalidateCache drops the retained outputs
+	aggregator.InvalidateCache(actor.ID)
+	resolve(&interfaces.Actor{ID: actor.ID, Version: 2}, 4, 5)
+}
+
+func TestAggregatorImpl_Resolve_CachingSubsystem(t *testing.T) {
+	equipment := &CachingMockSubsystem{
+		CountingSubsystem: CountingSubsystem{MockSubsystem: MockSubsystem{systemID: "equipment", priority: 100, output: primaryOutput()}},
+		cacheKey:          "loadout-1",
+		shouldCache:       true,
+	}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), equipment)
+	actor := &interfaces.Actor{ID: "actor", Version: 1}
+
+	steps := []struct {
+		name        string
+		cacheKey    string
+		shouldCache bool
+		wantCalls   int64
+	}{
+		{name: "FirstResolve", cacheKey: "loadout-1", shouldCache: true, wantCalls: 1},
+		{name: "SameKey", cacheKey: "loadout-1", shouldCache: true, wantCalls: 1},
+		{name: "KeyChanged", cacheKey: "loadout-2", shouldCache: true, wantCalls: 2},
+		{name: "CachingDisabled", cacheKey: "loadout-2", shouldCache: false, wantCalls: 3},
+		{name: "CachingDisabledAgain", cacheKey: "loadout-2", shouldCache: false, wantCalls: 4},
+	}
+
+	for _, step := range steps {
+		equipment.cacheKey, equipment.shouldCache = step.cacheKey, step.shouldCache
+
+		if _, err := aggregator.Resolve(context.Background(), actor); err != nil {
+			t.Fatalf("%s: Resolve() error = %v", step.name, err)
+		}
+		if equipment.calls != step.wantCalls {
+			t.Errorf("%s: Resolve() calls = %d, want %d", step.name, equipment.calls, step.wantCalls)
+		}
+	}
+}
+
+func TestAggregatorImpl_Resolve_RetainedOutputs(t *testing.T) {
+	race := &CountingSubsystem{MockSubsystem: MockSubsystem{systemID: "race", priority: 100, output: primaryOutput(
+		interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "race"},
+	)}}
+	equipment := &CachingMockSubsystem{shouldCache: true, CountingSubsystem: CountingSubsystem{MockSubsystem: MockSubsystem{systemID: "equipment", priority: 80, output: primaryOutput(
+		interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 3, System: "equipment"},
+	)}}}
+	items := &FlakySubsystem{MockSubsystem: MockSubsystem{systemID: "items", priority: 50, output: primaryOutput(
+		interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 5, System: "items"},
+	)}}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), race, equipment, items).(*services.AggregatorImpl)
+	if err := aggregator.SetErrorPolicy("items", enums.ErrorPolicyUseLastGood); err != nil {
+		t.Fatalf("SetErrorPolicy() error = %v", err)
+	}
+	actor := &interfaces.Actor{ID: "actor", Version: 1}
+
+	resolve := func() *interfaces.Snapshot {
+		t.Helper()
+		snapshot, err := aggregator.Resolve(context.Background(), actor)
+		if err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+		return snapshot
+	}
+
+	resolve()
+	snapshot := resolve()
+
+	// Subsystems that do not opt in to caching are invoked on every resolve,
+	// but their retained outputs still serve USE_LAST_GOOD
+	if race.calls != 2 || equipment.calls != 1 {
+		t.Errorf("Resolve() race calls = %d equipment calls = %d, want 2 and 1", race.calls, equipment.calls)
+	}
+	if failure, exists := snapshot.GetFailure("items"); !exists || !failure.UsedLastGood || snapshot.Primary["strength"] != 18 {
+		t.Errorf("Resolve() strength = %v failure = %+v, want 18 from the last good items output", snapshot.Primary["strength"], failure)
+	}
+
+	// Retained outputs expire with the cache TTL
+	if err := aggregator.SetCacheTTL(10 * time.Millisecond); err != nil {
+		t.Fatalf("SetCacheTTL() error = %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	snapshot = resolve()
+	if equipment.calls != 2 {
+		t.Errorf("Resolve() equipment calls = %d, want 2 after its output expired", equipment.calls)
+	}
+	if failure, exists := snapshot.GetFailure("items"); !exists || failure.UsedLastGood || snapshot.Primary["strength"] != 13 {
+		t.Errorf("Resolve() strength = %v failure = %+v, want 13 without the expired items output", snapshot.Primary["strength"], failure)
+	}
+}
+
+func TestAggregatorImpl_ResolveBatch_MarkDirty(t *testing.T) {
+	guild := &BatchMockSubsystem{MockSubsystem: MockSubsystem{systemID: "guild", priority: 100}}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), guild)
+	actors := []*interfaces.Actor{{ID: "first", Version: 1}, {ID: "second", Version: 1}, {ID: "third", Version: 1}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := aggregator.ResolveBatch(context.Background(), actors); err != nil {
+			t.Fatalf("ResolveBatch() error = %v", err)
+		}
+	}
+	if guild.batchCalls != 1 || guild.batchSize != 3 {
+		t.Errorf("ResolveBatch() batch calls = %d size = %d, want 1 and 3", guild.batchCalls, guild.batchSize)
+	}
+
+	// Only the dirty actor is passed to the batch subsystem
+	aggregator.MarkDirty("second", "guild")
+	if _, err := aggregator.ResolveBatch(context.Background(), actors); err != nil {
+		t.Fatalf("ResolveBatch() error = %v", err)
+	}
+	if guild.batchCalls != 2 || guild.batchSize != 1 || guild.contributeCalls != 0 {
+		t.Errorf("ResolveBatch() batch calls = %d size = %d single calls = %d, want 2, 1, 0",
+			guild.batchCalls, guild.batchSize, guild.contributeCalls)
+	}
+}
+
+// OptionalMockSubsystem implements the conditional, validating and
+// configurable subsystem interfaces
+type OptionalMockSubsystem struct {
+	CountingSubsystem
+	active      func(actor *interfaces.Actor) bool
+	validateErr error
+
+	mu      sync.Mutex
+	bonus   float64
+	configs int
+}
+
+func (o *OptionalMockSubsystem) IsActive(actor *interfaces.Actor) bool {
+	return o.active == nil || o.active(actor)
+}
+
+func (o *OptionalMockSubsystem) Validate(actor *interfaces.Actor) error {
+	return o.validateErr
+}
+
+func (o *OptionalMockSubsystem) Configure(config map[string]interface{}) error {
+	bonus, ok := config["bonus"].(float64)
+	if !ok {
+		return fmt.Errorf("bonus must be a number")
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.bonus = bonus
+	o.configs++
+	return nil
+}
+
+func (o *OptionalMockSubsystem) Contribute(ctx context.Context, actor *interfaces.Actor) (*interfaces.SubsystemOutput, error) {
+	atomic.AddInt64(&o.calls, 1)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return primaryOutput(
+		interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 10 + o.bonus, System: o.systemID},
+	), nil
+}
+
+func TestAggregatorImpl_Resolve_ConditionalSubsystem(t *testing.T) {
+	cultivation := &OptionalMockSubsystem{
+		CountingSubsystem: CountingSubsystem{MockSubsystem: MockSubsystem{systemID: "cultivation", priority: 100}},
+		active: func(actor *interfaces.Actor) bool {
+			return actor.ID != "npc"
+		},
+	}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), cultivation)
+
+	snapshot, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "npc", Version: 1})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if _, exists := snapshot.Primary["strength"]; exists || cultivation.calls != 0 {
+		t.Errorf("Resolve() strength = %v calls = %d, want an inactive subsystem to be skipped", snapshot.Primary["strength"], cultivation.calls)
+	}
+
+	snapshot, err = aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "player", Version: 1})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if got := snapshot.Primary["strength"]; got != 10 || cultivation.calls != 1 {
+		t.Errorf("Resolve() strength = %v calls = %d, want 10 and 1", got, cultivation.calls)
+	}
+}
+
+func TestAggregatorImpl_Resolve_ValidatingSubsystem(t *testing.T) {
+	cultivation := &OptionalMockSubsystem{
+		CountingSubsystem: CountingSubsystem{MockSubsystem: MockSubsystem{systemID: "cultivation", priority: 100}},
+		validateErr:       errors.New("realm is missing"),
+	}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), cultivation)
+
+	snapshot, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "actor", Version: 1})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	failure, exists := snapshot.GetFailure("cultivation")
+	if !exists || failure.Error.GetCode() != constants.ErrorCodeActorValidationFailed || failure.Error.GetType() != constants.ErrorTypeValidation {
+		t.Errorf("Resolve() failure = %+v, want V007 validation failure", failure)
+	}
+	if cultivation.calls != 0 {
+		t.Errorf("Resolve() calls = %d, want Contribute not to run for an invalid actor", cultivation.calls)
+	}
+}
+
+func TestAggregatorImpl_Resolve_ConfigurableSubsystem(t *testing.T) {
+	cultivation := &OptionalMockSubsystem{
+		CountingSubsystem: CountingSubsystem{MockSubsystem: MockSubsystem{systemID: "cultivation", priority: 100}},
+	}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), cultivation)
+	aggregator.(*services.AggregatorImpl).SetBatchWorkers(8)
+
+	actors := make([]*interfaces.Actor, 0, 40)
+	for i := 0; i < 40; i++ {
+		actors = append(actors, &interfaces.Actor{
+			ID:      fmt.Sprintf("actor-%d", i),
+			Version: 1,
+			Subsystems: []types.Subsystem{
+				{SystemID: "cultivation", Enabled: true, Config: map[string]interface{}{"bonus": float64(i)}},
+			},
+		})
+	}
+
+	snapshots, err := aggregator.ResolveBatch(context.Background(), actors)
+	if err != nil {
+		t.Fatalf("ResolveBatch() error = %v", err)
+	}
+
+	// Every actor sees its own configuration even when resolved concurrently
+	for i, snapshot := range snapshots {
+		if got, want := snapshot.Primary["strength"], 10+float64(i); got != want {
+			t.Errorf("ResolveBatch() actor %d strength = %v, want %v", i, got, want)
+		}
+	}
+	if cultivation.configs != len(actors) {
+		t.Errorf("Configure() calls = %d, want %d", cultivation.configs, len(actors))
+	}
+
+	invalid := &interfaces.Actor{
+		ID:         "invalid",
+		Version:    1,
+		Subsystems: []types.Subsystem{{SystemID: "cultivation", Enabled: true, Config: map[string]interface{}{"bonus": "high"}}},
+	}
+	snapshot, err := aggregator.Resolve(context.Background(), invalid)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if _, exists := snapshot.GetFailure("cultivation"); !exists {
+		t.Error("Resolve() should record a subsystem that failed to configure")
+	}
+}
+
+// OrderedSubsystem records the order in which subsystems contribute
+type OrderedSubsystem struct {
+	MockSubsystem
+	order *[]string
+}
+
+func (o *OrderedSubsystem) Contribute(ctx context.Context, actor *interfaces.Actor) (*interfaces.SubsystemOutput, error) {
+	*o.order = append(*o.order, o.systemID)
+	return primaryOutput(
+		interfaces.Contribution{Dimension: o.systemID, Bucket: "FLAT", Value: 1, System: o.systemID},
+	), nil
+}
+
+func TestAggregatorImpl_Resolve_ActorSubsystems(t *testing.T) {
+	var order []string
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(),
+		&OrderedSubsystem{MockSubsystem: MockSubsystem{systemID: "race", priority: 100}, order: &order},
+		&OrderedSubsystem{MockSubsystem: MockSubsystem{systemID: "cultivation", priority: 50}, order: &order},
+		&OrderedSubsystem{MockSubsystem: MockSubsystem{systemID: "items", priority: 10}, order: &order},
+	)
+
+	tests := []struct {
+		name       string
+		subsystems []types.Subsystem
+		want       []string
+	}{
+		{
+			name: "NoSubsystemList",
+			want: []string{"race", "cultivation", "items"},
+		},
+		{
+			name: "NotListed",
+			subsystems: []types.Subsystem{
+				{SystemID: "race", Enabled: true},
+				{SystemID: "items", Enabled: true},
+			},
+			want: []string{"race", "items"},
+		},
+		{
+			name: "Disabled",
+			subsystems: []types.Subsystem{
+				{SystemID: "race", Enabled: true},
+				{SystemID: "cultivation", Enabled: false},
+				{SystemID: "items", Enabled: true},
+			},
+			want: []string{"race", "items"},
+		},
+		{
+			name: "PriorityOverride",
+			subsystems: []types.Subsystem{
+				{SystemID: "race", Enabled: true},
+				{SystemID: "cultivation", Enabled: true},
+				{SystemID: "items", Enabled: true, Priority: 200},
+			},
+			want: []string{"items", "race", "cultivation"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order = nil
+
+			snapshot, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: tt.name, Version: 1, Subsystems: tt.subsystems})
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+
+			if strings.Join(order, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Resolve() ran %v, want %v", order, tt.want)
+			}
+			if len(snapshot.Primary) != len(tt.want) {
+				t.Errorf("Resolve() primary = %v, want only %v", snapshot.Primary, tt.want)
+			}
+			if strings.Join(snapshot.SubsystemsProcessed, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Resolve() SubsystemsProcessed = %v, want %v", snapshot.SubsystemsProcessed, tt.want)
+			}
+		})
+	}
+}
+
+func TestAggregatorImpl_Resolve_SnapshotMetadata(t *testing.T) {
+	failing := &MockSubsystem{systemID: "items", priority: 10, err: errors.New("boom")}
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(),
+		&SlowSubsystem{MockSubsystem: MockSubsystem{
+			systemID: "race",
+			priority: 100,
+			output:   primaryOutput(interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "race"}),
+		}, delay: 5 * time.Millisecond},
+		failing,
+	)
+
+	snapshot, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "actor", Version: 1})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	if snapshot.ActorID != "actor" {
+		t.Errorf("Resolve() ActorID = %q, want actor", snapshot.ActorID)
+	}
+	if strings.Join(snapshot.SubsystemsProcessed, ",") != "race" {
+		t.Errorf("Resolve() SubsystemsProcessed = %v, want [race]", snapshot.SubsystemsProcessed)
+	}
+	if snapshot.ProcessingTime < 5*time.Millisecond {
+		t.Errorf("Resolve() ProcessingTime = %v, want at least 5ms", snapshot.ProcessingTime)
+	}
+	if snapshot.Timestamp.IsZero() {
+		t.Error("Resolve() Timestamp is zero")
+	}
+}
+
+func TestAggregatorImpl_Resolve_LegacyOutput(t *testing.T) {
+	legacy := &interfaces.LegacySubsystemOutput{
+		Primary: []interfaces.LegacyContribution{
+			{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "race"},
+		},
+		Caps: []interfaces.LegacyCapContribution{
+			{System: "race", Dimension: "strength", Mode: "HARD_MAX", Kind: "max", Value: 8, Scope: "REALM", Tags: []string{"source=race", "innate"}},
+		},
+	}
+
+	output := legacy.ToSubsystemOutput()
+	if output.Primary[0].Bucket != enums.BucketFlat {
+		t.Errorf("ToSubsystemOutput() bucket = %v, want FLAT", output.Primary[0].Bucket)
+	}
+	if tags := output.Caps[0].Tags; tags["source"] != "race" || tags["innate"] != "true" {
+		t.Errorf("ToSubsystemOutput() tags = %v, want source=race and innate=true", tags)
+	}
+	if list := types.TagList(output.Caps[0].Tags); strings.Join(list, ",") != "innate,source=race" {
+		t.Errorf("TagList() = %v, want [innate source=race]", list)
+	}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), &MockSubsystem{systemID: "race", priority: 100, output: output})
+
+	snapshot, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "actor", Version: 1})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	// The converted HARD_MAX cap clamps the contribution
+	if got := snapshot.Primary["strength"]; got != 8 {
+		t.Errorf("Resolve() strength = %v, want 8", got)
+	}
+
+	old := interfaces.ToLegacySnapshot(snapshot)
+	if !old.CreatedAt.Equal(snapshot.Timestamp) || old.Primary["strength"] != 8 {
+		t.Errorf("ToLegacySnapshot() = %+v, want the snapshot's values", old)
+	}
+}
+
+// DependentOrderedSubsystem runs after the subsystems it depends on
+type DependentOrderedSubsystem struct {
+	OrderedSubsystem
+	dependsOn []string
+}
+
+func (d *DependentOrderedSubsystem) DependsOn() []string {
+	return d.dependsOn
+}
+
+func (d *DependentOrderedSubsystem) Before() []string {
+	return nil
+}
+
+func (d *DependentOrderedSubsystem) After() []string {
+	return nil
+}
+
+func TestAggregatorImpl_Resolve_DependencyOrder(t *testing.T) {
+	var order []string
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(),
+		&DependentOrderedSubsystem{
+			OrderedSubsystem: OrderedSubsystem{MockSubsystem: MockSubsystem{systemID: "items", priority: 100}, order: &order},
+			dependsOn:        []string{"cultivation"},
+		},
+		&OrderedSubsystem{MockSubsystem: MockSubsystem{systemID: "cultivation", priority: 50}, order: &order},
+		&OrderedSubsystem{MockSubsystem: MockSubsystem{systemID: "race", priority: 10}, order: &order},
+	)
+
+	// The actor's priority overrides do not break the dependency
+	actor := &interfaces.Actor{ID: "actor", Version: 1, Subsystems: []types.Subsystem{
+		{SystemID: "items", Enabled: true, Priority: 300},
+		{SystemID: "cultivation", Enabled: true, Priority: 5},
+		{SystemID: "race", Enabled: true, Priority: 200},
+	}}
+
+	snapshot, err := aggregator.Resolve(context.Background(), actor)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	want := "race,cultivation,items"
+	if strings.Join(order, ",") != want {
+		t.Errorf("Resolve() ran %v, want %v", order, want)
+	}
+	if strings.Join(snapshot.SubsystemsProcessed, ",") != want {
+		t.Errorf("Resolve() SubsystemsProcessed = %v, want %v", snapshot.SubsystemsProcessed, want)
+	}
+}
+
+func TestAggregatorImpl_Resolve_IncompatibleOutputs(t *testing.T) {
+	withMeta := func(dimension string, meta interfaces.SubsystemMeta) *interfaces.SubsystemOutput {
+		output := primaryOutput(interfaces.Contribution{Dimension: dimension, Bucket: "FLAT", Value: 10, System: meta.System})
+		output.Meta = meta
+		return output
+	}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(),
+		&MockSubsystem{systemID: "race", priority: 100, output: withMeta("strength", interfaces.SubsystemMeta{System: "race", Compatible: true, APILevel: constants.APICurrent})},
+		&MockSubsystem{systemID: "items", priority: 50, output: withMeta("agility", interfaces.SubsystemMeta{System: "items", Compatible: false})},
+		&MockSubsystem{systemID: "guild", priority: 40, output: withMeta("luck", interfaces.SubsystemMeta{System: "race", Compatible: true})},
+		&MockSubsystem{systemID: "buffs", priority: 30, output: withMeta("vitality", interfaces.SubsystemMeta{System: "buffs", Compatible: true, APILevel: constants.APILegacy})},
+		&MockSubsystem{systemID: "weather", priority: 20, output: primaryOutput(interfaces.Contribution{Dimension: "speed", Bucket: "FLAT", Value: 10, System: "weather"})},
+	)
+
+	snapshot, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "actor", Version: 1})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	// Outputs without metadata are accepted
+	if strings.Join(snapshot.SubsystemsProcessed, ",") != "race,weather" {
+		t.Errorf("Resolve() SubsystemsProcessed = %v, want [race weather]", snapshot.SubsystemsProcessed)
+	}
+	for _, dimension := range []string{"agility", "luck", "vitality"} {
+		if _, exists := snapshot.Primary[dimension]; exists {
+			t.Errorf("Resolve() kept %s from an incompatible output", dimension)
+		}
+	}
+
+	for _, systemID := range []string{"items", "guild", "buffs"} {
+		failure, exists := snapshot.GetFailure(systemID)
+		if !exists {
+			t.Errorf("GetFailure(%s) not found", systemID)
+			continue
+		}
+		if failure.Error.GetCode() != constants.ErrorCodeIncompatibleOutput || !failure.Error.IsSystemError() {
+			t.Errorf("GetFailure(%s) error = %+v, want an S008 system error", systemID, failure.Error)
+		}
+	}
+
+	if failure, _ := snapshot.GetFailure("guild"); failure.Error.GetContext()["output_system"] != "race" {
+		t.Errorf("GetFailure(guild) context = %v, want output_system race", failure.Error.GetContext())
+	}
+}
+
+func TestAggregatorImpl_Resolve_UnknownCapScope(t *testing.T) {
+	race := &MockSubsystem{systemID: "race", priority: 100, output: &interfaces.SubsystemOutput{
+		Primary: []interfaces.Contribution{{Dimension: "strength", Bucket: "FLAT", Value: 80, System: "race"}},
+		Caps:    []interfaces.CapContribution{{System: "race", Dimension: "strength", Mode: "HARD_MAX", Kind: "max", Value: 60, Scope: "REALM"}},
+	}}
+	party := &MockSubsystem{systemID: "party", priority: 50, output: &interfaces.SubsystemOutput{
+		Primary: []interfaces.Contribution{{Dimension: "agility", Bucket: "FLAT", Value: 10, System: "party"}},
+		Caps:    []interfaces.CapContribution{{System: "party", Dimension: "strength", Mode: "HARD_MAX", Kind: "max", Value: 50, Scope: "PARTY"}},
+	}}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), race, party)
+	actor := &interfaces.Actor{ID: "actor", Version: 1}
+
+	// PARTY is not a registered layer, so the party output is reported and skipped
+	snapshot, err := aggregator.Resolve(context.Background(), actor)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	if got := snapshot.Primary["strength"]; got != 60 {
+		t.Errorf("Resolve() strength = %v, want 60", got)
+	}
+	if _, exists := snapshot.Primary["agility"]; exists {
+		t.Error("Resolve() kept agility from an output with an unknown cap scope")
+	}
+
+	failure, exists := snapshot.GetFailure("party")
+	if !exists {
+		t.Fatal("Resolve() should record the output with an unknown cap scope")
+	}
+	if failure.Error.GetCode() != constants.ErrorCodeInvalidLayerScope || failure.Error.GetType() != constants.ErrorTypeValidation {
+		t.Errorf("Resolve() failure = %+v, want V003 validation failure", failure.Error)
+	}
+	if !strings.Contains(failure.Error.Error(), `"PARTY" (from party)`) {
+		t.Errorf("Resolve() failure = %v, want the unknown scope reported", failure.Error)
+	}
+
+	if err := aggregator.(*services.AggregatorImpl).SetErrorPolicy("party", enums.ErrorPolicyFailFast); err != nil {
+		t.Fatalf("SetErrorPolicy() error = %v", err)
+	}
+	if _, err := aggregator.Resolve(context.Background(), actor); err == nil {
+		t.Error("Resolve() should fail fast on an unknown cap scope")
+	}
+}
+
+func TestAggregatorImpl_ResolveWithContext_Realm(t *testing.T) {
+	jindan := &MockSubsystem{systemID: "jindan", priority: 100, output: &interfaces.SubsystemOutput{
+		Primary: []interfaces.Contribution{{Dimension: "strength", Bucket: "FLAT", Value: 80, System: "jindan"}},
+		Caps: []interfaces.CapContribution{
+			{System: "jindan", Dimension: "strength", Mode: "HARD_MAX", Kind: "max", Value: 60, Scope: "REALM", Realm: "foundation"},
+			{System: "jindan", Dimension: "strength", Mode: "HARD_MAX", Kind: "max", Value: 20, Scope: "REALM", Realm: "mortal"},
+		},
+	}}
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), jindan)
+
+	actor := &interfaces.Actor{ID: "actor", Version: 1}
+	actor.SetRealm("foundation/so")
+
+	tests := []struct {
+		name    string
+		context map[string]interface{}
+		want    float64
+	}{
+		{name: "ActorRealm", context: nil, want: 60},
+		{name: "ContextRealm", context: map[string]interface{}{constants.ResolveContextRealm: "mortal"}, want: 20},
+		{name: "ContextRealms", context: map[string]interface{}{constants.ResolveContextRealm: []interface{}{"mortal", "foundation"}}, want: 20},
+		// An empty list leaves the actor in no realm, so realm-scoped caps are dropped
+		{name: "NoRealm", context: map[string]interface{}{constants.ResolveContextRealm: []string{}}, want: 80},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot, err := aggregator.ResolveWithContext(context.Background(), actor, tt.context)
+			if err != nil {
+				t.Fatalf("ResolveWithContext() error = %v", err)
+			}
+
+			if got := snapshot.Primary["strength"]; got != tt.want {
+				t.Errorf("ResolveWithContext() strength = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, realm := range []interface{}{42.0, []interface{}{"mortal", 1.0}} {
+		if _, err := aggregator.ResolveWithContext(context.Background(), actor, map[string]interface{}{constants.ResolveContextRealm: realm}); err == nil {
+			t.Errorf("ResolveWithContext() should return error for realm %v", realm)
+		}
+	}
+}
+
+func TestAggregatorImpl_Resolve_RecordsCapHits(t *testing.T) {
+	race := &MockSubsystem{systemID: "race", priority: 100, output: &interfaces.SubsystemOutput{
+		Primary: []interfaces.Contribution{
+			{Dimension: "strength", Bucket: "FLAT", Value: 80, System: "race"},
+			{Dimension: "agility", Bucket: "FLAT", Value: 10, System: "race"},
+		},
+		Caps: []interfaces.CapContribution{
+			{System: "race", Dimension: "strength", Mode: "HARD_MAX", Kind: "max", Value: 60, Scope: "REALM"},
+			{System: "race", Dimension: "agility", Mode: "HARD_MAX", Kind: "max", Value: 60, Scope: "REALM"},
+		},
+	}}
+
+	capsProvider := services.NewCapsProvider(registry.NewCapLayerRegistry())
+	pluginRegistry := registry.NewPluginRegistry()
+	if err := pluginRegistry.Register(race); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	aggregator := services.NewAggregator(registry.NewCombinerRegistry(), capsProvider, pluginRegistry, registry.NewCache(100, "allkeys-lru"))
+	actor := &interfaces.Actor{ID: "actor", Version: 1}
+
+	for i := 0; i < 2; i++ {
+		if _, err := aggregator.ResolveWithContext(context.Background(), actor, map[string]interface{}{constants.ResolveContextProvenance: true}); err != nil {
+			t.Fatalf("ResolveWithContext() error = %v", err)
+		}
+	}
+
+	// Neither snapshots served from the cache nor explanations are counted
+	for i := 0; i < 2; i++ {
+		if _, err := aggregator.Resolve(context.Background(), actor); err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+	}
+	if _, err := aggregator.Explain(context.Background(), actor, "strength"); err != nil {
+		t.Fatalf("Explain() error = %v", err)
+	}
+
+	stats, err := capsProvider.GetCapStatistics(context.Background(), actor, []*interfaces.SubsystemOutput{})
+	if err != nil {
+		t.Fatalf("GetCapStatistics() error = %v", err)
+	}
+
+	if got := stats["resolves"]; got != 3 {
+		t.Errorf("GetCapStatistics() resolves = %v, want 3", got)
+	}
+
+	want := map[string]interfaces.CapHitStatistics{
+		"strength": {Capped: 3, AtMax: 3, Hits: 3},
+		"agility":  {Capped: 3},
+	}
+	if got := stats["cap_hits"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetCapStatistics() cap_hits = %+v, want %+v", got, want)
+	}
+}
+
+// GatedSubsystem blocks in Contribute until its gate is closed
+type GatedSubsystem struct {
+	MockSubsystem
+	entered chan struct{}
+	gate    chan struct{}
+	closed  atomic.Bool
+}
+
+func (g *GatedSubsystem) Contribute(ctx context.Context, actor *interfaces.Actor) (*interfaces.SubsystemOutput, error) {
+	close(g.entered)
+	<-g.gate
+	return g.output, nil
+}
+
+func (g *GatedSubsystem) Initialize() error {
+	return nil
+}
+
+func (g *GatedSubsystem) Shutdown() error {
+	g.closed.Store(true)
+	return nil
+}
+
+func TestAggregatorImpl_SwapPluginRegistry(t *testing.T) {
+	old := &GatedSubsystem{
+		MockSubsystem: MockSubsystem{systemID: "race", priority: 100, output: primaryOutput(
+			interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "race"},
+		)},
+		entered: make(chan struct{}),
+		gate:    make(chan struct{}),
+	}
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), old).(*services.AggregatorImpl)
+
+	replacement := registry.NewPluginRegistry()
+	if err := replacement.Register(&MockSubsystem{systemID: "race", priority: 100, output: primaryOutput(
+		interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 20, System: "race"},
+	)}); err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+
+	inFlight := make(chan *interfaces.Snapshot)
+	go func() {
+		snapshot, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "in-flight", Version: 1})
+		if err != nil {
+			t.Errorf("Resolve() error = %v", err)
+		}
+		inFlight <- snapshot
+	}()
+	<-old.entered
+
+	swapped := make(chan error)
+	go func() {
+		swapped <- aggregator.SwapPluginRegistry(replacement)
+	}()
+
+	select {
+	case err := <-swapped:
+		t.Fatalf("SwapPluginRegistry() returned before the in-flight resolve finished: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(old.gate)
+
+	if snapshot := <-inFlight; snapshot == nil || snapshot.Primary["strength"] != 10 {
+		t.Errorf("in-flight Resolve() = %+v, want strength 10 from the previous registry", snapshot)
+	}
+	if err := <-swapped; err != nil {
+		t.Fatalf("SwapPluginRegistry() error = %v", err)
+	}
+	if !old.closed.Load() {
+		t.Error("SwapPluginRegistry() should close the previous registry")
+	}
+
+	snapshot, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "in-flight", Version: 1})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if snapshot.Primary["strength"] != 20 {
+		t.Errorf("Resolve() strength = %v, want 20 from the new registry", snapshot.Primary["strength"])
+	}
+
+	if err := aggregator.SwapPluginRegistry(nil); err == nil {
+		t.Error("SwapPluginRegistry() should reject a nil registry")
+	}
+}
+
+// AbandonedSubsystem times out while blocked in Contribute and records whether
+// it was shut down before Contribute returned
+type AbandonedSubsystem struct {
+	GatedSubsystem
+	returned      atomic.Bool
+	shutdownEarly atomic.Bool
+}
+
+func (s *AbandonedSubsystem) Contribute(ctx context.Context, actor *interfaces.Actor) (*interfaces.SubsystemOutput, error) {
+	output, err := s.GatedSubsystem.Contribute(ctx, actor)
+	s.returned.Store(true)
+	return output, err
+}
+
+func (s *AbandonedSubsystem) Timeout() time.Duration {
+	return 5 * time.Millisecond
+}
+
+func (s *AbandonedSubsystem) Shutdown() error {
+	if !s.returned.Load() {
+		s.shutdownEarly.Store(true)
+	}
+	return s.GatedSubsystem.Shutdown()
+}
+
+func TestAggregatorImpl_SwapPluginRegistry_AbandonedCall(t *testing.T) {
+	old := &AbandonedSubsystem{GatedSubsystem: GatedSubsystem{
+		MockSubsystem: MockSubsystem{systemID: "race", priority: 100, output: primaryOutput()},
+		entered:       make(chan struct{}),
+		gate:          make(chan struct{}),
+	}}
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), old).(*services.AggregatorImpl)
+
+	// The resolve returns once race times out, leaving its call running
+	snapshot, err := aggregator.Resolve(context.Background(), &interfaces.Actor{ID: "actor", Version: 1})
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if failure, exists := snapshot.GetFailure("race"); !exists || failure.Error.GetCode() != constants.ErrorCodeOperationTimeout {
+		t.Fatalf("Resolve() failure = %+v, want a timeout for race", failure)
+	}
+
+	swapped := make(chan error)
+	go func() {
+		swapped <- aggregator.SwapPluginRegistry(registry.NewPluginRegistry())
+	}()
+
+	select {
+	case err := <-swapped:
+		t.Fatalf("SwapPluginRegistry() returned while an abandoned call was still running: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(old.gate)
+
+	if err := <-swapped; err != nil {
+		t.Fatalf("SwapPluginRegistry() error = %v", err)
+	}
+	if !old.closed.Load() || old.shutdownEarly.Load() {
+		t.Errorf("SwapPluginRegistry() closed = %v early = %v, want closed after Contribute returned", old.closed.Load(), old.shutdownEarly.Load())
+	}
+}
+
+func TestAggregatorImpl_ResolveWithContext_TagFilters(t *testing.T) {
+	race := &CachingMockSubsystem{shouldCache: true, CountingSubsystem: CountingSubsystem{MockSubsystem: MockSubsystem{
+		systemID: "race",
+		priority: 100,
+		output: &interfaces.SubsystemOutput{
+			Primary: []interfaces.Contribution{
+				{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "race"},
+				{Dimension: "strength", Bucket: "FLAT", Value: 5, System: "race", Tags: map[string]string{"scope": "dungeon"}},
+				{Dimension: "strength", Bucket: "FLAT", Value: 100, System: "race", Tags: map[string]string{"scope": "dungeon", "source": "pvp_banned"}},
+			},
+			Caps: []interfaces.CapContribution{
+				{System: "race", Dimension: "strength", Mode: "HARD_MAX", Kind: "max", Value: 50, Scope: "REALM", Tags: map[string]string{"source": "pvp_banned"}},
+			},
+		},
+	}}}
+	aggregator, _, _, _ := newCachingAggregator(t, race)
+	actor := &interfaces.Actor{ID: "actor", Version: 1}
+
+	tests := []struct {
+		name    string
+		context map[string]interface{}
+		want    float64
+	}{
+		{name: "NoFilter", context: nil, want: 50},
+		{name: "Exclude", context: map[string]interface{}{constants.ResolveContextExcludeTags: map[string]string{"source": "pvp_banned"}}, want: 15},
+		// The untagged cap is dropped along with the untagged contribution
+		{name: "Include", context: map[string]interface{}{constants.ResolveContextIncludeTags: []string{"scope=dungeon"}}, want: 105},
+		{name: "IncludeAndExclude", context: map[string]interface{}{
+			constants.ResolveContextIncludeTags: map[string]interface{}{"scope": "dungeon"},
+			constants.ResolveContextExcludeTags: []interface{}{"source=pvp_banned"},
+		}, want: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot, err := aggregator.ResolveWithContext(context.Background(), actor, tt.context)
+			if err != nil {
+				t.Fatalf("ResolveWithContext() error = %v", err)
+			}
+
+			if got := snapshot.Primary["strength"]; got != tt.want {
+				t.Errorf("ResolveWithContext() strength = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Filtered resolves bypass the cache, so the unfiltered snapshot is still cached
+	if snapshot, exists := aggregator.GetCachedSnapshot(actor.ID); !exists || snapshot.Primary["strength"] != 50 {
+		t.Errorf("GetCachedSnapshot() = %v, %v, want the unfiltered snapshot", snapshot, exists)
+	}
+
+	// Filtering happens after Contribute, so retained outputs are reused unmodified
+	if race.calls != 1 {
+		t.Errorf("Contribute() calls = %d, want 1", race.calls)
+	}
+	if got := len(race.output.Primary); got != 3 {
+		t.Errorf("output Primary = %d contributions, want 3 after filtering", got)
+	}
+
+	if _, err := aggregator.ResolveWithContext(context.Background(), actor, map[string]interface{}{constants.ResolveContextExcludeTags: 42}); err == nil {
+		t.Error("ResolveWithContext() should reject malformed tag filters")
+	}
+}
+
+func TestAggregatorImpl_ResolveWithContext_Provenance(t *testing.T) {
+	formulaRegistry := registry.NewDerivedFormulaRegistry()
+	if err := formulaRegistry.SetFormula("hp_max", "vitality * 10"); err != nil {
+		t.Fatalf("SetFormula() error = %v", err)
+	}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(),
+		&MockSubsystem{systemID: "race", priority: 100, output: &interfaces.SubsystemOutput{
+			Primary: []interfaces.Contribution{
+				{Dimension: "vitality", Bucket: "FLAT", Value: 20, System: "race"},
+				{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "race"},
+			},
+			Derived: []interfaces.Contribution{
+				{Dimension: "hp_max", Bucket: "POST_ADD", Value: 30, System: "race"},
+			},
+		}},
+		&MockSubsystem{systemID: "items", priority: 50, output: primaryOutput(
+			interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 5, System: "items"},
+			interfaces.Contribution{Dimension: "vitality", Bucket: "FLAT", Value: 5, System: "items", Tags: map[string]string{"source": "pvp_banned"}},
+		)},
+	)
+	aggregator.(*services.AggregatorImpl).SetDerivedFormulaRegistry(formulaRegistry)
+	actor := &interfaces.Actor{ID: "actor", Version: 1}
+
+	snapshot, err := aggregator.Resolve(context.Background(), actor)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if snapshot.Provenance != nil {
+		t.Errorf("Resolve() Provenance = %v, want none unless requested", snapshot.Provenance)
+	}
+
+	snapshot, err = aggregator.ResolveWithContext(context.Background(), actor, map[string]interface{}{
+		constants.ResolveContextProvenance:  true,
+		constants.ResolveContextExcludeTags: []string{"source=pvp_banned"},
+	})
+	if err != nil {
+		t.Fatalf("ResolveWithContext() error = %v", err)
+	}
+
+	want := map[string]string{
+		"strength": "items,race",
+		"vitality": "race",
+		"hp_max":   constants.SystemIDDerivedFormula + ",race",
+	}
+	for dimension, systems := range want {
+		if got, _ := snapshot.GetProvenance(dimension); strings.Join(got, ",") != systems {
+			t.Errorf("GetProvenance(%s) = %v, want %s", dimension, got, systems)
+		}
+	}
+	if len(snapshot.Provenance) != len(want) {
+		t.Errorf("Provenance = %v, want %d dimensions", snapshot.Provenance, len(want))
+	}
+
+	clone := snapshot.Clone()
+	clone.Provenance["strength"][0] = "changed"
+	if got, _ := snapshot.GetProvenance("strength"); got[0] != "items" {
+		t.Error("Clone() should deep copy the provenance index")
+	}
+}
+
+func TestAggregatorImpl_ResolveWithContext_Conditions(t *testing.T) {
+	conditional := func(value float64, condition string) interfaces.Contribution {
+		return interfaces.Contribution{Dimension: "strength", Bucket: "CONDITIONAL", Value: value, System: "buffs", Tags: map[string]string{constants.TagCondition: condition}}
+	}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), &MockSubsystem{systemID: "buffs", priority: 100, output: primaryOutput(
+		interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "buffs"},
+		conditional(5, `in_combat && has_buff("berserk")`),
+		conditional(20, `in_guild("lotus") && zone_level >= 10`),
+		conditional(100, "pvp"),
+		// CONDITIONAL contributions without a condition always apply
+		interfaces.Contribution{Dimension: "strength", Bucket: "CONDITIONAL", Value: 1, System: "buffs"},
+	)})
+
+	idle := &interfaces.Actor{ID: "idle", Version: 1}
+	berserk := &interfaces.Actor{ID: "berserk", Version: 1}
+	berserk.SetInCombat(true)
+	berserk.AddBuff("berserk")
+	berserk.SetGuildID("lotus")
+
+	tests := []struct {
+		name    string
+		actor   *interfaces.Actor
+		context map[string]interface{}
+		want    float64
+	}{
+		{name: "NoneMet", actor: idle, context: map[string]interface{}{"pvp": false}, want: 11},
+		{name: "ActorState", actor: berserk, context: map[string]interface{}{"pvp": false, "zone_level": 1}, want: 16},
+		{name: "ActorStateAndContext", actor: berserk, context: map[string]interface{}{"pvp": false, "zone_level": 12}, want: 36},
+		{name: "ContextFlag", actor: idle, context: map[string]interface{}{"pvp": true, "zone_level": 12}, want: 111},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snapshot, err := aggregator.ResolveWithContext(context.Background(), tt.actor, tt.context)
+			if err != nil {
+				t.Fatalf("ResolveWithContext() error = %v", err)
+			}
+
+			if got := snapshot.Primary["strength"]; got != tt.want {
+				t.Errorf("ResolveWithContext() strength = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	trace, err := aggregator.ExplainWithContext(context.Background(), berserk, "strength", map[string]interface{}{"pvp": false, "zone_level": 5})
+	if err != nil {
+		t.Fatalf("ExplainWithContext() error = %v", err)
+	}
+
+	wantMet := map[string]bool{
+		`in_combat && has_buff("berserk")`:      true,
+		`in_guild("lotus") && zone_level >= 10`: false,
+		"pvp":                                   false,
+	}
+	if len(trace.Conditions) != len(wantMet) {
+		t.Fatalf("ExplainWithContext() Conditions = %+v, want %d", trace.Conditions, len(wantMet))
+	}
+	for _, condition := range trace.Conditions {
+		if met, exists := wantMet[condition.Condition]; !exists || condition.Met != met {
+			t.Errorf("ExplainWithContext() condition %q met = %v, want %v", condition.Condition, condition.Met, met)
+		}
+	}
+	if trace.Final != 16 || len(trace.Contributions) != 3 {
+		t.Errorf("ExplainWithContext() Final = %v with %d contributions, want 16 with 3", trace.Final, len(trace.Contributions))
+	}
+
+}
+
+func TestAggregatorImpl_SetConditionCacheSize(t *testing.T) {
+	conditional := func(value float64, condition string) interfaces.Contribution {
+		return interfaces.Contribution{Dimension: "strength", Bucket: "CONDITIONAL", Value: value, System: "buffs", Tags: map[string]string{constants.TagCondition: condition}}
+	}
+	buffs := &MockSubsystem{systemID: "buffs", priority: 100, output: primaryOutput(
+		conditional(5, "in_combat"),
+		conditional(20, "zone_level >= 10"),
+		conditional(100, "pvp"),
+	)}
+
+	aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), buffs)
+	impl := aggregator.(*services.AggregatorImpl)
+	if err := impl.SetConditionCacheSize(0); err == nil {
+		t.Error("SetConditionCacheSize() should reject a size of 0")
+	}
+	// Every resolve evicts the conditions it parses again
+	if err := impl.SetConditionCacheSize(1); err != nil {
+		t.Fatalf("SetConditionCacheSize() error = %v", err)
+	}
+
+	actor := &interfaces.Actor{ID: "actor", Version: 1}
+	tests := []struct {
+		context map[string]interface{}
+		want    float64
+	}{
+		{context: map[string]interface{}{"in_combat": true, "zone_level": 1, "pvp": false}, want: 5},
+		{context: map[string]interface{}{"in_combat": false, "zone_level": 12, "pvp": true}, want: 120},
+		{context: map[string]interface{}{"in_combat": true, "zone_level": 12, "pvp": false}, want: 25},
+	}
+
+	for round := 0; round < 2; round++ {
+		for _, tt := range tests {
+			snapshot, err := aggregator.ResolveWithContext(context.Background(), actor, tt.context)
+			if err != nil {
+				t.Fatalf("ResolveWithContext() error = %v", err)
+			}
+			if got := snapshot.Primary["strength"]; got != tt.want {
+				t.Errorf("ResolveWithContext(%v) strength = %v, want %v", tt.context, got, tt.want)
+			}
+		}
+
+		// Parsed conditions are dropped with the registry they came from
+		replacement := registry.NewPluginRegistry()
+		if err := replacement.Register(buffs); err != nil {
+			t.Fatalf("Register() error = %v", err)
+		}
+		if err := impl.SwapPluginRegistry(replacement); err != nil {
+			t.Fatalf("SwapPluginRegistry() error = %v", err)
+		}
+	}
+}
+
+func TestAggregatorImpl_ResolveWithContext_InvalidConditions(t *testing.T) {
+	conditional := func(value float64, condition string) *interfaces.SubsystemOutput {
+		return primaryOutput(interfaces.Contribution{Dimension: "strength", Bucket: "CONDITIONAL", Value: value, System: "buffs", Tags: map[string]string{constants.TagCondition: condition}})
+	}
+	race := &MockSubsystem{systemID: "race", priority: 100, output: primaryOutput(
+		interfaces.Contribution{Dimension: "strength", Bucket: "FLAT", Value: 10, System: "race"},
+	)}
+	actor := &interfaces.Actor{ID: "actor", Version: 1}
+	combat := map[string]interface{}{"in_combat": true}
+
+	for _, condition := range []string{`has_buff(`, "in_comabt", `has_buff(1)`} {
+		t.Run("SkipAndRecord/"+condition, func(t *testing.T) {
+			aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), race, &MockSubsystem{systemID: "buffs", priority: 50, output: conditional(5, condition)})
+
+			snapshot, err := aggregator.ResolveWithContext(context.Background(), actor, combat)
+			if err != nil {
+				t.Fatalf("ResolveWithContext() error = %v", err)
+			}
+
+			if got := snapshot.Primary["strength"]; got != 10 {
+				t.Errorf("ResolveWithContext() strength = %v, want 10", got)
+			}
+
+			failure, exists := snapshot.GetFailure("buffs")
+			if !exists || failure.Error.GetCode() != constants.ErrorCodeInvalidCondition || failure.Error.GetType() != constants.ErrorTypeValidation {
+				t.Errorf("ResolveWithContext() failure = %+v, want V008 validation failure", failure)
+			}
+		})
+	}
+
+	t.Run("UnsetActorFlag", func(t *testing.T) {
+		aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), &MockSubsystem{systemID: "buffs", priority: 50, output: conditional(5, "!in_combat")})
+
+		snapshot, err := aggregator.Resolve(context.Background(), actor)
+		if err != nil {
+			t.Fatalf("Resolve() error = %v", err)
+		}
+		if got := snapshot.Primary["strength"]; got != 5 || len(snapshot.Failures) != 0 {
+			t.Errorf("Resolve() strength = %v with failures %+v, want 5 without failures", got, snapshot.Failures)
+		}
+	})
+
+	t.Run("FailFast", func(t *testing.T) {
+		aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), race, &MockSubsystem{systemID: "buffs", priority: 50, output: conditional(5, "in_comabt")})
+		if err := aggregator.(*services.AggregatorImpl).SetErrorPolicy("buffs", enums.ErrorPolicyFailFast); err != nil {
+			t.Fatalf("SetErrorPolicy() error = %v", err)
+		}
+
+		_, err := aggregator.ResolveWithContext(context.Background(), actor, combat)
+		var coreErr *interfaces.ActorCoreError
+		if !errors.As(err, &coreErr) || coreErr.GetSystem() != "buffs" || coreErr.GetCode() != constants.ErrorCodeInvalidCondition {
+			t.Errorf("ResolveWithContext() error = %v, want V008 ActorCoreError for buffs", err)
+		}
+	})
+
+	t.Run("UseLastGood", func(t *testing.T) {
+		buffs := &MockSubsystem{systemID: "buffs", priority: 50, output: conditional(5, "in_combat")}
+		aggregator := newTestAggregator(t, registry.NewCombinerRegistry(), race, buffs)
+		if err := aggregator.(*services.AggregatorImpl).SetErrorPolicy("buffs", enums.ErrorPolicyUseLastGood); err != nil {
+			t.Fatalf("SetErrorPolicy() error = %v", err)
+		}
+
+		if _, err := aggregator.ResolveWithContext(context.Background(), actor, combat); err != nil {
+			t.Fatalf("ResolveWithContext() error = %v", err)
+		}
+		buffs.output = conditional(50, "in_comabt")
+
+		snapshot, err := aggregator.ResolveWithContext(context.Background(), actor, combat)
+		if err != nil {
+			t.Fatalf("ResolveWithContext() error = %v", err)
+		}
+
+		if got := snapshot.Primary["strength"]; got != 15 {
+			t.Errorf("ResolveWithContext() strength = %v, want 15", got)
+		}
+		if failure, exists := snapshot.GetFailure("buffs"); !exists || !failure.UsedLastGood {
+			t.Errorf("ResolveWithContext() failure = %+v, want failure served from last good output", failure)
+		}
+	})
+}
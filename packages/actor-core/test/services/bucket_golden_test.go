@@ -0,0 +1,151 @@
+package services
+
+import (
+	"chaos-actor-module/packages/actor-core/enums"
+	"chaos-actor-module/packages/actor-core/interfaces"
+	"chaos-actor-module/packages/actor-core/registry"
+	"chaos-actor-module/packages/actor-core/services"
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// bucketGoldenCase is a pipeline case from testdata/bucket_golden.json
+type bucketGoldenCase struct {
+	Name          string                       `json:"name"`
+	Rule          interfaces.MergeRule         `json:"rule"`
+	Contributions []interfaces.Contribution    `json:"contributions"`
+	Want          float64                      `json:"want"`
+	Steps         []interfaces.AggregationStep `json:"steps"`
+	Error         bool                         `json:"error"`
+
+	// Policy is the default error policy, SKIP_AND_RECORD if empty
+	Policy enums.ErrorPolicy `json:"policy"`
+
+	// Failures are the systems expected to fail
+	Failures []string `json:"failures"`
+}
+
+func TestAggregatorImpl_BucketPipeline_Golden(t *testing.T) {
+	data, err := os.ReadFile("testdata/bucket_golden.json")
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+
+	var golden struct {
+		Cases []bucketGoldenCase `json:"cases"`
+	}
+	if err := json.Unmarshal(data, &golden); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	const dimension = "golden"
+
+	for _, tc := range golden.Cases {
+		t.Run(tc.Name, func(t *testing.T) {
+			for i := range tc.Contributions {
+				tc.Contributions[i].Dimension = dimension
+			}
+
+			combinerRegistry := registry.NewCombinerRegistry()
+			if err := combinerRegistry.SetRule(dimension, &tc.Rule); err != nil {
+				t.Fatalf("SetRule() error = %v", err)
+			}
+
+			// Each system contributes through its own subsystem, prioritised in
+			// the order the systems first appear
+			var subsystems []interfaces.Subsystem
+			outputs := make(map[string]*interfaces.SubsystemOutput)
+			for _, contribution := range tc.Contributions {
+				output, exists := outputs[contribution.System]
+				if !exists {
+					output = primaryOutput()
+					outputs[contribution.System] = output
+					subsystems = append(subsystems, &MockSubsystem{
+						systemID: contribution.System,
+						priority: int64(100 - len(subsystems)),
+						output:   output,
+					})
+				}
+				output.Primary = append(output.Primary, contribution)
+			}
+
+			aggregator := newTestAggregator(t, combinerRegistry, subsystems...)
+			if tc.Policy != "" {
+				if err := aggregator.(*services.AggregatorImpl).SetDefaultErrorPolicy(tc.Policy); err != nil {
+					t.Fatalf("SetDefaultErrorPolicy() error = %v", err)
+				}
+			}
+
+			actor := &interfaces.Actor{ID: "actor", Version: 1}
+			trace, err := aggregator.Explain(context.Background(), actor, dimension)
+			if tc.Error {
+				if err == nil {
+					t.Fatalf("Explain() = %v, want error", trace.Final)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Explain() error = %v", err)
+			}
+
+			if !goldenEqual(trace.Final, tc.Want) {
+				t.Errorf("Explain() Final = %v, want %v", trace.Final, tc.Want)
+			}
+
+			if len(trace.Steps) != len(tc.Steps) {
+				t.Fatalf("Explain() Steps = %+v, want %+v", trace.Steps, tc.Steps)
+			}
+			for i, step := range trace.Steps {
+				if step.Stage != tc.Steps[i].Stage || !goldenEqual(step.Value, tc.Steps[i].Value) {
+					t.Errorf("Explain() Steps[%d] = %+v, want %+v", i, step, tc.Steps[i])
+				}
+			}
+
+			snapshot, err := aggregator.Resolve(context.Background(), actor)
+			if err != nil {
+				t.Fatalf("Resolve() error = %v", err)
+			}
+
+			var failures []string
+			for _, failure := range snapshot.Failures {
+				failures = append(failures, failure.System)
+			}
+			if !reflect.DeepEqual(failures, tc.Failures) {
+				t.Errorf("Resolve() Failures = %v, want %v", failures, tc.Failures)
+			}
+		})
+	}
+}
+
+// goldenEqual compares golden values with a relative tolerance
+func goldenEqual(got, want float64) bool {
+	return math.Abs(got-want) <= 1e-9*math.Max(1, math.Abs(want))
+}
+
+func TestMergeRule_BucketOrder(t *testing.T) {
+	rule := &interfaces.MergeRule{UsePipeline: true, BucketOrder: []enums.Bucket{enums.BucketOverride, enums.BucketFlat}}
+	if !rule.IsValid() {
+		t.Fatal("IsValid() = false, want true")
+	}
+
+	want := []enums.Bucket{"OVERRIDE", "FLAT", "MULT", "POST_ADD", "EXPONENTIAL", "LOGARITHMIC", "CONDITIONAL"}
+	if got := rule.GetBucketOrder(); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetBucketOrder() = %v, want %v", got, want)
+	}
+
+	invalid := []*interfaces.MergeRule{
+		{UsePipeline: true, BucketOrder: []enums.Bucket{enums.BucketFlat, enums.BucketFlat}},
+		{UsePipeline: true, BucketOrder: []enums.Bucket{"SQUARE"}},
+		{UsePipeline: true, LogBase: 1},
+		{UsePipeline: true, LogBase: -2},
+	}
+	for _, rule := range invalid {
+		if rule.IsValid() {
+			t.Errorf("IsValid() = true for %+v, want false", rule)
+		}
+	}
+}
@@ -1,11 +1,18 @@
 package services
 
 import (
+	"chaos-actor-module/packages/actor-core/constants"
+	"chaos-actor-module/packages/actor-core/enums"
 	"chaos-actor-module/packages/actor-core/interfaces"
 	"chaos-actor-module/packages/actor-core/registry"
 	"chaos-actor-module/packages/actor-core/services"
 	"context"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestCapsProviderImpl_EffectiveCapsWithinLayer(t *testing.T) {
@@ -19,6 +26,7 @@ func TestCapsProviderImpl_EffectiveCapsWithinLayer(t *testing.T) {
 	actor := &interfaces.Actor{
 		ID:      "test_actor",
 		Version: 1,
+		Data:    map[string]interface{}{"realm": "test_realm"},
 	}
 
 	// Create test outputs
@@ -33,7 +41,7 @@ func TestCapsProviderImpl_EffectiveCapsWithinLayer(t *testing.T) {
 					Priority:  1000,
 					Scope:     "REALM",
 					Realm:     "test_realm",
-					Tags:      []string{"test"},
+					Tags:      map[string]string{"test": "true"},
 				},
 			},
 		},
@@ -82,6 +90,100 @@ func TestCapsProviderImpl_EffectiveCapsWithinLayer_InvalidInput(t *testing.T) {
 	}
 }
 
+func TestCapsProviderImpl_EffectiveCapsWithinLayer_CapModes(t *testing.T) {
+	cp := services.NewCapsProvider(registry.NewCapLayerRegistry())
+	actor := &interfaces.Actor{ID: "test_actor", Version: 1}
+	open := math.MaxFloat64
+
+	capOf := func(system string, mode enums.CapMode, kind string, value float64, priority int64) interfaces.CapContribution {
+		return interfaces.CapContribution{System: system, Dimension: "lifespan_years", Mode: mode, Kind: kind, Value: value, Priority: priority, Scope: "REALM"}
+	}
+
+	tests := []struct {
+		name string
+		caps []interfaces.CapContribution
+		want interfaces.Caps
+	}{
+		{name: "BaselinePlusAdditive", caps: []interfaces.CapContribution{
+			capOf("race", enums.CapModeBaseline, "max", 100, 0),
+			capOf("items", enums.CapModeAdditive, "max", 20, 0),
+			capOf("curse", enums.CapModeAdditive, "max", -5, 0),
+		}, want: interfaces.Caps{Min: -open, Max: 115}},
+		{name: "AdditiveWithoutBaseline", caps: []interfaces.CapContribution{
+			capOf("level_1", enums.CapModeAdditive, "max", 10, 0),
+			capOf("level_2", enums.CapModeAdditive, "max", 10, 0),
+			capOf("level_3", enums.CapModeAdditive, "max", 10, 0),
+		}, want: interfaces.Caps{Min: -open, Max: 30}},
+		{name: "HighestPriorityBaseline", caps: []interfaces.CapContribution{
+			capOf("race", enums.CapModeBaseline, "max", 100, 10),
+			capOf("realm", enums.CapModeBaseline, "max", 200, 20),
+		}, want: interfaces.Caps{Min: -open, Max: 200}},
+		{name: "HardMaxCeiling", caps: []interfaces.CapContribution{
+			capOf("race", enums.CapModeBaseline, "max", 100, 0),
+			capOf("items", enums.CapModeAdditive, "max", 50, 0),
+			capOf("world", enums.CapModeHardMax, "max", 120, 0),
+		}, want: interfaces.Caps{Min: -open, Max: 120}},
+		{name: "HardMaxBeatsOverride", caps: []interfaces.CapContribution{
+			capOf("event", enums.CapModeOverride, "max", 500, 100),
+			capOf("world", enums.CapModeHardMax, "max", 300, 0),
+			capOf("realm", enums.CapModeHardMax, "max", 250, 0),
+		}, want: interfaces.Caps{Min: -open, Max: 250}},
+		{name: "HardMinFloor", caps: []interfaces.CapContribution{
+			capOf("race", enums.CapModeBaseline, "min", 5, 0),
+			capOf("world", enums.CapModeHardMin, "min", 10, 0),
+			capOf("realm", enums.CapModeHardMin, "min", 8, 0),
+		}, want: interfaces.Caps{Min: 10, Max: open}},
+		{name: "HighestPriorityOverride", caps: []interfaces.CapContribution{
+			capOf("race", enums.CapModeBaseline, "max", 100, 50),
+			capOf("buff", enums.CapModeOverride, "max", 50, 1),
+			capOf("event", enums.CapModeOverride, "max", 80, 5),
+		}, want: interfaces.Caps{Min: -open, Max: 80}},
+		{name: "FloorAboveCeiling", caps: []interfaces.CapContribution{
+			capOf("world", enums.CapModeHardMin, "min", 100, 0),
+			capOf("realm", enums.CapModeHardMax, "max", 50, 0),
+		}, want: interfaces.Caps{Min: 50, Max: 50}},
+		{name: "BothBounds", caps: []interfaces.CapContribution{
+			capOf("race", enums.CapModeBaseline, "", 100, 0),
+			capOf("items", enums.CapModeAdditive, "max", 10, 0),
+		}, want: interfaces.Caps{Min: 100, Max: 110}},
+		// Equal priorities fall back to the sort key, so the result is the
+		// same whatever order the subsystems ran in
+		{name: "SortKeyOrder", caps: []interfaces.CapContribution{
+			capOf("b_system", enums.CapModeBaseline, "max", 200, 0),
+			capOf("a_system", enums.CapModeBaseline, "max", 100, 0),
+		}, want: interfaces.Caps{Min: -open, Max: 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, caps := range [][]interfaces.CapContribution{tt.caps, reversed(tt.caps)} {
+				effectiveCaps, err := cp.EffectiveCapsWithinLayer(context.Background(), actor, []*interfaces.SubsystemOutput{{Caps: caps}}, "REALM")
+				if err != nil {
+					t.Fatalf("EffectiveCapsWithinLayer() error = %v", err)
+				}
+
+				if got := effectiveCaps["lifespan_years"]; got != tt.want {
+					t.Errorf("EffectiveCapsWithinLayer() cap = %+v, want %+v", got, tt.want)
+				}
+			}
+		})
+	}
+
+	invalid := []interfaces.CapContribution{capOf("race", "SOFT", "max", 100, 0)}
+	if _, err := cp.EffectiveCapsWithinLayer(context.Background(), actor, []*interfaces.SubsystemOutput{{Caps: invalid}}, "REALM"); err == nil {
+		t.Error("EffectiveCapsWithinLayer() should return error for an invalid cap mode")
+	}
+}
+
+// reversed returns the cap contributions in reverse order
+func reversed(caps []interfaces.CapContribution) []interfaces.CapContribution {
+	result := make([]interfaces.CapContribution, len(caps))
+	for i, cap := range caps {
+		result[len(caps)-1-i] = cap
+	}
+	return result
+}
+
 func TestCapsProviderImpl_EffectiveCapsAcrossLayers(t *testing.T) {
 	layerRegistry := registry.NewCapLayerRegistry()
 	cp := services.NewCapsProvider(layerRegistry)
@@ -89,6 +191,7 @@ func TestCapsProviderImpl_EffectiveCapsAcrossLayers(t *testing.T) {
 	actor := &interfaces.Actor{
 		ID:      "test_actor",
 		Version: 1,
+		Data:    map[string]interface{}{"realm": "test_realm"},
 	}
 
 	outputs := []*interfaces.SubsystemOutput{
@@ -102,7 +205,7 @@ func TestCapsProviderImpl_EffectiveCapsAcrossLayers(t *testing.T) {
 					Priority:  1000,
 					Scope:     "REALM",
 					Realm:     "test_realm",
-					Tags:      []string{"test"},
+					Tags:      map[string]string{"test": "true"},
 				},
 			},
 		},
@@ -118,6 +221,320 @@ func TestCapsProviderImpl_EffectiveCapsAcrossLayers(t *testing.T) {
 	}
 }
 
+func TestCapsProviderImpl_EffectiveCapsAcrossLayers_Policies(t *testing.T) {
+	layers := []enums.Layer{enums.LayerRealm, enums.LayerWorld, enums.LayerEvent, enums.LayerGuild, enums.LayerTotal}
+	policies := []enums.AcrossLayerPolicy{enums.AcrossLayerIntersect, enums.AcrossLayerUnion, enums.AcrossLayerPrioritizedOverride}
+	actor := &interfaces.Actor{ID: "test_actor", Version: 1}
+
+	// The k-th layer caps both dimensions to [k+1, 50+10k], so every pair of
+	// layers overlaps but no two agree
+	layerCaps := func(k int) interfaces.Caps {
+		return interfaces.Caps{Min: float64(k + 1), Max: float64(50 + 10*k)}
+	}
+
+	expected := func(policy enums.AcrossLayerPolicy, present []int) interfaces.Caps {
+		result := layerCaps(present[0])
+		for _, k := range present[1:] {
+			switch policy {
+			case enums.AcrossLayerIntersect:
+				result = result.Intersect(layerCaps(k))
+			case enums.AcrossLayerUnion:
+				result = result.Union(layerCaps(k))
+			}
+		}
+		return result
+	}
+
+	// Every non-empty combination of layers, under every pair of across-layer
+	// and per-dimension policies
+	for mask := 1; mask < 1<<len(layers); mask++ {
+		var present []int
+		var caps []interfaces.CapContribution
+		var names []string
+		for k, layer := range layers {
+			if mask&(1<<k) == 0 {
+				continue
+			}
+			present = append(present, k)
+			names = append(names, layer.String())
+			for _, dimension := range []string{"strength", "move_speed"} {
+				caps = append(caps,
+					interfaces.CapContribution{System: "test_system", Dimension: dimension, Mode: enums.CapModeBaseline, Kind: "min", Value: layerCaps(k).Min, Scope: layer.String()},
+					interfaces.CapContribution{System: "test_system", Dimension: dimension, Mode: enums.CapModeBaseline, Kind: "max", Value: layerCaps(k).Max, Scope: layer.String()},
+				)
+			}
+		}
+		outputs := []*interfaces.SubsystemOutput{{Caps: caps}}
+
+		for _, acrossPolicy := range policies {
+			for _, dimensionPolicy := range policies {
+				name := strings.Join(names, "+") + "/" + acrossPolicy.String() + "/" + dimensionPolicy.String()
+				t.Run(name, func(t *testing.T) {
+					layerRegistry := registry.NewCapLayerRegistry()
+					if err := layerRegistry.SetAcrossLayerPolicy(acrossPolicy.String()); err != nil {
+						t.Fatalf("SetAcrossLayerPolicy() error = %v", err)
+					}
+					if err := layerRegistry.SetDimensionPolicy("move_speed", dimensionPolicy.String()); err != nil {
+						t.Fatalf("SetDimensionPolicy() error = %v", err)
+					}
+					cp := services.NewCapsProvider(layerRegistry)
+
+					effectiveCaps, err := cp.EffectiveCapsAcrossLayers(context.Background(), actor, outputs)
+					if err != nil {
+						t.Fatalf("EffectiveCapsAcrossLayers() error = %v", err)
+					}
+
+					if got, want := effectiveCaps["strength"], expected(acrossPolicy, present); got != want {
+						t.Errorf("EffectiveCapsAcrossLayers() strength = %+v, want %+v", got, want)
+					}
+					if got, want := effectiveCaps["move_speed"], expected(dimensionPolicy, present); got != want {
+						t.Errorf("EffectiveCapsAcrossLayers() move_speed = %+v, want %+v", got, want)
+					}
+					if policy := cp.GetDimensionPolicy("move_speed"); policy != dimensionPolicy.String() {
+						t.Errorf("GetDimensionPolicy() = %v, want %v", policy, dimensionPolicy)
+					}
+				})
+			}
+		}
+	}
+}
+
+func TestCapsProviderImpl_EffectiveCapsAcrossLayers_CustomPolicy(t *testing.T) {
+	layerRegistry := registry.NewCapLayerRegistry()
+	if err := layerRegistry.RegisterPolicy(sumMaxPolicy{}); err != nil {
+		t.Fatalf("RegisterPolicy() error = %v", err)
+	}
+	if err := layerRegistry.SetDimensionPolicy("move_speed", "sum_max"); err != nil {
+		t.Fatalf("SetDimensionPolicy() error = %v", err)
+	}
+	cp := services.NewCapsProvider(layerRegistry)
+
+	// A festival doubles the realm's max speed
+	outputs := []*interfaces.SubsystemOutput{{Caps: []interfaces.CapContribution{
+		{System: "realm", Dimension: "move_speed", Mode: enums.CapModeBaseline, Kind: "max", Value: 10, Scope: "REALM"},
+		{System: "festival", Dimension: "move_speed", Mode: enums.CapModeBaseline, Kind: "max", Value: 10, Scope: "EVENT"},
+	}}}
+
+	effectiveCaps, err := cp.EffectiveCapsAcrossLayers(context.Background(), &interfaces.Actor{ID: "test_actor", Version: 1}, outputs)
+	if err != nil {
+		t.Fatalf("EffectiveCapsAcrossLayers() error = %v", err)
+	}
+
+	if got := effectiveCaps["move_speed"].Max; got != 20 {
+		t.Errorf("EffectiveCapsAcrossLayers() move_speed max = %v, want 20", got)
+	}
+}
+
+// sumMaxPolicy adds up the maximums of the layers
+type sumMaxPolicy struct{}
+
+func (sumMaxPolicy) Name() string {
+	return "sum_max"
+}
+
+func (sumMaxPolicy) Combine(dimension string, layers []interfaces.LayerCaps) (interfaces.Caps, error) {
+	result := interfaces.Caps{Min: layers[0].Caps.Min}
+	for _, layer := range layers {
+		result.Max += layer.Caps.Max
+	}
+	return result, nil
+}
+
+func TestCapsProviderImpl_EffectiveCapsAcrossLayers_LayerPolicies(t *testing.T) {
+	layerRegistry := registry.NewCapLayerRegistry()
+	for _, layer := range []interfaces.LayerDefinition{
+		{Name: "PARTY"},
+		{Name: "SEASON", Description: "Seasonal festivals", DefaultPolicy: "union"},
+	} {
+		if err := layerRegistry.RegisterLayer(layer); err != nil {
+			t.Fatalf("RegisterLayer() error = %v", err)
+		}
+	}
+	if err := layerRegistry.SetLayerOrder([]string{"REALM", "SEASON", "PARTY", "TOTAL"}); err != nil {
+		t.Fatalf("SetLayerOrder() error = %v", err)
+	}
+	cp := services.NewCapsProvider(layerRegistry)
+	actor := &interfaces.Actor{ID: "test_actor", Version: 1}
+
+	maxCap := func(dimension, layer string, value float64) interfaces.CapContribution {
+		return interfaces.CapContribution{System: "test_system", Dimension: dimension, Mode: enums.CapModeBaseline, Kind: "max", Value: value, Scope: layer}
+	}
+
+	// The festival raises the realm's max speed, and the party and total
+	// layers still intersect with the result
+	outputs := []*interfaces.SubsystemOutput{{Caps: []interfaces.CapContribution{
+		maxCap("move_speed", "REALM", 10),
+		maxCap("move_speed", "SEASON", 20),
+		maxCap("move_speed", "PARTY", 18),
+		maxCap("attack_speed", "REALM", 10),
+		maxCap("attack_speed", "SEASON", 20),
+		maxCap("cast_speed", "REALM", 10),
+		maxCap("cast_speed", "SEASON", 20),
+	}}}
+
+	if err := layerRegistry.SetDimensionPolicy("cast_speed", "intersect"); err != nil {
+		t.Fatalf("SetDimensionPolicy() error = %v", err)
+	}
+
+	effectiveCaps, err := cp.EffectiveCapsAcrossLayers(context.Background(), actor, outputs)
+	if err != nil {
+		t.Fatalf("EffectiveCapsAcrossLayers() error = %v", err)
+	}
+
+	want := map[string]float64{"move_speed": 18, "attack_speed": 20, "cast_speed": 10}
+	for dimension, max := range want {
+		if got := effectiveCaps[dimension].Max; got != max {
+			t.Errorf("EffectiveCapsAcrossLayers() %s max = %v, want %v", dimension, got, max)
+		}
+	}
+}
+
+func TestCapsProviderImpl_EffectiveCapsAcrossLayers_UnknownScope(t *testing.T) {
+	cp := services.NewCapsProvider(registry.NewCapLayerRegistry())
+
+	outputs := []*interfaces.SubsystemOutput{{Caps: []interfaces.CapContribution{
+		{System: "party", Dimension: "strength", Mode: enums.CapModeHardMax, Kind: "max", Value: 50, Scope: "PARTY"},
+	}}}
+
+	if _, err := cp.EffectiveCapsAcrossLayers(context.Background(), &interfaces.Actor{ID: "test_actor", Version: 1}, outputs); err == nil {
+		t.Error("EffectiveCapsAcrossLayers() should return error for a cap in an unknown layer")
+	}
+}
+
+func TestCapsProviderImpl_EffectiveCapsWithinLayer_Realms(t *testing.T) {
+	cp := services.NewCapsProvider(registry.NewCapLayerRegistry())
+
+	outputs := []*interfaces.SubsystemOutput{{Caps: []interfaces.CapContribution{
+		{System: "jindan", Dimension: "qi_max", Mode: enums.CapModeHardMax, Kind: "max", Value: 9000, Scope: "REALM"},
+		{System: "jindan", Dimension: "qi_max", Mode: enums.CapModeHardMax, Kind: "max", Value: 5000, Scope: "REALM", Realm: "foundation"},
+		{System: "jindan", Dimension: "qi_max", Mode: enums.CapModeHardMax, Kind: "max", Value: 4000, Scope: "REALM", Realm: "foundation/vien_man"},
+		{System: "jindan", Dimension: "qi_max", Mode: enums.CapModeHardMax, Kind: "max", Value: 100, Scope: "REALM", Realm: "mortal"},
+	}}}
+
+	tests := []struct {
+		name  string
+		realm interface{}
+		ctx   []string
+		want  float64
+	}{
+		{name: "NoRealm", want: 9000},
+		{name: "Realm", realm: "foundation", want: 5000},
+		{name: "SubRealm", realm: "foundation/so", want: 5000},
+		{name: "NestedSubRealm", realm: "foundation/vien_man", want: 4000},
+		{name: "PrefixIsNotSubRealm", realm: "foundation_x", want: 9000},
+		{name: "SeveralRealms", realm: []interface{}{"mortal", "foundation"}, want: 100},
+		{name: "ContextOverridesActor", realm: "mortal", ctx: []string{"foundation"}, want: 5000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actor := &interfaces.Actor{ID: "test_actor", Version: 1, Data: map[string]interface{}{}}
+			if tt.realm != nil {
+				actor.Data["realm"] = tt.realm
+			}
+
+			ctx := context.Background()
+			if tt.ctx != nil {
+				ctx = services.WithRealms(ctx, tt.ctx...)
+			}
+
+			effectiveCaps, err := cp.EffectiveCapsWithinLayer(ctx, actor, outputs, "REALM")
+			if err != nil {
+				t.Fatalf("EffectiveCapsWithinLayer() error = %v", err)
+			}
+
+			if got := effectiveCaps["qi_max"].Max; got != tt.want {
+				t.Errorf("EffectiveCapsWithinLayer() qi_max max = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCapsProviderImpl_RealmCapTable(t *testing.T) {
+	table, err := registry.NewRealmCapTableFromFile("testdata/realms.yml")
+	if err != nil {
+		t.Fatalf("NewRealmCapTableFromFile() error = %v", err)
+	}
+
+	cp := services.NewCapsProvider(registry.NewCapLayerRegistry()).(*services.CapsProviderImpl)
+	generation := cp.GetGeneration()
+	cp.SetRealmCapTable(table)
+	if cp.GetRealmCapTable() != table || cp.GetGeneration() == generation {
+		t.Fatal("SetRealmCapTable() should set the table and bump the generation")
+	}
+
+	// A subsystem cap in the same layer still intersects with the table's
+	outputs := []*interfaces.SubsystemOutput{{Caps: []interfaces.CapContribution{
+		{System: "items", Dimension: "dantian_capacity", Mode: enums.CapModeHardMax, Kind: "max", Value: 15000, Scope: "REALM"},
+	}}}
+
+	tests := []struct {
+		name  string
+		realm []string
+		want  map[string]interfaces.Caps
+	}{
+		{name: "NoRealm", want: map[string]interfaces.Caps{
+			"dantian_capacity": {Min: -math.MaxFloat64, Max: 15000},
+		}},
+		// The stage's range overrides the realm's; the realm's other caps still apply
+		{name: "Stage", realm: []string{"foundation/so"}, want: map[string]interfaces.Caps{
+			"dantian_capacity": {Min: 12000, Max: 15000},
+			"qi_max":           {Min: 0, Max: 5000},
+			"qi_purity":        {Min: 0.25, Max: 0.45},
+		}},
+		{name: "RealmOnly", realm: []string{"foundation"}, want: map[string]interfaces.Caps{
+			"dantian_capacity": {Min: -math.MaxFloat64, Max: 15000},
+			"qi_max":           {Min: 0, Max: 5000},
+			"qi_purity":        {Min: 0.25, Max: 0.70},
+		}},
+		{name: "Tier", realm: []string{"qi_1_9/t1"}, want: map[string]interfaces.Caps{
+			"dantian_capacity": {Min: 200, Max: 800},
+			"qi_purity":        {Min: 0.05, Max: 0.15},
+		}},
+		// The hard ceiling clamps the stage's floor
+		{name: "StageAndPillGrade", realm: []string{"golden_core/ha", "golden_core/pill_grade/cuc"}, want: map[string]interfaces.Caps{
+			"dantian_capacity":  {Min: 15000, Max: 15000},
+			"dantian_stability": {Min: 240, Max: 400},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actor := &interfaces.Actor{ID: "test_actor", Version: 1, Data: map[string]interface{}{}}
+			if tt.realm != nil {
+				actor.Data["realm"] = tt.realm
+			}
+
+			effectiveCaps, err := cp.EffectiveCapsWithinLayer(context.Background(), actor, outputs, "REALM")
+			if err != nil {
+				t.Fatalf("EffectiveCapsWithinLayer() error = %v", err)
+			}
+
+			if len(effectiveCaps) != len(tt.want) {
+				t.Errorf("EffectiveCapsWithinLayer() = %v, want %v", effectiveCaps, tt.want)
+			}
+			for dimension, want := range tt.want {
+				if got := effectiveCaps[dimension]; got != want {
+					t.Errorf("EffectiveCapsWithinLayer() %s = %v, want %v", dimension, got, want)
+				}
+			}
+		})
+	}
+
+	// A table scoped to an unknown layer is reported when caps are combined
+	unknown, err := registry.ParseRealmCapTable(map[string]interface{}{
+		"layer":  "SECT",
+		"realms": []interface{}{map[string]interface{}{"id": "mortal", "caps": map[string]interface{}{"qi_max": []interface{}{0.0, 100.0}}}},
+	})
+	if err != nil {
+		t.Fatalf("ParseRealmCapTable() error = %v", err)
+	}
+	cp.SetRealmCapTable(unknown)
+	if _, err := cp.EffectiveCapsAcrossLayers(context.Background(), &interfaces.Actor{ID: "test_actor", Version: 1}, nil); err == nil {
+		t.Error("EffectiveCapsAcrossLayers() should return error for a realm cap table in an unknown layer")
+	}
+}
+
 func TestCapsProviderImpl_GetLayerOrder(t *testing.T) {
 	layerRegistry := registry.NewCapLayerRegistry()
 	cp := services.NewCapsProvider(layerRegistry)
@@ -160,6 +577,103 @@ func TestCapsProviderImpl_GetCapsForDimension_Invalid(t *testing.T) {
 	if err == nil {
 		t.Error("GetCapsForDimension() should return error for empty dimension")
 	}
+
+	if _, err := cp.GetCapsForDimension("qi_max"); err == nil {
+		t.Error("GetCapsForDimension() should return error for a dimension missing from the catalog")
+	}
+}
+
+func TestCapsProviderImpl_SetRealmCapTable_Concurrent(t *testing.T) {
+	table, err := registry.NewRealmCapTableFromFile("testdata/realms.yml")
+	if err != nil {
+		t.Fatalf("NewRealmCapTableFromFile() error = %v", err)
+	}
+
+	cp := services.NewCapsProvider(registry.NewCapLayerRegistry()).(*services.CapsProviderImpl)
+	actor := &interfaces.Actor{ID: "actor", Version: 1, Data: map[string]interface{}{"realm": "foundation/so"}}
+	outputs := []*interfaces.SubsystemOutput{{Caps: []interfaces.CapContribution{
+		{System: "items", Dimension: "dantian_capacity", Mode: enums.CapModeHardMax, Kind: "max", Value: 15000, Scope: "REALM"},
+	}}}
+
+	// Readers run long enough for a writer to queue between a reader's nested
+	// lock acquisitions, which deadlocked before they were flattened
+	deadline := time.Now().Add(time.Second)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for time.Now().Before(deadline) {
+				if _, err := cp.EffectiveCapsAcrossLayers(context.Background(), actor, outputs); err != nil {
+					t.Errorf("EffectiveCapsAcrossLayers() error = %v", err)
+					return
+				}
+				if _, err := cp.GetCapStatistics(context.Background(), actor, outputs); err != nil {
+					t.Errorf("GetCapStatistics() error = %v", err)
+					return
+				}
+			}
+		}()
+	}
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				cp.SetRealmCapTable(table)
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(stop)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("readers deadlocked behind SetRealmCapTable")
+	}
+}
+
+func TestCapsProviderImpl_DimensionCatalog(t *testing.T) {
+	catalog, err := registry.NewDimensionCatalogFromFile("testdata/dimensions.yml")
+	if err != nil {
+		t.Fatalf("NewDimensionCatalogFromFile() error = %v", err)
+	}
+
+	cp := services.NewCapsProvider(registry.NewCapLayerRegistry()).(*services.CapsProviderImpl)
+	if err := cp.SetDimensionCatalog(nil); err == nil {
+		t.Error("SetDimensionCatalog() should return error for a nil catalog")
+	}
+	if err := cp.SetDimensionCatalog(catalog); err != nil {
+		t.Fatalf("SetDimensionCatalog() error = %v", err)
+	}
+	if cp.GetDimensionCatalog() != catalog {
+		t.Error("GetDimensionCatalog() should return the catalog set")
+	}
+
+	if got, want := cp.GetSupportedDimensions(), []string{"qi_max", "qi_purity", "spirit"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetSupportedDimensions() = %v, want %v", got, want)
+	}
+
+	caps, err := cp.GetCapsForDimension("qi_max")
+	if err != nil || caps != (interfaces.Caps{Min: 0, Max: 50000}) {
+		t.Errorf("GetCapsForDimension(qi_max) = %v, %v, want {0 50000}", caps, err)
+	}
+
+	// Dimensions dropped from the catalog are no longer supported
+	if _, err := cp.GetCapsForDimension("strength"); err == nil {
+		t.Error("GetCapsForDimension() should return error for strength")
+	}
+
+	if err := cp.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
 }
 
 func TestCapsProviderImpl_ValidateCaps(t *testing.T) {
@@ -192,6 +706,26 @@ func TestCapsProviderImpl_ValidateCaps(t *testing.T) {
 	if err == nil {
 		t.Error("ValidateCaps() should return error for nil caps")
 	}
+
+	// Caps with only a maximum are left open below
+	maxOnly, err := cp.EffectiveCapsWithinLayer(context.Background(), &interfaces.Actor{ID: "actor", Version: 1}, []*interfaces.SubsystemOutput{{
+		Caps: []interfaces.CapContribution{
+			{System: "realm", Dimension: "move_speed", Mode: enums.CapModeBaseline, Kind: "max", Value: 10, Scope: "REALM"},
+		},
+	}}, "REALM")
+	if err != nil {
+		t.Fatalf("EffectiveCapsWithinLayer() error = %v", err)
+	}
+	if maxOnly["move_speed"].Min != -math.MaxFloat64 {
+		t.Fatalf("EffectiveCapsWithinLayer() move_speed = %v, want an open lower bound", maxOnly["move_speed"])
+	}
+	if err := cp.ValidateCaps(maxOnly); err != nil {
+		t.Errorf("ValidateCaps() error = %v for a max-only cap", err)
+	}
+
+	if err := cp.ValidateCaps(interfaces.EffectiveCaps{"strength": interfaces.Caps{Min: -5, Max: 50}}); err == nil {
+		t.Error("ValidateCaps() should return error for a negative min cap")
+	}
 }
 
 func TestCapsProviderImpl_GetSupportedDimensions(t *testing.T) {
@@ -226,6 +760,7 @@ func TestCapsProviderImpl_GetCapStatistics(t *testing.T) {
 	actor := &interfaces.Actor{
 		ID:      "test_actor",
 		Version: 1,
+		Data:    map[string]interface{}{"realm": "test_realm"},
 	}
 
 	outputs := []*interfaces.SubsystemOutput{
@@ -239,7 +774,7 @@ func TestCapsProviderImpl_GetCapStatistics(t *testing.T) {
 					Priority:  1000,
 					Scope:     "REALM",
 					Realm:     "test_realm",
-					Tags:      []string{"test"},
+					Tags:      map[string]string{"test": "true"},
 				},
 			},
 		},
@@ -255,6 +790,86 @@ func TestCapsProviderImpl_GetCapStatistics(t *testing.T) {
 	}
 }
 
+func TestCapsProviderImpl_GetCapStatistics_CapHits(t *testing.T) {
+	cp := services.NewCapsProvider(registry.NewCapLayerRegistry()).(*services.CapsProviderImpl)
+	if got := cp.GetCapStatisticsWindow(); got != constants.DefaultCapStatisticsWindow {
+		t.Errorf("GetCapStatisticsWindow() = %v, want %v", got, constants.DefaultCapStatisticsWindow)
+	}
+	if err := cp.SetCapStatisticsWindow(-1); err == nil {
+		t.Error("SetCapStatisticsWindow() should return error for a negative window")
+	}
+	if err := cp.SetCapStatisticsWindow(3); err != nil {
+		t.Fatalf("SetCapStatisticsWindow() error = %v", err)
+	}
+
+	caps := map[string]interfaces.Caps{
+		"strength": {Min: 0, Max: 100},
+		"hp_max":   {Min: 1, Max: 1000},
+		"agility":  {Min: 0, Max: 50},
+	}
+	resolve := func(strength, hpMax float64) *interfaces.Snapshot {
+		// agility is capped but has no value, so it is not counted
+		return &interfaces.Snapshot{
+			Primary:  map[string]float64{"strength": strength},
+			Derived:  map[string]float64{"hp_max": hpMax},
+			CapsUsed: caps,
+		}
+	}
+
+	capHits := func() (int, map[string]interfaces.CapHitStatistics) {
+		t.Helper()
+		stats, err := cp.GetCapStatistics(context.Background(), &interfaces.Actor{ID: "test_actor", Version: 1}, []*interfaces.SubsystemOutput{})
+		if err != nil {
+			t.Fatalf("GetCapStatistics() error = %v", err)
+		}
+		return stats["resolves"].(int), stats["cap_hits"].(map[string]interfaces.CapHitStatistics)
+	}
+
+	cp.RecordResolve(resolve(100, 1))
+	cp.RecordResolve(resolve(50, 1000))
+	cp.RecordResolve(resolve(0, 500))
+	cp.RecordResolve(nil)
+
+	resolves, hits := capHits()
+	want := map[string]interfaces.CapHitStatistics{
+		"strength": {Capped: 3, AtMin: 1, AtMax: 1, Hits: 2},
+		"hp_max":   {Capped: 3, AtMin: 1, AtMax: 1, Hits: 2},
+	}
+	if resolves != 3 || !reflect.DeepEqual(hits, want) {
+		t.Errorf("GetCapStatistics() = %d resolves, %+v, want 3, %+v", resolves, hits, want)
+	}
+	if got := hits["strength"].HitRate(); math.Abs(got-2.0/3.0) > 1e-9 {
+		t.Errorf("HitRate() = %v, want 2/3", got)
+	}
+
+	// Only the most recent resolves are kept
+	cp.RecordResolve(resolve(50, 500))
+	cp.RecordResolve(resolve(50, 500))
+
+	resolves, hits = capHits()
+	want = map[string]interfaces.CapHitStatistics{
+		"strength": {Capped: 3, AtMin: 1, Hits: 1},
+		"hp_max":   {Capped: 3},
+	}
+	if resolves != 3 || !reflect.DeepEqual(hits, want) {
+		t.Errorf("GetCapStatistics() = %d resolves, %+v, want 3, %+v", resolves, hits, want)
+	}
+
+	cp.ResetCapStatistics()
+	if resolves, hits := capHits(); resolves != 0 || len(hits) != 0 {
+		t.Errorf("GetCapStatistics() = %d resolves, %+v after ResetCapStatistics(), want none", resolves, hits)
+	}
+
+	// A zero window disables recording
+	if err := cp.SetCapStatisticsWindow(0); err != nil {
+		t.Fatalf("SetCapStatisticsWindow() error = %v", err)
+	}
+	cp.RecordResolve(resolve(100, 1000))
+	if resolves, _ := capHits(); resolves != 0 {
+		t.Errorf("GetCapStatistics() = %d resolves, want 0 with a zero window", resolves)
+	}
+}
+
 func TestCapsProviderImpl_Validate(t *testing.T) {
 	layerRegistry := registry.NewCapLayerRegistry()
 	cp := services.NewCapsProvider(layerRegistry)
@@ -210,6 +210,26 @@ func (a *Actor) GetSubsystem(systemID string) (Subsystem, bool) {
 	return Subsystem{}, false
 }
 
+// IsSubsystemEnabled checks if a subsystem runs for the actor. Actors without
+// their own subsystem list run every registered subsystem.
+func (a *Actor) IsSubsystemEnabled(systemID string) bool {
+	if len(a.Subsystems) == 0 {
+		return true
+	}
+
+	subsystem, exists := a.GetSubsystem(systemID)
+	return exists && subsystem.Enabled
+}
+
+// GetSubsystemConfig returns the actor's configuration for a subsystem
+func (a *Actor) GetSubsystemConfig(systemID string) (map[string]interface{}, bool) {
+	subsystem, exists := a.GetSubsystem(systemID)
+	if !exists || len(subsystem.Config) == 0 {
+		return nil, false
+	}
+	return subsystem.Config, true
+}
+
 // HasSubsystem checks if the actor has a subsystem
 func (a *Actor) HasSubsystem(systemID string) bool {
 	_, exists := a.GetSubsystem(systemID)
@@ -254,6 +274,37 @@ func (a *Actor) SetGuildID(guildID string) {
 	a.Data["guild_id"] = guildID
 }
 
+// GetRealms returns the realm paths from the actor's data. The "realm" entry
+// holds a path such as "foundation/so", or a list of paths for actors in
+// several sub-realms at once.
+func (a *Actor) GetRealms() []string {
+	switch realm := a.Data["realm"].(type) {
+	case string:
+		if realm != "" {
+			return []string{realm}
+		}
+	case []string:
+		return realm
+	case []interface{}:
+		realms := make([]string, 0, len(realm))
+		for _, path := range realm {
+			if s, ok := path.(string); ok && s != "" {
+				realms = append(realms, s)
+			}
+		}
+		return realms
+	}
+	return nil
+}
+
+// SetRealm sets the realm path in the actor's data
+func (a *Actor) SetRealm(realm string) {
+	if a.Data == nil {
+		a.Data = make(map[string]interface{})
+	}
+	a.Data["realm"] = realm
+}
+
 // IsInCombat checks if the actor is in combat
 func (a *Actor) IsInCombat() bool {
 	if inCombat, exists := a.Data["in_combat"]; exists {
@@ -2,6 +2,8 @@ package types
 
 import (
 	"chaos-actor-module/packages/actor-core/enums"
+	"sort"
+	"strings"
 )
 
 // Contribution represents a contribution to a dimension
@@ -286,7 +288,44 @@ func (cc *CapContribution) IsMaxCap() bool {
 	return cc.Kind == "max"
 }
 
-// GetSortKey returns the sort key for deterministic ordering
+// GetSortKey returns the sort key for deterministic ordering. Cap
+// contributions of equal priority are ordered by their sort keys.
 func (cc *CapContribution) GetSortKey() string {
-	return cc.Dimension + ":" + string(cc.Mode) + ":" + cc.Kind
+	return cc.Dimension + ":" + string(cc.Mode) + ":" + cc.Kind + ":" + cc.System
+}
+
+// TagsFromList converts a list of tags to a tag map. "key=value" entries map
+// key to value; bare entries map to "true".
+func TagsFromList(list []string) map[string]string {
+	if len(list) == 0 {
+		return nil
+	}
+
+	tags := make(map[string]string, len(list))
+	for _, tag := range list {
+		key, value, found := strings.Cut(tag, "=")
+		if !found {
+			value = "true"
+		}
+		tags[key] = value
+	}
+	return tags
+}
+
+// TagList converts a tag map to a sorted list of tags, the inverse of TagsFromList
+func TagList(tags map[string]string) []string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	list := make([]string, 0, len(tags))
+	for key, value := range tags {
+		if value == "true" {
+			list = append(list, key)
+			continue
+		}
+		list = append(list, key+"="+value)
+	}
+	sort.Strings(list)
+	return list
 }
@@ -0,0 +1,95 @@
+package types
+
+import (
+	"time"
+)
+
+// ActorCoreError represents an error in the Actor Core system
+type ActorCoreError struct {
+	// Type is the error category
+	Type string `json:"type"`
+
+	// Code is the specific error code
+	Code string `json:"code"`
+
+	// Message is the human-readable message
+	Message string `json:"message"`
+
+	// System is the originating system
+	System string `json:"system"`
+
+	// Dimension is the affected dimension
+	Dimension string `json:"dimension"`
+
+	// Layer is the affected layer
+	Layer string `json:"layer"`
+
+	// Context contains additional context
+	Context map[string]interface{} `json:"context"`
+
+	// Timestamp is when the error occurred
+	Timestamp time.Time `json:"timestamp"`
+
+	// StackTrace is the stack trace (debug only)
+	StackTrace string `json:"stack_trace,omitempty"`
+}
+
+// Error returns the error message
+func (ace *ActorCoreError) Error() string {
+	return ace.Message
+}
+
+// GetType returns the error type
+func (ace *ActorCoreError) GetType() string {
+	return ace.Type
+}
+
+// GetCode returns the error code
+func (ace *ActorCoreError) GetCode() string {
+	return ace.Code
+}
+
+// GetSystem returns the originating system
+func (ace *ActorCoreError) GetSystem() string {
+	return ace.System
+}
+
+// GetDimension returns the affected dimension
+func (ace *ActorCoreError) GetDimension() string {
+	return ace.Dimension
+}
+
+// GetLayer returns the affected layer
+func (ace *ActorCoreError) GetLayer() string {
+	return ace.Layer
+}
+
+// GetContext returns the additional context
+func (ace *ActorCoreError) GetContext() map[string]interface{} {
+	return ace.Context
+}
+
+// GetTimestamp returns the timestamp
+func (ace *ActorCoreError) GetTimestamp() time.Time {
+	return ace.Timestamp
+}
+
+// GetStackTrace returns the stack trace
+func (ace *ActorCoreError) GetStackTrace() string {
+	return ace.StackTrace
+}
+
+// IsValidationError checks if this is a validation error
+func (ace *ActorCoreError) IsValidationError() bool {
+	return ace.Type == "validation"
+}
+
+// IsSystemError checks if this is a system error
+func (ace *ActorCoreError) IsSystemError() bool {
+	return ace.Type == "system"
+}
+
+// IsPerformanceError checks if this is a performance error
+func (ace *ActorCoreError) IsPerformanceError() bool {
+	return ace.Type == "performance"
+}
@@ -6,6 +6,9 @@ import (
 
 // Snapshot represents the final aggregated snapshot of an actor's stats
 type Snapshot struct {
+	// ActorID is the ID of the actor the snapshot was resolved for
+	ActorID string `json:"actor_id"`
+
 	// Primary contains primary dimension values
 	Primary map[string]float64 `json:"primary"`
 
@@ -15,6 +18,16 @@ type Snapshot struct {
 	// CapsUsed contains the caps that were applied
 	CapsUsed map[string]Caps `json:"caps_used"`
 
+	// Context contains the merged context modifier packs per context type
+	Context map[string]ModifierPack `json:"context,omitempty"`
+
+	// Failures records the subsystems that failed while resolving
+	Failures []SubsystemFailure `json:"failures,omitempty"`
+
+	// Provenance maps each dimension to the systems that contributed to it,
+	// sorted by system ID. It is only recorded when requested at resolve time.
+	Provenance map[string][]string `json:"provenance,omitempty"`
+
 	// Version is the actor version when this snapshot was created
 	Version int64 `json:"version"`
 
@@ -48,6 +61,21 @@ func (s *Snapshot) IsValid() bool {
 	return true
 }
 
+// SubsystemFailure records a subsystem that failed while resolving a snapshot
+type SubsystemFailure struct {
+	// System is the failing subsystem ID
+	System string `json:"system"`
+
+	// Policy is the error policy that was applied
+	Policy string `json:"policy"`
+
+	// Error describes why the subsystem failed
+	Error *ActorCoreError `json:"error"`
+
+	// UsedLastGood is true if the subsystem's last good output was used instead
+	UsedLastGood bool `json:"used_last_good"`
+}
+
 // GetPrimary returns the primary dimension values
 func (s *Snapshot) GetPrimary() map[string]float64 {
 	return s.Primary
@@ -254,9 +282,53 @@ func (s *Snapshot) GetTotalCaps() int64 {
 	return int64(len(s.CapsUsed))
 }
 
+// HasFailures checks if any subsystem failed while resolving the snapshot
+func (s *Snapshot) HasFailures() bool {
+	return len(s.Failures) > 0
+}
+
+// GetFailure returns the failure recorded for a subsystem
+func (s *Snapshot) GetFailure(systemID string) (SubsystemFailure, bool) {
+	for _, failure := range s.Failures {
+		if failure.System == systemID {
+			return failure, true
+		}
+	}
+	return SubsystemFailure{}, false
+}
+
+// GetContextModifier returns the merged modifier pack for a context type
+func (s *Snapshot) GetContextModifier(contextType string) (ModifierPack, bool) {
+	if s.Context == nil {
+		return ModifierPack{}, false
+	}
+	modifier, exists := s.Context[contextType]
+	return modifier, exists
+}
+
+// GetProvenance returns the systems that contributed to a dimension
+func (s *Snapshot) GetProvenance(dimension string) ([]string, bool) {
+	if s.Provenance == nil {
+		return nil, false
+	}
+	systems, exists := s.Provenance[dimension]
+	return systems, exists
+}
+
+// ApplyContext applies the merged modifier pack for a context type to a base value.
+// The base value is returned unchanged if no subsystem contributed to the context.
+func (s *Snapshot) ApplyContext(contextType string, baseValue float64) float64 {
+	modifier, exists := s.GetContextModifier(contextType)
+	if !exists {
+		return baseValue
+	}
+	return modifier.Apply(baseValue)
+}
+
 // Clone creates a deep copy of the snapshot
 func (s *Snapshot) Clone() *Snapshot {
 	clone := &Snapshot{
+		ActorID:             s.ActorID,
 		Version:             s.Version,
 		Timestamp:           s.Timestamp,
 		ProcessingTime:      s.ProcessingTime,
@@ -281,6 +353,28 @@ func (s *Snapshot) Clone() *Snapshot {
 		clone.CapsUsed[k] = v
 	}
 
+	// Copy context modifiers
+	if s.Context != nil {
+		clone.Context = make(map[string]ModifierPack)
+		for k, v := range s.Context {
+			clone.Context[k] = v.Merge(ModifierPack{})
+		}
+	}
+
+	// Copy failures
+	if s.Failures != nil {
+		clone.Failures = make([]SubsystemFailure, len(s.Failures))
+		copy(clone.Failures, s.Failures)
+	}
+
+	// Copy provenance
+	if s.Provenance != nil {
+		clone.Provenance = make(map[string][]string, len(s.Provenance))
+		for k, v := range s.Provenance {
+			clone.Provenance[k] = append([]string(nil), v...)
+		}
+	}
+
 	// Copy subsystems processed
 	copy(clone.SubsystemsProcessed, s.SubsystemsProcessed)
 
@@ -314,6 +314,20 @@ func (mp *ModifierPack) GetTotalMultiplier() float64 {
 	return total
 }
 
+// Merge combines two modifier packs: additive percents and post-adds are
+// summed, multiplier lists are concatenated so they multiply together
+func (mp ModifierPack) Merge(other ModifierPack) ModifierPack {
+	multipliers := make([]float64, 0, len(mp.Multipliers)+len(other.Multipliers))
+	multipliers = append(multipliers, mp.Multipliers...)
+	multipliers = append(multipliers, other.Multipliers...)
+
+	return ModifierPack{
+		AdditivePercent: mp.AdditivePercent + other.AdditivePercent,
+		Multipliers:     multipliers,
+		PostAdd:         mp.PostAdd + other.PostAdd,
+	}
+}
+
 // Apply applies the modifier pack to a base value
 func (mp *ModifierPack) Apply(baseValue float64) float64 {
 	// Step 1: Apply additive percentage